@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"otus-project/internal/client/db"
+	"otus-project/internal/client/db/pg"
+	"otus-project/internal/config"
+	"otus-project/internal/model"
+	"otus-project/internal/repository/feed"
+	feedModel "otus-project/internal/repository/feed/model"
+	feedPg "otus-project/internal/repository/feed/pg"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const usage = `Сравнение раскладки поста по лентам: задача на каждого друга против пачек по посту
+
+Использование:
+  go run ./cmd/feed_bench [-env .env] [-recipients 1000] [-posts 10] [-chunk 500]
+
+Для каждого режима раскладывается -posts постов по -recipients синтетическим получателям,
+записи в materialized_feeds и feed_jobs удаляются после замера.
+`
+
+func main() {
+	envPath := flag.String("env", ".env", "путь к файлу с переменными окружения")
+	recipientsCount := flag.Int("recipients", 1000, "количество получателей поста")
+	postsCount := flag.Int("posts", 10, "количество постов в каждом режиме")
+	chunkSize := flag.Int("chunk", 500, "размер пачки лент для раскладки по посту")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *recipientsCount <= 0 || *postsCount <= 0 || *chunkSize <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := config.Load(*envPath); err != nil {
+		log.Printf("env file %s not loaded: %v", *envPath, err)
+	}
+
+	pgConfig, err := config.NewPGConfig()
+	if err != nil {
+		log.Fatalf("failed to load pg config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	dbClient, err := pg.New(ctx, pgConfig.DSN(), pgConfig.DSNReplica())
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer dbClient.Close()
+
+	repo := feedPg.NewRepository(dbClient)
+
+	recipients := make([]string, *recipientsCount)
+	for i := range recipients {
+		recipients[i] = uuid.New().String()
+	}
+
+	modes := []struct {
+		name string
+		run  func(ctx context.Context, postID, authorID string) error
+	}{
+		{
+			name: "per-recipient",
+			run: func(ctx context.Context, postID, authorID string) error {
+				return perRecipient(ctx, repo, recipients, postID, authorID)
+			},
+		},
+		{
+			name: "batched",
+			run: func(ctx context.Context, postID, authorID string) error {
+				return batched(ctx, repo, recipients, *chunkSize, postID, authorID)
+			},
+		},
+	}
+
+	for _, mode := range modes {
+		postIDs := make([]string, *postsCount)
+		authorID := uuid.New().String()
+
+		started := time.Now()
+		for i := range postIDs {
+			postIDs[i] = uuid.New().String()
+			if err := mode.run(ctx, postIDs[i], authorID); err != nil {
+				cleanup(ctx, dbClient, repo, postIDs[:i+1])
+				log.Fatalf("%s: failed to fan out post: %v", mode.name, err)
+			}
+		}
+		elapsed := time.Since(started)
+
+		rows := *postsCount * *recipientsCount
+		log.Printf("%-14s posts=%d recipients=%d total=%s per_post=%s rows_per_sec=%.0f",
+			mode.name, *postsCount, *recipientsCount, elapsed, elapsed/time.Duration(*postsCount),
+			float64(rows)/elapsed.Seconds())
+
+		cleanup(ctx, dbClient, repo, postIDs)
+	}
+}
+
+// perRecipient повторяет прежнюю схему: отдельная задача, задание и вставка на каждого получателя
+func perRecipient(ctx context.Context, repo feed.Repository, recipients []string, postID, authorID string) error {
+	createdAt := time.Now().Truncate(time.Microsecond)
+
+	for _, userID := range recipients {
+		job := &feedModel.FeedJob{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			PostID:    postID,
+			EventType: model.FeedEventTypePostCreated,
+			Status:    feedModel.FeedJobStatusProcessing,
+			Attempts:  1,
+		}
+		if err := repo.CreateJob(ctx, job); err != nil {
+			return err
+		}
+
+		if err := repo.AddToFeeds(ctx, []string{userID}, postID, authorID, createdAt); err != nil {
+			return err
+		}
+
+		if err := repo.UpdateJobStatus(ctx, job.ID, feedModel.FeedJobStatusCompleted, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// batched повторяет текущую схему: одно задание на пост и вставка лент пачками
+func batched(ctx context.Context, repo feed.Repository, recipients []string, chunkSize int, postID, authorID string) error {
+	createdAt := time.Now().Truncate(time.Microsecond)
+
+	job := &feedModel.FeedJob{
+		ID:        uuid.New().String(),
+		PostID:    postID,
+		EventType: model.FeedEventTypePostCreated,
+		Status:    feedModel.FeedJobStatusProcessing,
+		Attempts:  1,
+	}
+	if err := repo.CreateJob(ctx, job); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(recipients); start += chunkSize {
+		chunk := recipients[start:min(start+chunkSize, len(recipients))]
+		if err := repo.AddToFeeds(ctx, chunk, postID, authorID, createdAt); err != nil {
+			return err
+		}
+	}
+
+	return repo.UpdateJobStatus(ctx, job.ID, feedModel.FeedJobStatusCompleted, nil)
+}
+
+// cleanup удаляет записи лент и задания, созданные замером
+func cleanup(ctx context.Context, dbClient db.Client, repo feed.Repository, postIDs []string) {
+	for _, postID := range postIDs {
+		if err := repo.RemovePostFromFeeds(ctx, postID); err != nil {
+			log.Printf("failed to remove post %s from feeds: %v", postID, err)
+		}
+	}
+
+	q := db.Query{
+		Name:     "feed_bench.cleanupJobs",
+		QueryRaw: `DELETE FROM feed_jobs WHERE post_id = ANY($1::uuid[])`,
+	}
+	if _, err := dbClient.DB().ExecContext(ctx, q, postIDs); err != nil {
+		log.Printf("failed to remove feed jobs: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"otus-project/internal/client/queue/rabbitmq"
+	"otus-project/internal/config"
+)
+
+const usage = `Работа с dead-letter очередью задач материализации ленты
+
+Использование:
+  go run ./cmd/feed_dlq [-env .env] [-limit 100] <list|replay|purge>
+
+Команды:
+  list    показать задачи вместе с ошибкой и количеством попыток (задачи остаются в очереди)
+  replay  вернуть задачи в очередь материализации со сброшенным счетчиком попыток
+  purge   удалить все задачи из dead-letter очереди
+`
+
+func main() {
+	envPath := flag.String("env", ".env", "путь к файлу с переменными окружения")
+	limit := flag.Int("limit", 100, "максимальное количество задач для list и replay")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := config.Load(*envPath); err != nil {
+		log.Printf("env file %s not loaded: %v", *envPath, err)
+	}
+
+	cfg, err := config.NewRabbitMQConfig()
+	if err != nil {
+		log.Fatalf("failed to load rabbitmq config: %v", err)
+	}
+
+	client, err := rabbitmq.NewClient(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to rabbitmq: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "list":
+		tasks, err := client.ListDeadLetters(ctx, *limit)
+		if err != nil {
+			log.Fatalf("failed to list dead-lettered tasks: %v", err)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		for _, task := range tasks {
+			if err := encoder.Encode(task); err != nil {
+				log.Fatalf("failed to encode task: %v", err)
+			}
+		}
+		log.Printf("%d dead-lettered tasks listed", len(tasks))
+	case "replay":
+		replayed, err := client.ReplayDeadLetters(ctx, *limit)
+		if err != nil {
+			log.Fatalf("replayed %d tasks, then failed: %v", replayed, err)
+		}
+		log.Printf("%d tasks replayed", replayed)
+	case "purge":
+		purged, err := client.PurgeDeadLetters(ctx)
+		if err != nil {
+			log.Fatalf("failed to purge dead-letter queue: %v", err)
+		}
+		log.Printf("%d tasks purged", purged)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
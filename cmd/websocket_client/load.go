@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const loadUsage = `Нагрузочный режим: тысячи WebSocket соединений и публикация постов с заданной частотой
+
+Использование:
+  go run ./cmd/websocket_client load [-api http://localhost:8089] [-ws ws://localhost:8090]
+      [-connections 1000] [-authors 10] [-rate 5] [-duration 1m] [-drain 30s] [-format text|json]
+
+Через REST API регистрируются -authors авторов и -connections слушателей, каждый слушатель
+добавляет в друзья одного автора и держит одно соединение, переподключаясь с last_event_id.
+Авторы по очереди публикуют посты с частотой -rate в секунду. Для каждого поста измеряется
+время от отправки POST /post/create до доставки поста каждому слушателю автора. Доставки,
+не полученные за -drain после окончания публикации, считаются потерянными.
+
+Слушателей на автора должно быть меньше FEED_CELEBRITY_THRESHOLD: посты знаменитостей не
+раскладываются по лентам при записи и не доставляются через WebSocket. Для тысяч соединений
+поднимите лимит файловых дескрипторов (ulimit -n).
+`
+
+// loadPostPrefix отмечает текст постов замера, по нему слушатели находят свои посты в ленте
+const loadPostPrefix = "load-test"
+
+type loadConfig struct {
+	apiURL           string
+	wsURL            string
+	connections      int
+	authors          int
+	rate             float64
+	duration         time.Duration
+	drain            time.Duration
+	setupConcurrency int
+	dialConcurrency  int
+	password         string
+	format           string
+}
+
+type loadUser struct {
+	id    string
+	token string
+}
+
+// runLoad запускает нагрузочный режим с аргументами после подкоманды load
+func runLoad(args []string) {
+	cfg := loadConfig{}
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	flags.StringVar(&cfg.apiURL, "api", "http://localhost:8089", "адрес REST API")
+	flags.StringVar(&cfg.wsURL, "ws", "ws://localhost:8090", "адрес WebSocket сервера")
+	flags.IntVar(&cfg.connections, "connections", 1000, "количество слушателей, у каждого одно соединение")
+	flags.IntVar(&cfg.authors, "authors", 10, "количество авторов постов")
+	flags.Float64Var(&cfg.rate, "rate", 5, "частота публикации постов в секунду")
+	flags.DurationVar(&cfg.duration, "duration", time.Minute, "длительность публикации постов")
+	flags.DurationVar(&cfg.drain, "drain", 30*time.Second, "сколько ждать доставки после окончания публикации")
+	flags.IntVar(&cfg.setupConcurrency, "setup-concurrency", 50, "параллельных запросов к REST API при подготовке пользователей")
+	flags.IntVar(&cfg.dialConcurrency, "dial-concurrency", 100, "параллельных подключений при открытии соединений")
+	flags.StringVar(&cfg.password, "password", "load-test-password", "пароль синтетических пользователей")
+	flags.StringVar(&cfg.format, "format", "text", "формат отчета: text или json")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, loadUsage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if cfg.connections <= 0 || cfg.authors <= 0 || cfg.rate <= 0 || cfg.duration <= 0 || cfg.drain < 0 ||
+		cfg.setupConcurrency <= 0 || cfg.dialConcurrency <= 0 || (cfg.format != "text" && cfg.format != "json") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner := &loadRunner{
+		cfg:   cfg,
+		api:   newAPIClient(cfg.apiURL, cfg.setupConcurrency),
+		runID: uuid.New().String()[:8],
+		stats: newLoadStats(),
+	}
+
+	report, err := runner.run(ctx)
+	if err != nil {
+		log.Fatalf("load test failed: %v", err)
+	}
+
+	if err := report.write(os.Stdout, cfg.format); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+}
+
+type loadRunner struct {
+	cfg       loadConfig
+	api       *apiClient
+	runID     string
+	stats     *loadStats
+	authors   []loadUser
+	listeners []loadUser
+}
+
+// run готовит пользователей, открывает соединения, публикует посты и собирает отчет
+func (r *loadRunner) run(ctx context.Context) (*loadReport, error) {
+	if perAuthor := (r.cfg.connections + r.cfg.authors - 1) / r.cfg.authors; perAuthor >= 1000 {
+		log.Printf("warning: %d listeners per author, authors above FEED_CELEBRITY_THRESHOLD get no WebSocket delivery", perAuthor)
+	}
+
+	if err := r.setup(ctx); err != nil {
+		return nil, err
+	}
+
+	listenCtx, stopListeners := context.WithCancel(ctx)
+	defer stopListeners()
+
+	var connected, listeners sync.WaitGroup
+	dialSlots := make(chan struct{}, r.cfg.dialConcurrency)
+	started := time.Now()
+	for _, user := range r.listeners {
+		connected.Add(1)
+		listeners.Add(1)
+		go func() {
+			defer listeners.Done()
+			r.listen(listenCtx, user, dialSlots, connected.Done)
+		}()
+	}
+	connected.Wait()
+	log.Printf("opened %d connections in %s, %d dial failures", r.stats.connected.Load(), time.Since(started).Round(time.Millisecond), r.stats.dialFailures.Load())
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	publishStarted := time.Now()
+	r.publish(ctx)
+	publishDuration := time.Since(publishStarted)
+	log.Printf("published %d posts in %s, waiting up to %s for deliveries", r.stats.postsCreated(), publishDuration.Round(time.Millisecond), r.cfg.drain)
+
+	r.waitDeliveries(ctx)
+	stopListeners()
+	listeners.Wait()
+
+	return r.stats.report(len(r.authors)+len(r.listeners), len(r.listeners), publishDuration), nil
+}
+
+// setup регистрирует и авторизует авторов и слушателей, затем добавляет каждому слушателю автора в друзья
+func (r *loadRunner) setup(ctx context.Context) error {
+	started := time.Now()
+
+	users := make([]loadUser, r.cfg.authors+r.cfg.connections)
+	err := parallel(ctx, len(users), r.cfg.setupConcurrency, func(i int) error {
+		id, err := r.api.register(ctx, fmt.Sprintf("Load%d", i), r.cfg.password)
+		if err != nil {
+			return fmt.Errorf("register user: %w", err)
+		}
+		token, err := r.api.login(ctx, id, r.cfg.password)
+		if err != nil {
+			return fmt.Errorf("login user %s: %w", id, err)
+		}
+		users[i] = loadUser{id: id, token: token}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.authors, r.listeners = users[:r.cfg.authors], users[r.cfg.authors:]
+	log.Printf("registered %d authors and %d listeners in %s", len(r.authors), len(r.listeners), time.Since(started).Round(time.Millisecond))
+
+	started = time.Now()
+	err = parallel(ctx, len(r.listeners), r.cfg.setupConcurrency, func(i int) error {
+		author := r.authors[i%len(r.authors)]
+		if err := r.api.addFriend(ctx, r.listeners[i].token, author.id); err != nil {
+			return fmt.Errorf("add friend %s for %s: %w", author.id, r.listeners[i].id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("added %d friendships in %s", len(r.listeners), time.Since(started).Round(time.Millisecond))
+
+	return nil
+}
+
+// listen держит соединение слушателя до отмены ctx, переподключаясь с номером последнего
+// полученного сообщения. ready вызывается один раз после первой попытки подключения
+func (r *loadRunner) listen(ctx context.Context, user loadUser, dialSlots chan struct{}, ready func()) {
+	var lastEventID int64
+	first := true
+	backoff := 100 * time.Millisecond
+
+	for ctx.Err() == nil {
+		if first {
+			dialSlots <- struct{}{}
+		}
+		conn, err := r.dial(ctx, user.token, lastEventID)
+		if first {
+			<-dialSlots
+			ready()
+		}
+		if err != nil {
+			r.stats.dialFailures.Add(1)
+			first = false
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 5*time.Second)
+			continue
+		}
+
+		if !first {
+			r.stats.reconnects.Add(1)
+		}
+		first = false
+		backoff = 100 * time.Millisecond
+
+		r.stats.connected.Add(1)
+		r.read(ctx, conn, user.id, &lastEventID)
+		r.stats.connected.Add(-1)
+		if ctx.Err() == nil {
+			r.stats.disconnects.Add(1)
+		}
+	}
+}
+
+// dial открывает соединение. last_event_id передается всегда, поэтому сервер не ждет resume
+// и после переподключения повторяет пропущенные посты
+func (r *loadRunner) dial(ctx context.Context, token string, lastEventID int64) (*websocket.Conn, error) {
+	u, err := url.Parse(r.cfg.wsURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/post/feed/posted"
+
+	q := u.Query()
+	q.Set("token", token)
+	q.Set("last_event_id", strconv.FormatInt(lastEventID, 10))
+	u.RawQuery = q.Encode()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	return conn, err
+}
+
+// read читает сообщения соединения до ошибки или отмены ctx и отмечает доставку постов замера
+func (r *loadRunner) read(ctx context.Context, conn *websocket.Conn, userID string, lastEventID *int64) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		receivedAt := time.Now()
+
+		var message struct {
+			Type    string      `json:"type"`
+			EventID int64       `json:"eventId"`
+			Payload PostPayload `json:"payload"`
+		}
+		if err := json.Unmarshal(data, &message); err != nil {
+			continue
+		}
+		if message.EventID > 0 {
+			*lastEventID = message.EventID
+		}
+
+		switch message.Type {
+		case "post":
+			if seq, ok := r.parsePostText(message.Payload.PostText); ok {
+				r.stats.delivered(seq, userID, receivedAt)
+			}
+		case "resync":
+			r.stats.resyncs.Add(1)
+		}
+	}
+}
+
+// publish создает посты с частотой cfg.rate в течение cfg.duration, авторы публикуют по очереди.
+// Каждый запрос идет в своей горутине, поэтому медленный API не снижает частоту
+func (r *loadRunner) publish(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / r.cfg.rate))
+	defer ticker.Stop()
+	deadline := time.After(r.cfg.duration)
+
+	var posts sync.WaitGroup
+	defer posts.Wait()
+
+	for seq := 0; ; seq++ {
+		author := r.authors[seq%len(r.authors)]
+		expected := (len(r.listeners) - seq%len(r.authors) + len(r.authors) - 1) / len(r.authors)
+
+		posts.Add(1)
+		go func() {
+			defer posts.Done()
+			// Время отправки записывается до запроса, поэтому задержка включает создание поста
+			r.stats.sent(seq, expected, time.Now())
+			if err := r.api.createPost(ctx, author.token, fmt.Sprintf("%s %s %d", loadPostPrefix, r.runID, seq)); err != nil {
+				log.Printf("failed to create post: %v", err)
+				r.stats.failed(seq)
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitDeliveries ждет, пока слушатели получат все посты, но не дольше cfg.drain
+func (r *loadRunner) waitDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(r.cfg.drain)
+
+	for !r.stats.complete() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// parsePostText возвращает номер поста этого замера из текста "load-test {runID} {seq}"
+func (r *loadRunner) parsePostText(text string) (int, bool) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 || fields[0] != loadPostPrefix || fields[1] != r.runID {
+		return 0, false
+	}
+
+	seq, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// parallel вызывает fn для индексов [0, n) не более чем в limit горутинах и возвращает первую ошибку
+func parallel(ctx context.Context, n, limit int, fn func(i int) error) error {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		failed   atomic.Bool
+	)
+	slots := make(chan struct{}, limit)
+
+	for i := 0; i < n && !failed.Load() && ctx.Err() == nil; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := fn(i); err != nil {
+				once.Do(func() { firstErr = err })
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// apiClient вызывает REST API от имени синтетических пользователей
+type apiClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newAPIClient(baseURL string, concurrency int) *apiClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = concurrency
+
+	return &apiClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+// register регистрирует пользователя и возвращает его идентификатор
+func (c *apiClient) register(ctx context.Context, secondName, password string) (string, error) {
+	request := map[string]string{
+		"first_name":  "Load",
+		"second_name": secondName,
+		"birthdate":   "2000-01-01",
+		"biography":   "websocket load test",
+		"city":        "Load",
+		"password":    password,
+	}
+	var response struct {
+		UserID string `json:"userId"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/user/register", "", request, &response); err != nil {
+		return "", err
+	}
+	return response.UserID, nil
+}
+
+// login возвращает токен пользователя
+func (c *apiClient) login(ctx context.Context, userID, password string) (string, error) {
+	request := map[string]string{"id": userID, "password": password}
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/login", "", request, &response); err != nil {
+		return "", err
+	}
+	return response.Token, nil
+}
+
+// addFriend добавляет пользователя friendID в друзья владельцу токена
+func (c *apiClient) addFriend(ctx context.Context, token, friendID string) error {
+	return c.do(ctx, http.MethodPut, "/friend/set/"+url.PathEscape(friendID), token, nil, nil)
+}
+
+// createPost создает пост от имени владельца токена
+func (c *apiClient) createPost(ctx context.Context, token, text string) error {
+	return c.do(ctx, http.MethodPost, "/post/create", token, map[string]string{"text": text}, nil)
+}
+
+func (c *apiClient) do(ctx context.Context, method, path, token string, request, response interface{}) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if response == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadStats собирает счетчики замера из горутин слушателей и публикации
+type loadStats struct {
+	connected    atomic.Int64
+	dialFailures atomic.Int64
+	disconnects  atomic.Int64
+	reconnects   atomic.Int64
+	resyncs      atomic.Int64
+
+	mu          sync.Mutex
+	posts       map[int]*loadPost
+	postsFailed int
+	latencies   []time.Duration
+	duplicates  int
+}
+
+// loadPost пост замера: время отправки, сколько слушателей должны его получить и кто уже получил
+type loadPost struct {
+	sentAt   time.Time
+	expected int
+	received map[string]struct{}
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{posts: make(map[int]*loadPost)}
+}
+
+// sent отмечает отправку поста seq, который должны получить expected слушателей
+func (s *loadStats) sent(seq, expected int, sentAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts[seq] = &loadPost{sentAt: sentAt, expected: expected, received: make(map[string]struct{}, expected)}
+}
+
+// failed убирает пост, который не удалось создать, из ожидаемых доставок
+func (s *loadStats) failed(seq int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.posts, seq)
+	s.postsFailed++
+}
+
+// delivered отмечает получение поста seq слушателем userID. Повторное получение считается дублем
+func (s *loadStats) delivered(seq int, userID string, receivedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[seq]
+	if !ok {
+		return
+	}
+	if _, ok := post.received[userID]; ok {
+		s.duplicates++
+		return
+	}
+	post.received[userID] = struct{}{}
+	s.latencies = append(s.latencies, receivedAt.Sub(post.sentAt))
+}
+
+// postsCreated возвращает количество успешно созданных постов
+func (s *loadStats) postsCreated() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.posts)
+}
+
+// complete проверяет, что все созданные посты получены всеми слушателями авторов
+func (s *loadStats) complete() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, post := range s.posts {
+		if len(post.received) < post.expected {
+			return false
+		}
+	}
+	return true
+}
+
+// report подводит итоги замера
+func (s *loadStats) report(users, connections int, publishDuration time.Duration) *loadReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &loadReport{
+		Users:        users,
+		Connections:  connections,
+		DialFailures: s.dialFailures.Load(),
+		Disconnects:  s.disconnects.Load(),
+		Reconnects:   s.reconnects.Load(),
+		Resyncs:      s.resyncs.Load(),
+		PostsCreated: len(s.posts),
+		PostsFailed:  s.postsFailed,
+		Delivered:    len(s.latencies),
+		Duplicates:   s.duplicates,
+	}
+	for _, post := range s.posts {
+		report.Expected += post.expected
+	}
+	if report.Expected > 0 {
+		report.DropRate = float64(report.Expected-report.Delivered) / float64(report.Expected)
+	}
+	if seconds := publishDuration.Seconds(); seconds > 0 {
+		report.PostRate = float64(report.PostsCreated+report.PostsFailed) / seconds
+	}
+
+	latencies := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	if len(latencies) > 0 {
+		var total time.Duration
+		for _, latency := range latencies {
+			total += latency
+		}
+		report.Latency = loadLatency{
+			Mean: milliseconds(total / time.Duration(len(latencies))),
+			P50:  milliseconds(percentile(latencies, 0.50)),
+			P90:  milliseconds(percentile(latencies, 0.90)),
+			P95:  milliseconds(percentile(latencies, 0.95)),
+			P99:  milliseconds(percentile(latencies, 0.99)),
+			Max:  milliseconds(latencies[len(latencies)-1]),
+		}
+	}
+
+	return report
+}
+
+// loadReport итоги замера: соединения, посты, доставки и задержка от POST /post/create до получения
+type loadReport struct {
+	Users        int         `json:"users"`
+	Connections  int         `json:"connections"`
+	DialFailures int64       `json:"dial_failures"`
+	Disconnects  int64       `json:"disconnects"`
+	Reconnects   int64       `json:"reconnects"`
+	Resyncs      int64       `json:"resyncs"`
+	PostsCreated int         `json:"posts_created"`
+	PostsFailed  int         `json:"posts_failed"`
+	PostRate     float64     `json:"post_rate"`
+	Expected     int         `json:"deliveries_expected"`
+	Delivered    int         `json:"deliveries"`
+	Duplicates   int         `json:"duplicates"`
+	DropRate     float64     `json:"drop_rate"`
+	Latency      loadLatency `json:"latency_ms"`
+}
+
+type loadLatency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// write выводит отчет в формате text или json
+func (r *loadReport) write(w io.Writer, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+
+	_, err := fmt.Fprintf(w, `users:        %d
+connections:  %d (dial failures %d, disconnects %d, reconnects %d, resyncs %d)
+posts:        %d created, %d failed, %.2f/s
+deliveries:   %d of %d, duplicates %d, drop rate %.2f%%
+latency ms:   mean %.1f, p50 %.1f, p90 %.1f, p95 %.1f, p99 %.1f, max %.1f
+`,
+		r.Users,
+		r.Connections, r.DialFailures, r.Disconnects, r.Reconnects, r.Resyncs,
+		r.PostsCreated, r.PostsFailed, r.PostRate,
+		r.Delivered, r.Expected, r.Duplicates, r.DropRate*100,
+		r.Latency.Mean, r.Latency.P50, r.Latency.P90, r.Latency.P95, r.Latency.P99, r.Latency.Max)
+	return err
+}
+
+// percentile возвращает перцентиль p отсортированных значений по методу ближайшего ранга
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
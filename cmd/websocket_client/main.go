@@ -24,9 +24,16 @@ type PostPayload struct {
 }
 
 func main() {
+	// Нагрузочный режим, см. load.go
+	if len(os.Args) >= 2 && os.Args[1] == "load" {
+		runLoad(os.Args[2:])
+		return
+	}
+
 	// Парсим аргументы командной строки
 	if len(os.Args) < 2 {
 		fmt.Println("Usage: websocket_client <server_url> [token]")
+		fmt.Println("       websocket_client load [flags]")
 		fmt.Println("Example: websocket_client ws://localhost:8090 <jwt_token>")
 		os.Exit(1)
 	}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/client/queue/rabbitmq"
+	"otus-project/internal/config"
+	"otus-project/internal/model"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const usage = `Рассылка объявления администратора всем WebSocket узлам
+
+Объявление получают только соединения, подписанные на канал announcements.
+
+Использование:
+  go run ./cmd/ws_announce [-env .env] <текст объявления>
+`
+
+func main() {
+	envPath := flag.String("env", ".env", "путь к файлу с переменными окружения")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	text := strings.TrimSpace(strings.Join(flag.Args(), " "))
+	if text == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := config.Load(*envPath); err != nil {
+		log.Printf("env file %s not loaded: %v", *envPath, err)
+	}
+
+	cfg, err := config.NewRabbitMQConfig()
+	if err != nil {
+		log.Fatalf("failed to load rabbitmq config: %v", err)
+	}
+
+	client, err := rabbitmq.NewClient(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to rabbitmq: %v", err)
+	}
+	defer client.Close()
+
+	announcement := &model.Announcement{
+		ID:        uuid.New().String(),
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+
+	err = client.PublishAnnouncement(context.Background(), announcement)
+	switch {
+	case errors.Is(err, queue.ErrUnroutable):
+		log.Printf("announcement %s not delivered: no WebSocket nodes are running", announcement.ID)
+	case err != nil:
+		log.Fatalf("failed to publish announcement: %v", err)
+	default:
+		log.Printf("announcement %s published", announcement.ID)
+	}
+}
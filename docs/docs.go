@@ -0,0 +1,9 @@
+// Package docs встраивает спецификации API в приложение
+package docs
+
+import _ "embed"
+
+// AsyncAPI спецификация WebSocket API, по ней проверяются сообщения клиентов
+//
+//go:embed asyncapi.json
+var AsyncAPI []byte
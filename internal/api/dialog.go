@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"otus-project/internal/converter"
 	"otus-project/internal/metric"
+	"otus-project/internal/model"
 	"otus-project/internal/utils"
 	"otus-project/pkg/api"
 	"strconv"
@@ -12,7 +13,7 @@ import (
 )
 
 // GetDialogUserIdList - обработчик GET запроса на /dialog/{user_id}/list
-func (i *Implementation) GetDialogUserIdList(w http.ResponseWriter, r *http.Request, userId api.UserId) {
+func (i *Implementation) GetDialogUserIdList(w http.ResponseWriter, r *http.Request, userId api.UserId, params api.GetDialogUserIdListParams) {
 	metric.IncRequestCounter()
 	timeStart := time.Now()
 
@@ -26,8 +27,15 @@ func (i *Implementation) GetDialogUserIdList(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	cursor, limit, err := parsePage(params.Cursor, params.Limit, 50, 1000)
+	if err != nil {
+		metric.IncResponseCounter(strconv.Itoa(http.StatusBadRequest), "GetDialogUserIdList")
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
 	// Получаем список сообщений диалога
-	messages, err := i.dialogService.GetDialogList(ctx, *fromUserId, string(userId))
+	messages, err := i.dialogService.GetDialogList(ctx, *fromUserId, string(userId), cursor, limit)
 	diffTime := time.Since(timeStart)
 
 	if err != nil {
@@ -37,6 +45,11 @@ func (i *Implementation) GetDialogUserIdList(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if len(messages) > 0 {
+		last := messages[len(messages)-1]
+		setNextCursor(w, len(messages), limit, &model.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
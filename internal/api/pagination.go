@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"otus-project/internal/model"
+	"otus-project/internal/utils"
+	"otus-project/pkg/api"
+)
+
+// nextCursorHeader заголовок, в котором клиенту возвращается курсор следующей страницы
+const nextCursorHeader = "X-Next-Cursor"
+
+// parsePage разбирает параметры keyset-пагинации и приводит лимит к допустимому диапазону
+func parsePage(cursor *api.Cursor, limit *int, defaultLimit, maxLimit int) (*model.Cursor, int, error) {
+	pageLimit := defaultLimit
+	if limit != nil && *limit > 0 {
+		pageLimit = *limit
+	}
+	if pageLimit > maxLimit {
+		pageLimit = maxLimit
+	}
+
+	if cursor == nil {
+		return nil, pageLimit, nil
+	}
+
+	decoded, err := utils.DecodeCursor(string(*cursor))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return decoded, pageLimit, nil
+}
+
+// setNextCursor выставляет курсор следующей страницы, если текущая страница заполнена полностью
+func setNextCursor(w http.ResponseWriter, count, limit int, last *model.Cursor) {
+	if count < limit || last == nil {
+		return
+	}
+
+	w.Header().Set(nextCursorHeader, utils.EncodeCursor(last))
+}
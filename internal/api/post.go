@@ -229,17 +229,13 @@ func (i *Implementation) GetPostFeed(w http.ResponseWriter, r *http.Request, par
 		return
 	}
 
-	// Convert *float32 Offset and Limit to int, defaulting to 0 if nil
-	var offset, limit int
-	if params.Offset != nil {
-		offset = int(*params.Offset)
-	}
-	if params.Limit != nil {
-		limit = int(*params.Limit)
+	cursor, limit, err := parsePage(params.Cursor, params.Limit, 10, 100)
+	if err != nil {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
 	}
 
-	//postsObj, err := i.postService.Feed(r.Context(), *userId, params.Offset, params.Limit)
-	postsObj, err := i.feedService.GetMaterializedFeed(r.Context(), *userId, offset, limit)
+	postsObj, err := i.feedService.GetMaterializedFeed(r.Context(), *userId, cursor, limit)
 	if err != nil {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
@@ -252,12 +248,15 @@ func (i *Implementation) GetPostFeed(w http.ResponseWriter, r *http.Request, par
 		posts = append(posts, post)
 	}
 
+	if len(postsObj) > 0 {
+		last := postsObj[len(postsObj)-1]
+		setNextCursor(w, len(postsObj), limit, &model.Cursor{CreatedAt: last.CreatedAt, ID: last.PostID})
+	}
+
 	w.WriteHeader(http.StatusOK)
 	response := converter.ToPostsFromService(posts)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
-	
-
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"otus-project/internal/converter"
 	"otus-project/internal/metric"
+	"otus-project/internal/model"
 	"otus-project/pkg/api"
 	"strconv"
 	"time"
@@ -16,7 +17,14 @@ func (i *Implementation) GetUserSearch(w http.ResponseWriter, r *http.Request, p
 
 	metric.IncRequestCounter()
 	timeStart := time.Now()
-	filter := converter.ToUserFilterFromApi(&params)
+	cursor, limit, err := parsePage(params.Cursor, params.Limit, 100, 300)
+	if err != nil {
+		metric.IncResponseCounter(strconv.Itoa(http.StatusBadRequest), "GetUserSearch")
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	filter := converter.ToUserFilterFromApi(&params, cursor, limit)
 
 	usersObj, err := i.userService.Search(context.Background(), filter)
 	diffTime := time.Since(timeStart)
@@ -28,6 +36,15 @@ func (i *Implementation) GetUserSearch(w http.ResponseWriter, r *http.Request, p
 		return
 	}
 
+	if len(usersObj) > 0 {
+		last := usersObj[len(usersObj)-1]
+		nextCursor := &model.Cursor{ID: *last.Id}
+		if last.CreatedAt != nil {
+			nextCursor.CreatedAt = *last.CreatedAt
+		}
+		setNextCursor(w, len(usersObj), limit, nextCursor)
+	}
+
 	w.WriteHeader(http.StatusOK)
 	response := converter.ToUsersFromService(usersObj)
 
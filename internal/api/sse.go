@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"otus-project/internal/model"
+	websocketService "otus-project/internal/service/websocket"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// sseHeartbeat комментарий SSE, который клиент игнорирует
+var sseHeartbeat = []byte(": ping\n\n")
+
+// HandleSSE отдает сообщения хаба потоком Server-Sent Events для клиентов, у которых не работает
+// WebSocket. Аутентификация, сообщения, журнал и политика медленного клиента те же, что у WebSocket.
+// Клиент не может отправлять сообщения, поэтому каналы задаются параметрами channel при подключении,
+// а ответы ack или error на подписки приходят первыми событиями
+func (h *WebSocketHandler) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	// EventSource передает номер последнего события в Last-Event-ID при переподключении,
+	// при первом подключении его можно передать параметром last_event_id
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	lastEventID, err := parseLastEventID(value)
+	if err != nil {
+		http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Прокси не должны буферизовать поток
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		log.Printf("Error starting SSE stream: %v", err)
+		return
+	}
+
+	connection := websocketService.NewConnection(uuid.New().String(), userID, h.config.SendBufferSize())
+	if err := h.service.RegisterConnection(context.Background(), connection); err != nil {
+		log.Printf("Error registering SSE connection: %v", err)
+		return
+	}
+
+	// Ждать resume от клиента не нужно: пропущенные сообщения повторяются сразу
+	h.service.Resume(context.Background(), connection, lastEventID)
+
+	for i, channel := range r.URL.Query()["channel"] {
+		frame, err := json.Marshal(&model.WebSocketMessage{
+			Type:    model.WebSocketMessageTypeSubscribe,
+			ID:      "channel-" + strconv.Itoa(i+1),
+			Payload: &model.WebSocketSubscription{Channel: channel},
+		})
+		if err != nil {
+			continue
+		}
+		h.service.HandleClientMessage(context.Background(), connection, frame)
+	}
+
+	h.streamSSE(r.Context(), connection, w, controller)
+}
+
+// streamSSE пишет сообщения соединения в поток, пока клиент не отключится или хаб не закроет соединение.
+// Каждые PingInterval отправляется комментарий: по нему прокси не закрывают простаивающее соединение,
+// а сервер замечает отключившегося клиента по ошибке записи
+func (h *WebSocketHandler) streamSSE(ctx context.Context, connection *websocketService.Connection, w http.ResponseWriter, controller *http.ResponseController) {
+	ticker := time.NewTicker(h.config.PingInterval())
+	defer func() {
+		ticker.Stop()
+		h.service.UnregisterConnection(context.Background(), connection)
+	}()
+
+	for {
+		select {
+		case message, ok := <-connection.Send:
+			if !ok {
+				return
+			}
+
+			if err := h.writeSSE(w, controller, sseEvent(message)); err != nil {
+				h.closeOnWriteError(connection, err)
+				return
+			}
+
+		case <-ticker.C:
+			if err := h.writeSSE(w, controller, sseHeartbeat); err != nil {
+				h.closeOnWriteError(connection, err)
+				return
+			}
+
+		case <-connection.Done:
+			// Кода закрытия в SSE нет, клиент увидит конец потока и переподключится
+			return
+
+		case <-ctx.Done():
+			connection.Close(websocket.CloseAbnormalClosure, model.WebSocketCloseReasonClientClosed)
+			return
+		}
+	}
+}
+
+// writeSSE записывает данные в поток и сразу отправляет их клиенту. Запись ограничена WriteTimeout
+func (h *WebSocketHandler) writeSSE(w http.ResponseWriter, controller *http.ResponseController, data []byte) error {
+	_ = controller.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout()))
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return controller.Flush()
+}
+
+// sseEvent оформляет сообщение хаба событием SSE. Сообщения журнала получают id, его EventSource вернет
+// в Last-Event-ID при переподключении. У остальных сообщений id нет, и запомненный клиентом номер не меняется
+func sseEvent(message []byte) []byte {
+	var envelope struct {
+		EventID int64 `json:"eventId"`
+	}
+	_ = json.Unmarshal(message, &envelope)
+
+	var b bytes.Buffer
+	if envelope.EventID > 0 {
+		fmt.Fprintf(&b, "id: %d\n", envelope.EventID)
+	}
+	b.WriteString("data: ")
+	b.Write(message)
+	b.WriteString("\n\n")
+
+	return b.Bytes()
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"otus-project/internal/config"
 	"otus-project/internal/model"
+	websocketService "otus-project/internal/service/websocket"
 	"otus-project/internal/utils"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -19,18 +26,58 @@ var upgrader = websocket.Upgrader{
 
 // WebSocketHandler обрабатывает WebSocket соединения
 type WebSocketHandler struct {
-	hub *model.WebSocketHub
+	service websocketService.WebSocketService
+	config  config.WebSocketConfig
 }
 
 // NewWebSocketHandler создает новый WebSocket обработчик
-func NewWebSocketHandler(hub *model.WebSocketHub) *WebSocketHandler {
+func NewWebSocketHandler(service websocketService.WebSocketService, cfg config.WebSocketConfig) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub: hub,
+		service: service,
+		config:  cfg,
 	}
 }
 
 // HandleWebSocket обрабатывает WebSocket соединение для канала /post/feed/posted
 func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	// Номер последнего полученного сообщения при переподключении, необязательный
+	lastEventID, err := parseLastEventID(r.URL.Query().Get("last_event_id"))
+	if err != nil {
+		http.Error(w, "Invalid last_event_id", http.StatusBadRequest)
+		return
+	}
+
+	// Обновляем соединение до WebSocket
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading connection to WebSocket: %v", err)
+		return
+	}
+
+	// Создаем WebSocket соединение
+	wsConnection := websocketService.NewConnection(uuid.New().String(), userID, h.config.SendBufferSize())
+
+	// Регистрируем соединение: события ленты пользователя начинают приходить на этот узел
+	if err := h.service.RegisterConnection(context.Background(), wsConnection); err != nil {
+		log.Printf("Error registering WebSocket connection: %v", err)
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(h.config.WriteTimeout()))
+		conn.Close()
+		return
+	}
+
+	// Запускаем горутины для чтения и записи
+	go h.writePump(wsConnection, conn)
+	go h.readPump(wsConnection, conn, lastEventID)
+}
+
+// authenticate проверяет JWT токен из заголовка Authorization или параметра token и возвращает userID.
+// Если токена нет или он невалиден, клиенту уже отправлен ответ 401
+func authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
 	var token string
 
 	// Сначала пытаемся получить токен из заголовка Authorization
@@ -45,94 +92,145 @@ func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 	// Проверяем, что токен получен
 	if token == "" {
 		http.Error(w, "Token required (Authorization header or URL parameter)", http.StatusUnauthorized)
-		return
+		return "", false
 	}
 
 	// Валидируем токен и получаем userID
 	claims, err := utils.VerifyToken(token)
 	if err != nil {
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
+		return "", false
 	}
 
-	userID := claims.UserId
+	return claims.UserId, true
+}
 
-	// Обновляем соединение до WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("Error upgrading connection to WebSocket: %v", err)
-		return
+// parseLastEventID разбирает номер последнего полученного сообщения. Пустое значение - nil
+func parseLastEventID(value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
 	}
 
-	// Создаем WebSocket соединение
-	wsConnection := &model.WebSocketConnection{
-		ID:     userID, // Используем userID как ID соединения
-		UserID: userID,
-		Send:   make(chan []byte, 256),
-		Hub:    h.hub,
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if id < 0 {
+		return nil, errors.New("last event id must not be negative")
 	}
 
-	// Регистрируем соединение в хабе
-	h.hub.Register <- wsConnection
-
-	// Запускаем горутины для чтения и записи
-	go h.writePump(wsConnection, conn)
-	go h.readPump(wsConnection, conn)
+	return &id, nil
 }
 
-// writePump отправляет сообщения клиенту
-func (h *WebSocketHandler) writePump(connection *model.WebSocketConnection, conn *websocket.Conn) {
+// writePump отправляет сообщения и ping клиенту. Каждая запись ограничена WriteTimeout,
+// поэтому клиент, переставший читать, не блокирует горутину
+func (h *WebSocketHandler) writePump(connection *websocketService.Connection, conn *websocket.Conn) {
+	ticker := time.NewTicker(h.config.PingInterval())
 	defer func() {
+		ticker.Stop()
 		conn.Close()
-		h.hub.Unregister <- connection
+		h.service.UnregisterConnection(context.Background(), connection)
 	}()
 
 	for {
 		select {
 		case message, ok := <-connection.Send:
 			if !ok {
-				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				_ = conn.WriteControl(websocket.CloseMessage, []byte{}, time.Now().Add(h.config.WriteTimeout()))
 				return
 			}
 
-			w, err := conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+			_ = conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout()))
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				h.closeOnWriteError(connection, err)
 				return
 			}
 
-			w.Write(message)
-
-			if err := w.Close(); err != nil {
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.config.WriteTimeout())); err != nil {
+				h.closeOnWriteError(connection, err)
 				return
 			}
+
+		case <-connection.Done:
+			code, reason := connection.CloseStatus()
+			if code != websocket.CloseAbnormalClosure {
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(h.config.WriteTimeout()))
+			}
+			return
 		}
 	}
 }
 
-// readPump читает сообщения от клиента
-func (h *WebSocketHandler) readPump(connection *model.WebSocketConnection, conn *websocket.Conn) {
+// readPump читает сообщения от клиента. Соединение закрывается, если от клиента не пришло
+// ни pong, ни другого сообщения за PongTimeout или сообщение больше MaxMessageSize.
+// Сетевое соединение закрывает writePump после отправки close frame.
+// Если lastEventID передан при подключении, пропущенные сообщения повторяются сразу, иначе
+// сервер ждет ResumeWait первое сообщение клиента, которым может быть resume
+func (h *WebSocketHandler) readPump(connection *websocketService.Connection, conn *websocket.Conn, lastEventID *int64) {
+	var resumeTimer *time.Timer
+	if lastEventID != nil {
+		h.service.Resume(context.Background(), connection, lastEventID)
+	} else {
+		resumeTimer = time.AfterFunc(h.config.ResumeWait(), func() {
+			h.service.Resume(context.Background(), connection, nil)
+		})
+	}
+
 	defer func() {
-		conn.Close()
-		h.hub.Unregister <- connection
+		if resumeTimer != nil {
+			resumeTimer.Stop()
+		}
+		h.service.UnregisterConnection(context.Background(), connection)
 	}()
 
+	conn.SetReadLimit(h.config.MaxMessageSize())
+	_ = conn.SetReadDeadline(time.Now().Add(h.config.PongTimeout()))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(h.config.PongTimeout()))
+	})
+
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
+			h.closeOnReadError(connection, err)
 			break
 		}
+		_ = conn.SetReadDeadline(time.Now().Add(h.config.PongTimeout()))
 
-		// Обрабатываем входящие сообщения (если нужно)
-		var wsMessage model.WebSocketMessage
-		if err := json.Unmarshal(message, &wsMessage); err != nil {
-			log.Printf("Error unmarshaling WebSocket message: %v", err)
-			continue
-		}
+		h.service.HandleClientMessage(context.Background(), connection, message)
+	}
+}
+
+// closeOnReadError закрывает соединение после ошибки чтения
+func (h *WebSocketHandler) closeOnReadError(connection *websocketService.Connection, err error) {
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+
+	switch {
+	// В этих двух случаях close frame библиотека уже отправила сама
+	case errors.As(err, &closeErr):
+		connection.Close(websocket.CloseAbnormalClosure, model.WebSocketCloseReasonClientClosed)
+	case errors.Is(err, websocket.ErrReadLimit):
+		connection.Close(websocket.CloseAbnormalClosure, model.WebSocketCloseReasonMessageTooLarge)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		connection.Close(websocket.CloseGoingAway, model.WebSocketCloseReasonPongTimeout)
+	default:
+		connection.Close(websocket.CloseAbnormalClosure, model.WebSocketCloseReasonReadError)
+	}
+}
 
-		// Здесь можно добавить обработку различных типов сообщений
-		log.Printf("Received message from user %s: %s", connection.UserID, wsMessage.Type)
+// closeOnWriteError закрывает соединение после ошибки записи. Close frame не отправляется:
+// запись в соединение уже не удалась
+func (h *WebSocketHandler) closeOnWriteError(connection *websocketService.Connection, err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		connection.Close(websocket.CloseAbnormalClosure, model.WebSocketCloseReasonWriteTimeout)
+		return
 	}
+
+	connection.Close(websocket.CloseAbnormalClosure, model.WebSocketCloseReasonWriteError)
 }
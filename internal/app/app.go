@@ -2,22 +2,30 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	internalApi "otus-project/internal/api"
+	"otus-project/internal/client/queue"
 	"otus-project/internal/closer"
 	"otus-project/internal/config"
 
 	"otus-project/internal/metric"
 	"otus-project/internal/model"
+	eventBusService "otus-project/internal/service/event_bus"
 	feedHandler "otus-project/internal/service/feed"
-	websocketHandler "otus-project/internal/service/websocket"
+	websocketService "otus-project/internal/service/websocket"
 	"otus-project/pkg/api"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// healthCheckTimeout время на проверку зависимостей в /healthz
+const healthCheckTimeout = 2 * time.Second
+
 // App структура приложения
 type App struct {
 	serviceProvider  *serviceProvider
@@ -45,7 +53,14 @@ func (a *App) Run() error {
 	defer func() {
 		// Останавливаем WebSocket сервис
 		if a.serviceProvider != nil {
-			a.serviceProvider.WebSocketService().StopHub(context.Background())
+			a.serviceProvider.WebSocketService(context.Background()).StopHub(context.Background())
+		}
+		// Останавливаем relay outbox и дожидаемся обработчиков уже принятых событий
+		if a.serviceProvider != nil {
+			a.serviceProvider.OutboxRelay(context.Background()).Stop(context.Background())
+			if err := a.serviceProvider.EventBus().Stop(context.Background()); err != nil {
+				log.Printf("Error stopping event bus: %v", err)
+			}
 		}
 		// Останавливаем воркер материализации ленты
 		if a.feedWorker != nil {
@@ -90,6 +105,7 @@ func (a *App) initDeps(ctx context.Context) error {
 		a.initMetrics,
 		a.initServiceProvider,
 		a.initWebSocket,
+		a.initOutbox,
 		a.initWebSocketServer,
 		a.initHTTPServer,
 		a.initPrometheus,
@@ -138,13 +154,13 @@ func (a *App) initServiceProvider(_ context.Context) error {
 // initWebSocket инициализирует WebSocket
 func (a *App) initWebSocket(ctx context.Context) error {
 	// Запускаем WebSocket хаб
-	err := a.serviceProvider.WebSocketService().StartHub(ctx)
+	err := a.serviceProvider.WebSocketService(ctx).StartHub(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Создаем WebSocket обработчик
-	a.websocketHandler = internalApi.NewWebSocketHandler(a.serviceProvider.WebSocketService().GetHub())
+	a.websocketHandler = internalApi.NewWebSocketHandler(a.serviceProvider.WebSocketService(ctx), a.serviceProvider.WebSocketConfig())
 
 	// Создаем воркер материализации ленты
 	a.feedWorker = NewFeedWorkerAdapter(a.serviceProvider.FeedService(ctx))
@@ -152,22 +168,43 @@ func (a *App) initWebSocket(ctx context.Context) error {
 	// Подписываем обработчики на события
 	eventBus := a.serviceProvider.EventBus()
 
-	// WebSocket обработчик
-	wsEventHandler := websocketHandler.NewEventHandler(a.serviceProvider.WebSocketService())
-	eventBus.Subscribe(model.EventTypePostCreated, wsEventHandler.HandlePostCreated)
-
-	// Feed обработчик
+	// Feed обработчик. Новые посты попадают в WebSocket только через раскладку: получатели - друзья
+	// автора, события для них публикуются по routing key feed.event.{user_id}
 	feedEventHandler := feedHandler.NewEventHandler(a.serviceProvider.FeedService(ctx))
-	eventBus.Subscribe(model.EventTypePostCreated, feedEventHandler.HandlePostCreated)
-
-	// Потребляем feed events из RabbitMQ и отправляем в конкретные WebSocket-соединения
-	if err := a.serviceProvider.QueueClient().ConsumeFeedEvents(ctx, func(ctx context.Context, userID string, ev *model.FeedEvent) error {
-		wsPost := &model.WebSocketPost{
-			PostID:       ev.PostID,
-			PostText:     ev.PostText,
-			AuthorUserID: ev.AuthorUserID,
+	// События диалогов публикуются участникам по routing key feed.message.{user_id}
+	websocketEventHandler := websocketService.NewEventHandler(a.serviceProvider.QueueClient())
+	subscriptions := []error{
+		eventBusService.Subscribe(eventBus, eventBusService.PostCreated, "feed", feedEventHandler.HandlePostCreated),
+		eventBusService.Subscribe(eventBus, eventBusService.PostUpdated, "feed", feedEventHandler.HandlePostUpdated),
+		eventBusService.Subscribe(eventBus, eventBusService.PostDeleted, "feed", feedEventHandler.HandlePostDeleted),
+		eventBusService.Subscribe(eventBus, eventBusService.FriendAdded, "feed", feedEventHandler.HandleFriendAdded),
+		eventBusService.Subscribe(eventBus, eventBusService.FriendRemoved, "feed", feedEventHandler.HandleFriendRemoved),
+		eventBusService.Subscribe(eventBus, eventBusService.DialogMessageSent, "websocket", websocketEventHandler.HandleDialogMessageSent),
+		eventBusService.Subscribe(eventBus, eventBusService.DialogMessagesRead, "websocket", websocketEventHandler.HandleDialogMessagesRead),
+	}
+	for _, err := range subscriptions {
+		if err != nil {
+			return err
 		}
-		return a.serviceProvider.WebSocketService().SendPostToUser(ctx, userID, wsPost)
+	}
+
+	// Потребляем события ленты и сообщения пользователей узла из RabbitMQ и отправляем в их WebSocket-соединения
+	err = a.serviceProvider.QueueClient().ConsumeUserEvents(ctx, queue.UserEventHandlers{
+		FeedEvent: func(ctx context.Context, userID string, ev *model.FeedEvent) error {
+			// Номер события в журнале получателя уходит клиенту в eventId для повтора после переподключения
+			return a.serviceProvider.WebSocketService(ctx).SendMessageToUser(ctx, userID, model.NewFeedWebSocketMessage(ev))
+		},
+		Message: func(ctx context.Context, userID string, message *model.WebSocketMessage) error {
+			return a.serviceProvider.WebSocketService(ctx).SendMessageToUser(ctx, userID, message)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Объявления администратора получает каждый узел и рассылает соединениям, подписанным на announcements
+	if err := a.serviceProvider.QueueClient().ConsumeAnnouncements(ctx, func(ctx context.Context, announcement *model.Announcement) error {
+		return a.serviceProvider.WebSocketService(ctx).BroadcastAnnouncement(ctx, announcement)
 	}); err != nil {
 		return err
 	}
@@ -175,6 +212,38 @@ func (a *App) initWebSocket(ctx context.Context) error {
 	return nil
 }
 
+// initOutbox запускает Event Bus, relay outbox и передает доменные события из RabbitMQ подписчикам.
+// Сервисы пишут события в outbox в транзакции изменения данных, relay публикует их в брокер
+func (a *App) initOutbox(ctx context.Context) error {
+	eventBus := a.serviceProvider.EventBus()
+	if err := eventBus.Start(ctx); err != nil {
+		return err
+	}
+
+	err := a.serviceProvider.QueueClient().ConsumeDomainEvents(ctx, func(ctx context.Context, eventType string, body []byte) error {
+		event, err := model.DecodeEvent(eventType, body)
+		if err != nil {
+			// Повторная доставка не исправит тело события
+			log.Printf("Skipping domain event %s: %v", eventType, err)
+			return nil
+		}
+
+		if err = eventBus.Dispatch(ctx, event); err != nil {
+			if !eventBusService.IsRetryable(err) {
+				log.Printf("Dropping domain event %s (ID: %s): %v", event.Type, event.ID, err)
+				return nil
+			}
+			return fmt.Errorf("failed to handle %s: %w", event.Type, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.serviceProvider.OutboxRelay(ctx).Start(ctx)
+}
+
 // initWebSocketServer инициализирует WebSocket сервер
 func (a *App) initWebSocketServer(ctx context.Context) error {
 	// Создаем мультиплексор для WebSocket сервера
@@ -182,6 +251,8 @@ func (a *App) initWebSocketServer(ctx context.Context) error {
 
 	// Добавляем WebSocket маршрут для канала /post/feed/posted
 	mux.HandleFunc("/post/feed/posted", a.websocketHandler.HandleWebSocket)
+	// Те же сообщения потоком Server-Sent Events для клиентов за прокси без поддержки WebSocket
+	mux.HandleFunc("/post/feed/events", a.websocketHandler.HandleSSE)
 
 	a.websocketServer = &http.Server{
 		Handler: mux,
@@ -222,6 +293,7 @@ func (a *App) initPrometheus(_ context.Context) error {
 	mux := http.NewServeMux()
 
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", a.handleHealth)
 
 	a.prometheusServer = &http.Server{
 		Addr:    "localhost:2112",
@@ -230,6 +302,36 @@ func (a *App) initPrometheus(_ context.Context) error {
 	return nil
 }
 
+// handleHealth проверяет соединения с PostgreSQL и RabbitMQ и отвечает 503, если одно из них недоступно
+func (a *App) handleHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]error{
+		"postgres": a.serviceProvider.DBClient(ctx).DB().Ping(ctx),
+	}
+	if checker, ok := a.serviceProvider.QueueClient().(queue.HealthChecker); ok {
+		checks["rabbitmq"] = checker.Healthy(ctx)
+	}
+
+	status := http.StatusOK
+	result := make(map[string]string, len(checks))
+	for name, err := range checks {
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			result[name] = err.Error()
+			continue
+		}
+		result[name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error writing health response: %v", err)
+	}
+}
+
 // runHTTPServer запускает HTTP сервер
 func (a *App) runHTTPServer() error {
 	log.Printf("HTTP server is running on %s", a.serviceProvider.HTTPConfig().Address())
@@ -11,22 +11,28 @@ import (
 	"otus-project/internal/client/db/pg"
 	"otus-project/internal/client/db/transaction"
 	"otus-project/internal/client/queue"
+	"otus-project/internal/client/queue/memory"
 	"otus-project/internal/client/queue/rabbitmq"
 	"otus-project/internal/closer"
 	"otus-project/internal/config"
 	"otus-project/internal/repository"
+	activityRepo "otus-project/internal/repository/activity/redis"
+	connectionRepo "otus-project/internal/repository/connection/redis"
 	dialogRepo "otus-project/internal/repository/dialog"
+	eventLogRepo "otus-project/internal/repository/eventlog/redis"
 	feedRepo "otus-project/internal/repository/feed"
 	feedPgRepo "otus-project/internal/repository/feed/pg"
+	feedRedisRepo "otus-project/internal/repository/feed/redis"
 	friendRepo "otus-project/internal/repository/friend"
+	outboxPgRepo "otus-project/internal/repository/outbox/pg"
 	postPgRepo "otus-project/internal/repository/post/pg"
-	postRRepo "otus-project/internal/repository/post/redis"
 	userRepository "otus-project/internal/repository/user"
 	"otus-project/internal/service"
 	dialogService "otus-project/internal/service/dialog"
 	eventBusService "otus-project/internal/service/event_bus"
 	feedService "otus-project/internal/service/feed"
 	friendService "otus-project/internal/service/friend"
+	outboxService "otus-project/internal/service/outbox"
 	postService "otus-project/internal/service/post"
 	userService "otus-project/internal/service/user"
 	websocketService "otus-project/internal/service/websocket"
@@ -39,6 +45,9 @@ type serviceProvider struct {
 	httpConfig      config.HTTPConfig
 	websocketConfig config.WebSocketConfig
 	redisConfig     config.RedisConfig
+	feedConfig      config.FeedConfig
+	outboxConfig    config.OutboxConfig
+	eventBusConfig  config.EventBusConfig
 
 	dbClient  db.Client
 	txManager db.TxManager
@@ -48,9 +57,13 @@ type serviceProvider struct {
 
 	userRepository      repository.UserRepository
 	postPgRepository    repository.PostRepository
-	postRedisRepository repository.PostRepository
 	friendRepository    repository.FriendRepository
 	dialogRepository    repository.DialogRepository
+	activityRepository  repository.ActivityRepository
+	eventLogRepository  repository.EventLogRepository
+	connectionRegistry  repository.ConnectionRegistryRepository
+	outboxRepository    repository.OutboxRepository
+	feedCacheRepository feedRepo.CacheRepository
 
 	userService      service.UserService
 	postService      service.PostService
@@ -60,6 +73,7 @@ type serviceProvider struct {
 	feedService      feedService.Service
 	queueClient      queue.Client
 	eventBus         eventBusService.EventBus
+	outboxRelay      outboxService.Relay
 
 	apiImpl *api.Implementation
 }
@@ -125,6 +139,48 @@ func (s *serviceProvider) RedisConfig() config.RedisConfig {
 	return s.redisConfig
 }
 
+// FeedConfig возвращает конфиг ленты
+func (s *serviceProvider) FeedConfig() config.FeedConfig {
+	if s.feedConfig == nil {
+		cfg, err := config.NewFeedConfig()
+		if err != nil {
+			log.Fatalf("failed to get feed config: %s", err.Error())
+		}
+
+		s.feedConfig = cfg
+	}
+
+	return s.feedConfig
+}
+
+// OutboxConfig возвращает конфиг outbox
+func (s *serviceProvider) OutboxConfig() config.OutboxConfig {
+	if s.outboxConfig == nil {
+		cfg, err := config.NewOutboxConfig()
+		if err != nil {
+			log.Fatalf("failed to get outbox config: %s", err.Error())
+		}
+
+		s.outboxConfig = cfg
+	}
+
+	return s.outboxConfig
+}
+
+// EventBusConfig возвращает конфиг Event Bus
+func (s *serviceProvider) EventBusConfig() config.EventBusConfig {
+	if s.eventBusConfig == nil {
+		cfg, err := config.NewEventBusConfig()
+		if err != nil {
+			log.Fatalf("failed to get event bus config: %s", err.Error())
+		}
+
+		s.eventBusConfig = cfg
+	}
+
+	return s.eventBusConfig
+}
+
 // RedisPool возвращает пул соединений к redis
 func (s *serviceProvider) RedisPool() *redigo.Pool {
 	if s.redisPool == nil {
@@ -196,13 +252,44 @@ func (s *serviceProvider) PostRepository(ctx context.Context) repository.PostRep
 	return s.postPgRepository
 }
 
-// PostRedisRepository возвращает репозиторий Post
-func (s *serviceProvider) PostRedisRepository(ctx context.Context) repository.PostRepository {
-	if s.postRedisRepository == nil {
-		s.postRedisRepository = postRRepo.NewRepository(s.RedisClient())
+// ActivityRepository возвращает репозиторий активности пользователей
+func (s *serviceProvider) ActivityRepository() repository.ActivityRepository {
+	if s.activityRepository == nil {
+		s.activityRepository = activityRepo.NewRepository(s.RedisClient())
 	}
 
-	return s.postRedisRepository
+	return s.activityRepository
+}
+
+// EventLogRepository возвращает журнал WebSocket сообщений для повтора после переподключения
+func (s *serviceProvider) EventLogRepository() repository.EventLogRepository {
+	if s.eventLogRepository == nil {
+		s.eventLogRepository = eventLogRepo.NewRepository(
+			s.RedisClient(),
+			s.WebSocketConfig().EventLogSize(),
+			s.WebSocketConfig().EventLogTTL(),
+		)
+	}
+
+	return s.eventLogRepository
+}
+
+// ConnectionRegistry возвращает реестр WebSocket соединений по узлам
+func (s *serviceProvider) ConnectionRegistry() repository.ConnectionRegistryRepository {
+	if s.connectionRegistry == nil {
+		s.connectionRegistry = connectionRepo.NewRepository(s.RedisClient())
+	}
+
+	return s.connectionRegistry
+}
+
+// OutboxRepository возвращает репозиторий outbox
+func (s *serviceProvider) OutboxRepository(ctx context.Context) repository.OutboxRepository {
+	if s.outboxRepository == nil {
+		s.outboxRepository = outboxPgRepo.NewRepository(s.DBClient(ctx))
+	}
+
+	return s.outboxRepository
 }
 
 // FriendRepository возвращает репозиторий Post
@@ -228,6 +315,7 @@ func (s *serviceProvider) UserService(ctx context.Context) service.UserService {
 	if s.userService == nil {
 		s.userService = userService.NewService(
 			s.UserRepository(ctx),
+			s.ActivityRepository(),
 			s.TxManager(ctx),
 		)
 	}
@@ -240,9 +328,8 @@ func (s *serviceProvider) PostService(ctx context.Context) service.PostService {
 	if s.postService == nil {
 		s.postService = postService.NewService(
 			s.PostRepository(ctx),
-			s.PostRedisRepository(ctx),
+			s.OutboxRepository(ctx),
 			s.TxManager(ctx),
-			s.EventBus(),
 		)
 	}
 
@@ -254,6 +341,7 @@ func (s *serviceProvider) FriendService(ctx context.Context) service.FriendServi
 	if s.friendService == nil {
 		s.friendService = friendService.NewService(
 			s.FriendRepository(ctx),
+			s.OutboxRepository(ctx),
 			s.TxManager(ctx),
 		)
 	}
@@ -264,16 +352,32 @@ func (s *serviceProvider) FriendService(ctx context.Context) service.FriendServi
 // DialogService возвращает сервис диалогов
 func (s *serviceProvider) DialogService(ctx context.Context) service.DialogService {
 	if s.dialogService == nil {
-		s.dialogService = dialogService.NewImplementation(s.DialogRepository(ctx))
+		s.dialogService = dialogService.NewImplementation(
+			s.DialogRepository(ctx),
+			s.OutboxRepository(ctx),
+			s.TxManager(ctx),
+		)
 	}
 
 	return s.dialogService
 }
 
 // WebSocketService возвращает WebSocket сервис
-func (s *serviceProvider) WebSocketService() websocketService.WebSocketService {
+func (s *serviceProvider) WebSocketService(ctx context.Context) websocketService.WebSocketService {
 	if s.websocketService == nil {
-		s.websocketService = websocketService.NewService()
+		wsService, err := websocketService.NewService(
+			s.WebSocketConfig(),
+			s.ActivityRepository(),
+			s.EventLogRepository(),
+			s.ConnectionRegistry(),
+			s.DialogService(ctx),
+			s.QueueClient(),
+		)
+		if err != nil {
+			log.Fatalf("failed to create websocket service: %s", err.Error())
+		}
+
+		s.websocketService = wsService
 	}
 
 	return s.websocketService
@@ -282,18 +386,29 @@ func (s *serviceProvider) WebSocketService() websocketService.WebSocketService {
 // QueueClient возвращает клиент очереди сообщений
 func (s *serviceProvider) QueueClient() queue.Client {
 	if s.queueClient == nil {
+		queueCfg, err := config.NewQueueConfig()
+		if err != nil {
+			log.Fatalf("failed to get queue config: %s", err.Error())
+		}
+
 		cfg, err := config.NewRabbitMQConfig()
 		if err != nil {
 			log.Fatalf("failed to get rabbitmq config: %s", err.Error())
 		}
 
-		client, err := rabbitmq.NewClient(cfg)
-		if err != nil {
-			log.Fatalf("failed to create queue client: %s", err.Error())
+		if queueCfg.Driver() == config.QueueDriverMemory {
+			// Настройки повторов задач общие для обеих реализаций
+			s.queueClient = memory.NewClient(cfg)
+			log.Printf("Using in-memory queue, messages are not persisted")
+		} else {
+			client, err := rabbitmq.NewClient(cfg)
+			if err != nil {
+				log.Fatalf("failed to create queue client: %s", err.Error())
+			}
+			s.queueClient = client
 		}
 
-		s.queueClient = client
-		closer.Add(client.Close)
+		closer.Add(s.queueClient.Close)
 	}
 
 	return s.queueClient
@@ -304,19 +419,53 @@ func (s *serviceProvider) FeedRepository(ctx context.Context) feedRepo.Repositor
 	return feedPgRepo.NewRepository(s.DBClient(ctx))
 }
 
+// FeedCacheRepository возвращает кэш материализованной ленты в Redis
+func (s *serviceProvider) FeedCacheRepository() feedRepo.CacheRepository {
+	if s.feedCacheRepository == nil {
+		s.feedCacheRepository = feedRedisRepo.NewRepository(
+			s.RedisClient(),
+			s.FeedConfig().CacheMaxLength(),
+			s.FeedConfig().CacheTTL(),
+		)
+	}
+
+	return s.feedCacheRepository
+}
+
 // EventBus возвращает Event Bus
 func (s *serviceProvider) EventBus() eventBusService.EventBus {
 	if s.eventBus == nil {
-		s.eventBus = eventBusService.NewService()
+		s.eventBus = eventBusService.NewService(s.EventBusConfig())
 	}
 
 	return s.eventBus
 }
 
+// OutboxRelay возвращает relay событий outbox в RabbitMQ
+func (s *serviceProvider) OutboxRelay(ctx context.Context) outboxService.Relay {
+	if s.outboxRelay == nil {
+		s.outboxRelay = outboxService.NewRelay(
+			s.OutboxRepository(ctx),
+			s.QueueClient(),
+			s.OutboxConfig(),
+		)
+	}
+
+	return s.outboxRelay
+}
+
 // FeedService возвращает сервис отложенной материализации ленты
 func (s *serviceProvider) FeedService(ctx context.Context) feedService.Service {
 	if s.feedService == nil {
-		s.feedService = feedService.NewService(s.FeedRepository(ctx), s.QueueClient())
+		s.feedService = feedService.NewService(
+			s.FeedRepository(ctx),
+			s.FeedCacheRepository(),
+			s.ActivityRepository(),
+			s.EventLogRepository(),
+			s.ConnectionRegistry(),
+			s.QueueClient(),
+			s.FeedConfig(),
+		)
 	}
 
 	return s.feedService
@@ -325,7 +474,7 @@ func (s *serviceProvider) FeedService(ctx context.Context) feedService.Service {
 // ApiImpl возвращает реализацию сервиса User
 func (s *serviceProvider) ApiImpl(ctx context.Context) *api.Implementation {
 	if s.apiImpl == nil {
-		s.apiImpl = api.NewImplementation(s.UserService(ctx), s.PostService(ctx), s.FriendService(ctx), s.DialogService(ctx))
+		s.apiImpl = api.NewImplementation(s.UserService(ctx), s.PostService(ctx), s.FriendService(ctx), s.DialogService(ctx), s.FeedService(ctx))
 	}
 
 	return s.apiImpl
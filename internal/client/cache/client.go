@@ -5,6 +5,20 @@ import (
 	"time"
 )
 
+// ZMember элемент отсортированного множества
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAppendEntry запись для ZAppend: значение добавляется в отсортированное множество Key
+// под следующим номером из счетчика SeqKey
+type ZAppendEntry struct {
+	SeqKey string
+	Key    string
+	Value  string
+}
+
 type RedisClient interface {
 	HashSet(ctx context.Context, key string, values interface{}, ttl time.Duration) error
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
@@ -12,4 +26,40 @@ type RedisClient interface {
 	Get(ctx context.Context, key string) (interface{}, error)
 	Expire(ctx context.Context, key string, expiration time.Duration) error
 	Ping(ctx context.Context) error
+
+	// HSet записывает поля хэша и обновляет время жизни ключа
+	HSet(ctx context.Context, key string, values interface{}, ttl time.Duration) error
+	// HSetIfExists обновляет поле хэша, только если ключ уже существует
+	HSetIfExists(ctx context.Context, key, field string, value interface{}) (bool, error)
+	// HGetAllMulti получает содержимое нескольких хэшей за один проход (pipeline)
+	HGetAllMulti(ctx context.Context, keys []string) ([][]interface{}, error)
+	// MGet получает значения нескольких ключей, для отсутствующих ключей возвращается nil
+	MGet(ctx context.Context, keys ...string) ([]interface{}, error)
+	// Exists проверяет наличие ключа
+	Exists(ctx context.Context, key string) (bool, error)
+	// Del удаляет ключи
+	Del(ctx context.Context, keys ...string) error
+	// HDel удаляет поля хэша
+	HDel(ctx context.Context, key string, fields ...string) error
+
+	// ZAdd добавляет элементы в отсортированное множество
+	ZAdd(ctx context.Context, key string, members ...ZMember) error
+	// ZRem удаляет элементы из отсортированного множества
+	ZRem(ctx context.Context, key string, members ...string) error
+	// ZRevRangeByScore возвращает элементы со счетом не больше max в порядке убывания счета
+	ZRevRangeByScore(ctx context.Context, key, max string, offset, count int) ([]ZMember, error)
+	// ZRemRangeByRank удаляет элементы в диапазоне рангов [start, stop]
+	ZRemRangeByRank(ctx context.Context, key string, start, stop int) error
+	// ZCard возвращает количество элементов отсортированного множества
+	ZCard(ctx context.Context, key string) (int, error)
+	// ZRangeByScore возвращает элементы со счетом в диапазоне [min, max] в порядке возрастания счета
+	ZRangeByScore(ctx context.Context, key, min, max string, offset, count int) ([]ZMember, error)
+	// ZAppend атомарно для каждой записи увеличивает счетчик и добавляет элемент "<номер>:<значение>"
+	// со счетом, равным номеру. В множестве остаются maxLen последних элементов, счетчик и множество
+	// живут ttl после последней записи. Записи отправляются одним проходом (pipeline), возвращаются номера
+	ZAppend(ctx context.Context, entries []ZAppendEntry, maxLen int, ttl time.Duration) ([]int64, error)
+	// ZMergeStore атомарно собирает во временном ключе tmpKey переданные элементы вместе с текущими
+	// элементами key, оставляет maxLen элементов с наибольшим счетом и переименовывает tmpKey в key.
+	// Множество живет ttl
+	ZMergeStore(ctx context.Context, key, tmpKey string, members []ZMember, maxLen int, ttl time.Duration) error
 }
@@ -6,11 +6,53 @@ import (
 	"log"
 	"otus-project/internal/client/cache"
 	"otus-project/internal/config"
+	"strconv"
 	"time"
 )
 
 var _ cache.RedisClient = (*client)(nil)
 
+// hSetIfExistsScript атомарно обновляет поле хэша, не создавая ключ заново
+var hSetIfExistsScript = redis.NewScript(1, `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// zAppendScript добавляет элемент в отсортированное множество под следующим номером счетчика
+var zAppendScript = redis.NewScript(2, `
+local seq = redis.call('INCR', KEYS[1])
+redis.call('ZADD', KEYS[2], seq, seq .. ':' .. ARGV[1])
+redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -tonumber(ARGV[2]) - 1)
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+return seq
+`)
+
+// zMergeStoreScript собирает отсортированное множество во временном ключе и переименовывает его
+// в основной. Элементы основного ключа сохраняются: их могли добавить после того,
+// как вызывающий прочитал переданные элементы
+var zMergeStoreScript = redis.NewScript(2, `
+redis.call('DEL', KEYS[2])
+for i = 3, #ARGV, 2 do
+	redis.call('ZADD', KEYS[2], ARGV[i], ARGV[i + 1])
+end
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('ZUNIONSTORE', KEYS[2], 2, KEYS[2], KEYS[1], 'AGGREGATE', 'MAX')
+end
+if tonumber(ARGV[1]) > 0 then
+	redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -tonumber(ARGV[1]) - 1)
+end
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	return 0
+end
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+redis.call('RENAME', KEYS[2], KEYS[1])
+return 1
+`)
+
 type handler func(ctx context.Context, conn redis.Conn) error
 
 type client struct {
@@ -127,6 +169,346 @@ func (c *client) Ping(ctx context.Context) error {
 	return nil
 }
 
+func (c *client) HSet(ctx context.Context, key string, values interface{}, ttl time.Duration) error {
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		if err := conn.Send("MULTI"); err != nil {
+			return err
+		}
+		if err := conn.Send("HSET", redis.Args{key}.AddFlat(values)...); err != nil {
+			return err
+		}
+		if err := conn.Send("EXPIRE", key, int64(ttl.Seconds())); err != nil {
+			return err
+		}
+		_, err := conn.Do("EXEC")
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) HSetIfExists(ctx context.Context, key, field string, value interface{}) (bool, error) {
+	var updated bool
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		var errEx error
+		updated, errEx = redis.Bool(hSetIfExistsScript.Do(conn, key, field, value))
+		if errEx != nil {
+			return errEx
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return updated, nil
+}
+
+func (c *client) HGetAllMulti(ctx context.Context, keys []string) ([][]interface{}, error) {
+	result := make([][]interface{}, 0, len(keys))
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		for _, key := range keys {
+			if err := conn.Send("HGETALL", key); err != nil {
+				return err
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+
+		for range keys {
+			values, err := redis.Values(conn.Receive())
+			if err != nil {
+				return err
+			}
+			result = append(result, values)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *client) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	var values []interface{}
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		var errEx error
+		values, errEx = redis.Values(conn.Do("MGET", redis.Args{}.AddFlat(keys)...))
+		if errEx != nil {
+			return errEx
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (c *client) Exists(ctx context.Context, key string) (bool, error) {
+	var exists bool
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		var errEx error
+		exists, errEx = redis.Bool(conn.Do("EXISTS", key))
+		if errEx != nil {
+			return errEx
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (c *client) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		_, err := conn.Do("DEL", redis.Args{}.AddFlat(keys)...)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) HDel(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		_, err := conn.Do("HDEL", redis.Args{key}.AddFlat(fields)...)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) ZAdd(ctx context.Context, key string, members ...cache.ZMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		args := redis.Args{key}
+		for _, m := range members {
+			args = args.Add(m.Score, m.Member)
+		}
+		_, err := conn.Do("ZADD", args...)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) ZRem(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		_, err := conn.Do("ZREM", redis.Args{key}.AddFlat(members)...)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) ZRevRangeByScore(ctx context.Context, key, max string, offset, count int) ([]cache.ZMember, error) {
+	var members []cache.ZMember
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		values, errEx := redis.Strings(conn.Do("ZREVRANGEBYSCORE", key, max, "-inf", "WITHSCORES", "LIMIT", offset, count))
+		if errEx != nil {
+			return errEx
+		}
+
+		members = make([]cache.ZMember, 0, len(values)/2)
+		for i := 0; i+1 < len(values); i += 2 {
+			score, errEx := strconv.ParseFloat(values[i+1], 64)
+			if errEx != nil {
+				return errEx
+			}
+			members = append(members, cache.ZMember{Member: values[i], Score: score})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (c *client) ZRemRangeByRank(ctx context.Context, key string, start, stop int) error {
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		_, err := conn.Do("ZREMRANGEBYRANK", key, start, stop)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *client) ZCard(ctx context.Context, key string) (int, error) {
+	var count int
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		var errEx error
+		count, errEx = redis.Int(conn.Do("ZCARD", key))
+		if errEx != nil {
+			return errEx
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (c *client) ZRangeByScore(ctx context.Context, key, min, max string, offset, count int) ([]cache.ZMember, error) {
+	var members []cache.ZMember
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		values, errEx := redis.Strings(conn.Do("ZRANGEBYSCORE", key, min, max, "WITHSCORES", "LIMIT", offset, count))
+		if errEx != nil {
+			return errEx
+		}
+
+		members = make([]cache.ZMember, 0, len(values)/2)
+		for i := 0; i+1 < len(values); i += 2 {
+			score, errEx := strconv.ParseFloat(values[i+1], 64)
+			if errEx != nil {
+				return errEx
+			}
+			members = append(members, cache.ZMember{Member: values[i], Score: score})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (c *client) ZAppend(ctx context.Context, entries []cache.ZAppendEntry, maxLen int, ttl time.Duration) ([]int64, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	result := make([]int64, 0, len(entries))
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		// Скрипт загружается заранее, чтобы в pipeline передавать только его хэш
+		if err := zAppendScript.Load(conn); err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err := zAppendScript.SendHash(conn, e.SeqKey, e.Key, e.Value, maxLen, int64(ttl.Seconds())); err != nil {
+				return err
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+
+		for range entries {
+			seq, err := redis.Int64(conn.Receive())
+			if err != nil {
+				return err
+			}
+			result = append(result, seq)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *client) ZMergeStore(ctx context.Context, key, tmpKey string, members []cache.ZMember, maxLen int, ttl time.Duration) error {
+	err := c.execute(ctx, func(ctx context.Context, conn redis.Conn) error {
+		args := redis.Args{key, tmpKey, maxLen, int64(ttl.Seconds())}
+		for _, m := range members {
+			args = args.Add(m.Score, m.Member)
+		}
+		_, err := zMergeStoreScript.Do(conn, args...)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *client) execute(ctx context.Context, handler handler) error {
 	conn, err := c.getConnect(ctx)
 	if err != nil {
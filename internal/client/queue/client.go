@@ -2,23 +2,105 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"otus-project/internal/model"
 )
 
-// Client интерфейс для работы с очередью сообщений
+var (
+	// ErrUnroutable брокер вернул сообщение: ни одна очередь не подходит под routing key
+	ErrUnroutable = errors.New("message is unroutable")
+	// ErrNacked брокер не принял сообщение
+	ErrNacked = errors.New("message is not acknowledged by broker")
+)
+
+// Client интерфейс для работы с очередью сообщений.
+// Публикация возвращает ошибку, если брокер не подтвердил сообщение или не смог его маршрутизировать
 type Client interface {
 	// PublishFeedEvent публикует событие ленты для конкретного пользователя
 	PublishFeedEvent(ctx context.Context, userID string, event *model.FeedEvent) error
 
+	// PublishFeedEvents публикует событие ленты для нескольких пользователей и дожидается
+	// подтверждения всех сообщений разом. eventIDs - номера события в журналах получателей, по одному
+	// на пользователя, или nil. Ошибка с индексом i относится к userIDs[i], nil - сообщение принято
+	PublishFeedEvents(ctx context.Context, userIDs []string, event *model.FeedEvent, eventIDs []int64) []error
+
 	// PublishFeedUpdateTask публикует задачу обновления ленты
 	PublishFeedUpdateTask(ctx context.Context, task *model.FeedUpdateTask) error
 
-	// ConsumeFeedMaterializationTasks потребляет задачи материализации ленты
-	ConsumeFeedMaterializationTasks(ctx context.Context, handler func(context.Context, *model.FeedUpdateTask) error) error
+	// ConsumeFeedMaterializationTasks потребляет задачи материализации ленты пулом из opts.Workers обработчиков.
+	// Отмена ctx останавливает потребителя без ожидания обработки, для корректной остановки используется Consumer.Stop
+	ConsumeFeedMaterializationTasks(ctx context.Context, handler func(context.Context, *model.FeedUpdateTask) error, opts ConsumerOptions) (Consumer, error)
+
+	// PublishUserMessage публикует готовое WebSocket сообщение для соединений пользователя на всех узлах.
+	// Возвращает ErrUnroutable, если пользователь ни к одному узлу не подключен
+	PublishUserMessage(ctx context.Context, userID string, message *model.WebSocketMessage) error
+
+	// ConsumeUserEvents потребляет события ленты (feed.event.{user_id}) и WebSocket сообщения
+	// (feed.message.{user_id}) пользователей, привязанных BindUserEvents
+	ConsumeUserEvents(ctx context.Context, handlers UserEventHandlers) error
+
+	// BindUserEvents начинает получать события ленты и сообщения пользователя на этом узле.
+	// Привязка сохраняется после переподключения к брокеру
+	BindUserEvents(ctx context.Context, userID string) error
 
-	// ConsumeFeedEvents потребляет события ленты по routing key feed.event.{user_id}
-	ConsumeFeedEvents(ctx context.Context, handler func(context.Context, string, *model.FeedEvent) error) error
+	// UnbindUserEvents перестает получать события ленты и сообщения пользователя на этом узле
+	UnbindUserEvents(ctx context.Context, userID string) error
+
+	// PublishAnnouncement рассылает объявление администратора всем WebSocket узлам.
+	// Возвращает ErrUnroutable, если ни один узел не запущен
+	PublishAnnouncement(ctx context.Context, announcement *model.Announcement) error
+
+	// ConsumeAnnouncements потребляет объявления администратора. Каждый узел получает все объявления
+	ConsumeAnnouncements(ctx context.Context, handler func(context.Context, *model.Announcement) error) error
+
+	// PublishDomainEvent публикует доменное событие из outbox
+	PublishDomainEvent(ctx context.Context, message *model.OutboxMessage) error
+
+	// ConsumeDomainEvents потребляет доменные события и передает их тип и тело в JSON
+	ConsumeDomainEvents(ctx context.Context, handler func(context.Context, string, []byte) error) error
 
 	// Close закрывает соединение
 	Close() error
 }
+
+// UserEventHandlers обработчики сообщений, адресованных пользователям узла. Первым аргументом
+// после контекста передается userID из routing key
+type UserEventHandlers struct {
+	// FeedEvent обрабатывает события ленты
+	FeedEvent func(context.Context, string, *model.FeedEvent) error
+	// Message обрабатывает WebSocket сообщения, опубликованные PublishUserMessage
+	Message func(context.Context, string, *model.WebSocketMessage) error
+}
+
+// ConsumerOptions настройки пула обработчиков очереди
+type ConsumerOptions struct {
+	// Workers количество задач, обрабатываемых параллельно
+	Workers int
+	// Prefetch сколько неподтвержденных сообщений брокер выдает потребителю заранее
+	Prefetch int
+}
+
+// Consumer запущенный пул обработчиков очереди
+type Consumer interface {
+	// Stop перестает получать сообщения и ждет обработки уже полученных, пока не отменен ctx.
+	// После отмены ctx обработчики получают отмененный контекст, а их задачи возвращаются в очередь
+	Stop(ctx context.Context) error
+}
+
+// DeadLetterQueue операции с задачами материализации, попавшими в dead-letter очередь
+type DeadLetterQueue interface {
+	// ListDeadLetters возвращает до limit задач, не извлекая их из очереди
+	ListDeadLetters(ctx context.Context, limit int) ([]*model.DeadLetterTask, error)
+
+	// ReplayDeadLetters возвращает до limit задач в очередь материализации со сброшенным счетчиком попыток
+	ReplayDeadLetters(ctx context.Context, limit int) (int, error)
+
+	// PurgeDeadLetters удаляет все задачи из dead-letter очереди
+	PurgeDeadLetters(ctx context.Context) (int, error)
+}
+
+// HealthChecker клиент, который сообщает о состоянии соединения с брокером
+type HealthChecker interface {
+	// Healthy возвращает ошибку, если соединения с брокером сейчас нет
+	Healthy(ctx context.Context) error
+}
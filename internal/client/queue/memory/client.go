@@ -0,0 +1,619 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/client/queue/rabbitmq"
+	"otus-project/internal/model"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	_ queue.Client          = (*Client)(nil)
+	_ queue.DeadLetterQueue = (*Client)(nil)
+	_ queue.HealthChecker   = (*Client)(nil)
+)
+
+// ErrClosed клиент закрыт
+var ErrClosed = errors.New("memory queue is closed")
+
+// RetryConfig настройки повторов задач материализации. Им удовлетворяет config.RabbitMQConfig,
+// поэтому очередь в памяти повторяет задачи так же, как RabbitMQ
+type RetryConfig interface {
+	RetryMaxAttempts() int
+	RetryBackoff() time.Duration
+	RetryMaxBackoff() time.Duration
+}
+
+// Client очередь сообщений в памяти процесса с той же топологией, что и у клиента RabbitMQ:
+// topic exchange событий ленты и сообщений пользователей с routing key feed.event.{user_id}
+// и feed.message.{user_id}, приоритетная очередь материализации и очередь доменных событий
+// с отложенными повторами и dead-letter очередями.
+// Сообщения не переживают перезапуск процесса, поэтому клиент предназначен для тестов и локального запуска
+type Client struct {
+	config RetryConfig
+
+	feedEvents      *topicExchange
+	domainEvents    *topicExchange
+	materialization *memQueue
+	dead            *memQueue
+	domainQueue     *memQueue
+	domainDead      *memQueue
+
+	// feedMu защищает привязки очереди WebSocket событий
+	feedMu sync.Mutex
+	// feedBindings пользователи, события ленты и сообщения которых получает потребитель
+	feedBindings map[string]struct{}
+	// feedQueue очередь потребителя WebSocket событий, nil - потребитель не запущен
+	feedQueue *memQueue
+
+	wg        sync.WaitGroup
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewClient создает очередь сообщений в памяти
+func NewClient(cfg RetryConfig) *Client {
+	dead := newQueue(rabbitmq.FeedMaterializationDeadQueue, nil)
+	domainDead := newQueue(rabbitmq.DomainEventsDeadQueue, nil)
+
+	c := &Client{
+		config:          cfg,
+		feedEvents:      &topicExchange{},
+		domainEvents:    &topicExchange{},
+		materialization: newQueue(rabbitmq.FeedMaterializationQueue, dead),
+		dead:            dead,
+		domainQueue:     newQueue(rabbitmq.DomainEventsQueue, domainDead),
+		domainDead:      domainDead,
+		feedBindings:    make(map[string]struct{}),
+		closed:          make(chan struct{}),
+	}
+
+	c.domainEvents.bind(c.domainQueue, "#")
+
+	return c
+}
+
+// isClosed проверяет, закрыт ли клиент
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// queuePriority переводит приоритет задачи (1 - высокий, 5 - низкий) в приоритет очереди,
+// где сообщение с большим значением выдается раньше
+func queuePriority(priority int) uint8 {
+	priority = max(1, min(priority, rabbitmq.FeedMaxPriority))
+	return uint8(rabbitmq.FeedMaxPriority + 1 - priority)
+}
+
+// PublishFeedEvent публикует событие ленты для конкретного пользователя
+func (c *Client) PublishFeedEvent(ctx context.Context, userID string, event *model.FeedEvent) error {
+	return c.PublishFeedEvents(ctx, []string{userID}, event, nil)[0]
+}
+
+// PublishFeedEvents публикует событие ленты для каждого пользователя. Как и RabbitMQ с mandatory,
+// возвращает queue.ErrUnroutable для событий, которые не попали ни в одну очередь
+func (c *Client) PublishFeedEvents(_ context.Context, userIDs []string, event *model.FeedEvent, eventIDs []int64) []error {
+	results := make([]error, len(userIDs))
+
+	if c.isClosed() {
+		return fillErrors(results, ErrClosed)
+	}
+
+	for i, userID := range userIDs {
+		e := *event
+		if eventIDs != nil {
+			e.EventID = eventIDs[i]
+		}
+
+		body, err := json.Marshal(&e)
+		if err != nil {
+			results[i] = fmt.Errorf("failed to marshal event: %w", err)
+			continue
+		}
+
+		routingKey := fmt.Sprintf(rabbitmq.FeedEventRoutingKey, userID)
+		routed := c.feedEvents.publish(&message{
+			routingKey: routingKey,
+			body:       body,
+			timestamp:  time.Now(),
+		})
+		if routed == 0 {
+			results[i] = fmt.Errorf("%w: routing key %q", queue.ErrUnroutable, routingKey)
+		}
+	}
+
+	return results
+}
+
+// fillErrors возвращает результаты пачки, в которых все сообщения завершились одной ошибкой
+func fillErrors(results []error, err error) []error {
+	for i := range results {
+		results[i] = err
+	}
+
+	return results
+}
+
+// PublishFeedUpdateTask публикует задачу обновления ленты
+func (c *Client) PublishFeedUpdateTask(_ context.Context, task *model.FeedUpdateTask) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	body, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	c.materialization.push(&message{
+		id:         fmt.Sprintf("%s:%s:%d", task.UserID, task.PostID, time.Now().UnixNano()),
+		routingKey: rabbitmq.FeedMaterializationQueue,
+		body:       body,
+		priority:   queuePriority(task.Priority),
+		timestamp:  time.Now(),
+	})
+
+	return nil
+}
+
+// PublishDomainEvent публикует событие из outbox с routing key по типу события
+func (c *Client) PublishDomainEvent(_ context.Context, message *model.OutboxMessage) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	c.domainEvents.publish(newDomainMessage(message))
+
+	return nil
+}
+
+func newDomainMessage(m *model.OutboxMessage) *message {
+	return &message{
+		id:         m.ID,
+		routingKey: m.EventType,
+		body:       m.Payload,
+		timestamp:  m.CreatedAt,
+	}
+}
+
+// consumer пул обработчиков очереди в памяти
+type consumer struct {
+	wg      sync.WaitGroup
+	cancels []context.CancelFunc
+	// runCtx отменяется при остановке, после чего обработчики не берут новые сообщения
+	runCtx    context.Context
+	runCancel context.CancelFunc
+}
+
+// consume передает сообщения очереди workers обработчикам, пока пул не остановлен или не закрыт клиент.
+// Обработчик обязан подтвердить или отклонить доставку. Отмена ctx останавливает пул без ожидания задач
+func (c *Client) consume(ctx context.Context, q *memQueue, workers int, handle func(ctx context.Context, d *delivery)) (*consumer, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+
+	cs := &consumer{}
+	cs.runCtx, cs.runCancel = context.WithCancel(context.Background())
+
+	workerCtx := context.WithoutCancel(ctx)
+	for range max(workers, 1) {
+		wctx, cancel := context.WithCancel(workerCtx)
+		cs.cancels = append(cs.cancels, cancel)
+
+		cs.wg.Add(1)
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			defer cs.wg.Done()
+
+			for {
+				msg, ok := q.pop(cs.runCtx, c.closed)
+				if !ok {
+					return
+				}
+				c.safeHandle(wctx, &delivery{queue: q, msg: msg}, handle)
+			}
+		}()
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = cs.Stop(ctx)
+		case <-cs.runCtx.Done():
+		}
+	}()
+
+	return cs, nil
+}
+
+// safeHandle обрабатывает доставку. Как и пул обработчиков RabbitMQ, при панике обработчика
+// отправляет сообщение в dead-letter очередь, а не останавливает процесс
+func (c *Client) safeHandle(ctx context.Context, d *delivery, handle func(ctx context.Context, d *delivery)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic handling message %s from %s: %v\n%s", d.msg.id, d.queue.name, r, debug.Stack())
+			if d.queue.deadLetter == nil {
+				d.Nack(false)
+				return
+			}
+			c.settleFailed(d, d.queue, d.queue.deadLetter, d.msg.attempts+1, fmt.Errorf("panic: %v", r), false)
+		}
+	}()
+
+	handle(ctx, d)
+}
+
+// Stop перестает выдавать сообщения и ждет обработки уже выданных, пока не отменен ctx
+func (cs *consumer) Stop(ctx context.Context) error {
+	cs.runCancel()
+
+	done := make(chan struct{})
+	go func() {
+		cs.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("consumer stopped with tasks in flight: %w", ctx.Err())
+		cs.cancelWorkers()
+		<-done
+	}
+
+	cs.cancelWorkers()
+	return err
+}
+
+// cancelWorkers отменяет контексты обработчиков
+func (cs *consumer) cancelWorkers() {
+	for _, cancel := range cs.cancels {
+		cancel()
+	}
+}
+
+// ConsumeFeedMaterializationTasks потребляет задачи материализации ленты пулом из opts.Workers обработчиков.
+// Prefetch не используется: обработчик берет следующую задачу из очереди, когда освобождается
+func (c *Client) ConsumeFeedMaterializationTasks(ctx context.Context, handler func(context.Context, *model.FeedUpdateTask) error, opts queue.ConsumerOptions) (queue.Consumer, error) {
+	return c.consume(ctx, c.materialization, opts.Workers, func(ctx context.Context, d *delivery) {
+		var task model.FeedUpdateTask
+		if err := json.Unmarshal(d.msg.body, &task); err != nil {
+			log.Printf("Error unmarshaling task: %v", err)
+			c.settleFailed(d, c.materialization, c.dead, d.msg.attempts+1, err, false)
+			return
+		}
+
+		if !task.IsValid() {
+			log.Printf("ERROR: Invalid task received - UserID: '%s', PostID: '%s', rejecting message", task.UserID, task.PostID)
+			c.settleFailed(d, c.materialization, c.dead, d.msg.attempts+1, errors.New("invalid task"), false)
+			return
+		}
+
+		if err := handler(ctx, &task); err != nil {
+			if ctx.Err() != nil {
+				// Задача прервана остановкой пула и возвращается в очередь без учета попытки
+				d.Nack(true)
+				return
+			}
+
+			log.Printf("Error processing task: %v", err)
+			attempts := d.msg.attempts + 1
+			c.settleFailed(d, c.materialization, c.dead, attempts, err, attempts < c.config.RetryMaxAttempts())
+			return
+		}
+
+		d.Ack()
+	})
+}
+
+// settleFailed откладывает сообщение для повтора в очереди target или отправляет его в dead-letter очередь dead
+func (c *Client) settleFailed(d *delivery, target, dead *memQueue, attempts int, cause error, retry bool) {
+	msg := d.msg.clone()
+	msg.attempts = attempts
+	msg.lastError = cause.Error()
+
+	if !retry {
+		msg.failedAt = time.Now().UTC()
+		dead.push(msg)
+		d.Ack()
+		log.Printf("Message (ID: %s) from %s dead-lettered after %d attempts: %v", msg.id, target.name, attempts, cause)
+		return
+	}
+
+	// Аналог очереди повторов с TTL: сообщение возвращается в исходную очередь по таймеру
+	delay := c.retryBackoff(attempts)
+	time.AfterFunc(delay, func() {
+		if !c.isClosed() {
+			target.push(msg)
+		}
+	})
+	d.Ack()
+
+	log.Printf("Message (ID: %s) scheduled for retry %d in %s", msg.id, attempts, delay)
+}
+
+// retryBackoff задержка перед повтором: RetryBackoff, удваиваемый с каждой попыткой, но не больше RetryMaxBackoff
+func (c *Client) retryBackoff(attempts int) time.Duration {
+	backoff := c.config.RetryBackoff()
+	maxBackoff := c.config.RetryMaxBackoff()
+
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// ConsumeDomainEvents потребляет доменные события. Если обработчик вернул ошибку, событие
+// откладывается для повтора с нарастающей задержкой, а после RetryMaxAttempts попыток
+// уходит в dead-letter очередь
+func (c *Client) ConsumeDomainEvents(ctx context.Context, handler func(context.Context, string, []byte) error) error {
+	_, err := c.consume(ctx, c.domainQueue, 1, func(ctx context.Context, d *delivery) {
+		if err := handler(ctx, d.msg.routingKey, d.msg.body); err != nil {
+			if ctx.Err() != nil {
+				d.Nack(true)
+				return
+			}
+
+			log.Printf("Error handling domain event %s (message ID: %s): %v", d.msg.routingKey, d.msg.id, err)
+			attempts := d.msg.attempts + 1
+			c.settleFailed(d, c.domainQueue, c.domainDead, attempts, err, attempts < c.config.RetryMaxAttempts())
+			return
+		}
+		d.Ack()
+	})
+	return err
+}
+
+// PublishUserMessage публикует WebSocket сообщение для пользователя. Как и RabbitMQ с mandatory,
+// возвращает queue.ErrUnroutable, если очередь пользователя не привязана
+func (c *Client) PublishUserMessage(_ context.Context, userID string, msg *model.WebSocketMessage) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal websocket message: %w", err)
+	}
+
+	routingKey := fmt.Sprintf(rabbitmq.UserMessageRoutingKey, userID)
+	routed := c.feedEvents.publish(&message{
+		routingKey: routingKey,
+		body:       body,
+		timestamp:  time.Now(),
+	})
+	if routed == 0 {
+		return fmt.Errorf("%w: routing key %q", queue.ErrUnroutable, routingKey)
+	}
+
+	return nil
+}
+
+// ConsumeUserEvents потребляет события ленты и WebSocket сообщения и передает userID из routing key.
+// Как и временная очередь в RabbitMQ, очередь потребителя получает события пользователей,
+// привязанных BindUserEvents, и удаляется, когда потребитель останавливается
+func (c *Client) ConsumeUserEvents(ctx context.Context, handlers queue.UserEventHandlers) error {
+	q := newQueue(rabbitmq.FeedWebsocketQueuePrefix+"*", nil)
+
+	c.feedMu.Lock()
+	for userID := range c.feedBindings {
+		c.feedEvents.bind(q, fmt.Sprintf(rabbitmq.UserEventsBindingKey, userID))
+	}
+	c.feedQueue = q
+	c.feedMu.Unlock()
+
+	cs, err := c.consume(ctx, q, 1, func(ctx context.Context, d *delivery) {
+		// Автоподтверждение, как у потребителя RabbitMQ
+		d.Ack()
+		rabbitmq.HandleUserEvent(ctx, handlers, d.msg.routingKey, d.msg.body)
+	})
+	if err != nil {
+		c.stopFeedQueue(q)
+		return err
+	}
+
+	go func() {
+		select {
+		case <-cs.runCtx.Done():
+		case <-c.closed:
+		}
+		c.stopFeedQueue(q)
+	}()
+
+	return nil
+}
+
+// stopFeedQueue удаляет очередь потребителя WebSocket событий. Привязки пользователей сохраняются
+func (c *Client) stopFeedQueue(q *memQueue) {
+	c.feedMu.Lock()
+	defer c.feedMu.Unlock()
+
+	c.feedEvents.unbind(q)
+	if c.feedQueue == q {
+		c.feedQueue = nil
+	}
+}
+
+// BindUserEvents привязывает очередь потребителя WebSocket событий к событиям и сообщениям пользователя
+func (c *Client) BindUserEvents(_ context.Context, userID string) error {
+	c.feedMu.Lock()
+	defer c.feedMu.Unlock()
+
+	if _, ok := c.feedBindings[userID]; ok {
+		return nil
+	}
+
+	c.feedBindings[userID] = struct{}{}
+	if c.feedQueue != nil {
+		c.feedEvents.bind(c.feedQueue, fmt.Sprintf(rabbitmq.UserEventsBindingKey, userID))
+	}
+
+	return nil
+}
+
+// UnbindUserEvents отвязывает очередь потребителя WebSocket событий от событий и сообщений пользователя
+func (c *Client) UnbindUserEvents(_ context.Context, userID string) error {
+	c.feedMu.Lock()
+	defer c.feedMu.Unlock()
+
+	delete(c.feedBindings, userID)
+	if c.feedQueue != nil {
+		c.feedEvents.unbindPattern(c.feedQueue, fmt.Sprintf(rabbitmq.UserEventsBindingKey, userID))
+	}
+
+	return nil
+}
+
+// PublishAnnouncement рассылает объявление администратора всем потребителям объявлений
+func (c *Client) PublishAnnouncement(_ context.Context, announcement *model.Announcement) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	body, err := json.Marshal(announcement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal announcement: %w", err)
+	}
+
+	routed := c.feedEvents.publish(&message{
+		id:         announcement.ID,
+		routingKey: rabbitmq.AnnouncementRoutingKey,
+		body:       body,
+		timestamp:  announcement.CreatedAt,
+	})
+	if routed == 0 {
+		return fmt.Errorf("%w: routing key %q", queue.ErrUnroutable, rabbitmq.AnnouncementRoutingKey)
+	}
+
+	return nil
+}
+
+// ConsumeAnnouncements потребляет объявления администратора из собственной очереди потребителя
+func (c *Client) ConsumeAnnouncements(ctx context.Context, handler func(context.Context, *model.Announcement) error) error {
+	q := newQueue(rabbitmq.AnnouncementsQueuePrefix+"*", nil)
+	c.feedEvents.bind(q, rabbitmq.AnnouncementRoutingKey)
+
+	cs, err := c.consume(ctx, q, 1, func(ctx context.Context, d *delivery) {
+		d.Ack()
+
+		var announcement model.Announcement
+		if err := json.Unmarshal(d.msg.body, &announcement); err != nil {
+			log.Printf("Error unmarshaling announcement: %v", err)
+			return
+		}
+		if err := handler(ctx, &announcement); err != nil {
+			log.Printf("Error handling announcement %s: %v", announcement.ID, err)
+		}
+	})
+	if err != nil {
+		c.feedEvents.unbind(q)
+		return err
+	}
+
+	go func() {
+		select {
+		case <-cs.runCtx.Done():
+		case <-c.closed:
+		}
+		c.feedEvents.unbind(q)
+	}()
+
+	return nil
+}
+
+// ListDeadLetters возвращает до limit задач из dead-letter очереди, не извлекая их
+func (c *Client) ListDeadLetters(_ context.Context, limit int) ([]*model.DeadLetterTask, error) {
+	msgs := c.dead.peek(limit)
+
+	tasks := make([]*model.DeadLetterTask, 0, len(msgs))
+	for _, msg := range msgs {
+		tasks = append(tasks, toDeadLetterTask(msg))
+	}
+
+	return tasks, nil
+}
+
+// ReplayDeadLetters переносит до limit задач из dead-letter очереди в очередь материализации
+func (c *Client) ReplayDeadLetters(ctx context.Context, limit int) (int, error) {
+	if c.isClosed() {
+		return 0, ErrClosed
+	}
+
+	replayed := 0
+	for replayed < limit && ctx.Err() == nil {
+		msg, ok := c.dead.tryPop()
+		if !ok {
+			break
+		}
+
+		// Задача начинает попытки заново
+		msg = msg.clone()
+		msg.attempts = 0
+		msg.lastError = ""
+		msg.failedAt = time.Time{}
+
+		c.materialization.push(msg)
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// PurgeDeadLetters удаляет все задачи из dead-letter очереди
+func (c *Client) PurgeDeadLetters(_ context.Context) (int, error) {
+	return c.dead.purge(), nil
+}
+
+// toDeadLetterTask собирает описание задачи из сообщения dead-letter очереди
+func toDeadLetterTask(msg *message) *model.DeadLetterTask {
+	dl := &model.DeadLetterTask{
+		MessageID: msg.id,
+		Attempts:  msg.attempts,
+		Error:     msg.lastError,
+		FailedAt:  msg.failedAt,
+	}
+
+	var task model.FeedUpdateTask
+	if err := json.Unmarshal(msg.body, &task); err != nil {
+		dl.Body = string(msg.body)
+	} else {
+		dl.Task = &task
+	}
+
+	return dl
+}
+
+// Healthy возвращает ошибку, если клиент закрыт
+func (c *Client) Healthy(_ context.Context) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+// Close останавливает потребителей и дожидается обработки уже выданных им сообщений.
+// Неподтвержденные сообщения и отложенные повторы теряются
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	c.wg.Wait()
+
+	return nil
+}
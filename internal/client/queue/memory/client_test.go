@@ -0,0 +1,500 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/model"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRetryConfig повторы без заметных задержек
+type testRetryConfig struct {
+	maxAttempts int
+}
+
+func (c testRetryConfig) RetryMaxAttempts() int          { return c.maxAttempts }
+func (c testRetryConfig) RetryBackoff() time.Duration    { return time.Millisecond }
+func (c testRetryConfig) RetryMaxBackoff() time.Duration { return 5 * time.Millisecond }
+
+func newTestClient(t *testing.T, maxAttempts int) *Client {
+	t.Helper()
+
+	log.SetOutput(io.Discard)
+	c := NewClient(testRetryConfig{maxAttempts: maxAttempts})
+	t.Cleanup(func() {
+		_ = c.Close()
+		log.SetOutput(os.Stderr)
+	})
+
+	return c
+}
+
+func newTestTask(postID string, priority int) *model.FeedUpdateTask {
+	return &model.FeedUpdateTask{
+		PostID:   postID,
+		Priority: priority,
+		Event: &model.FeedEvent{
+			PostID:       postID,
+			AuthorUserID: "author",
+			EventType:    model.FeedEventTypePostCreated,
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+// waitFor ждет выполнения условия, которое выполняется асинхронно потребителями и таймерами повторов
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// receive ждет значение из канала
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for message")
+		var zero T
+		return zero
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		routingKey string
+		want       bool
+	}{
+		{"feed.*.u1", "feed.event.u1", true},
+		{"feed.*.u1", "feed.message.u1", true},
+		{"feed.*.u1", "feed.event.u2", false},
+		{"feed.*.u1", "feed.event.u1.extra", false},
+		{"feed.#", "feed", true},
+		{"feed.#", "feed.event.u1", true},
+		{"#", "post.created", true},
+		{"post.*", "post", false},
+		{"post.created", "post.created", true},
+		{"post.created", "post.updated", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchTopic(tt.pattern, tt.routingKey); got != tt.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", tt.pattern, tt.routingKey, got, tt.want)
+		}
+	}
+}
+
+func TestUserEventsRouting(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	type received struct {
+		userID  string
+		eventID int64
+		text    string
+	}
+	events := make(chan received, 10)
+	messages := make(chan received, 10)
+
+	if err := c.ConsumeUserEvents(ctx, queue.UserEventHandlers{
+		FeedEvent: func(_ context.Context, userID string, event *model.FeedEvent) error {
+			events <- received{userID: userID, eventID: event.EventID, text: event.PostText}
+			return nil
+		},
+		Message: func(_ context.Context, userID string, msg *model.WebSocketMessage) error {
+			messages <- received{userID: userID, text: msg.Type}
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("ConsumeUserEvents: %v", err)
+	}
+	if err := c.BindUserEvents(ctx, "u1"); err != nil {
+		t.Fatalf("BindUserEvents: %v", err)
+	}
+
+	event := &model.FeedEvent{PostID: "p1", AuthorUserID: "author", PostText: "hello", EventType: model.FeedEventTypePostCreated}
+	results := c.PublishFeedEvents(ctx, []string{"u1", "u2"}, event, []int64{7, 8})
+	if results[0] != nil {
+		t.Fatalf("event for bound user: %v", results[0])
+	}
+	if !errors.Is(results[1], queue.ErrUnroutable) {
+		t.Fatalf("event for unbound user: got %v, want ErrUnroutable", results[1])
+	}
+
+	if got := receive(t, events); got != (received{userID: "u1", eventID: 7, text: "hello"}) {
+		t.Errorf("received event %+v", got)
+	}
+
+	if err := c.PublishUserMessage(ctx, "u1", &model.WebSocketMessage{Type: "announcement"}); err != nil {
+		t.Fatalf("PublishUserMessage: %v", err)
+	}
+	if got := receive(t, messages); got.userID != "u1" || got.text != "announcement" {
+		t.Errorf("received message %+v", got)
+	}
+
+	if err := c.UnbindUserEvents(ctx, "u1"); err != nil {
+		t.Fatalf("UnbindUserEvents: %v", err)
+	}
+	if err := c.PublishFeedEvent(ctx, "u1", event); !errors.Is(err, queue.ErrUnroutable) {
+		t.Errorf("event after unbind: got %v, want ErrUnroutable", err)
+	}
+	if err := c.PublishUserMessage(ctx, "u1", &model.WebSocketMessage{}); !errors.Is(err, queue.ErrUnroutable) {
+		t.Errorf("message after unbind: got %v, want ErrUnroutable", err)
+	}
+}
+
+func TestBindingsSurviveConsumerRestart(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	// Привязка до запуска потребителя, как у узла, который принимает соединения раньше подписки
+	if err := c.BindUserEvents(ctx, "u1"); err != nil {
+		t.Fatalf("BindUserEvents: %v", err)
+	}
+
+	events := make(chan string, 1)
+	if err := c.ConsumeUserEvents(ctx, queue.UserEventHandlers{
+		FeedEvent: func(_ context.Context, userID string, _ *model.FeedEvent) error {
+			events <- userID
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("ConsumeUserEvents: %v", err)
+	}
+
+	if err := c.PublishFeedEvent(ctx, "u1", &model.FeedEvent{PostID: "p1"}); err != nil {
+		t.Fatalf("PublishFeedEvent: %v", err)
+	}
+	if got := receive(t, events); got != "u1" {
+		t.Errorf("received event for %q, want u1", got)
+	}
+}
+
+func TestMaterializationPriorityOrder(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	// Задачи публикуются до запуска потребителя, чтобы порядок выдачи определялся только очередью
+	for _, task := range []*model.FeedUpdateTask{
+		newTestTask("dormant", model.FeedTaskPriorityDormant),
+		newTestTask("normal-1", model.FeedTaskPriorityNormal),
+		newTestTask("online", model.FeedTaskPriorityOnline),
+		newTestTask("normal-2", model.FeedTaskPriorityNormal),
+		newTestTask("active", model.FeedTaskPriorityActive),
+	} {
+		if err := c.PublishFeedUpdateTask(ctx, task); err != nil {
+			t.Fatalf("PublishFeedUpdateTask: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	var order []string
+	if _, err := c.ConsumeFeedMaterializationTasks(ctx, func(_ context.Context, task *model.FeedUpdateTask) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, task.PostID)
+		return nil
+	}, queue.ConsumerOptions{Workers: 1}); err != nil {
+		t.Fatalf("ConsumeFeedMaterializationTasks: %v", err)
+	}
+
+	waitFor(t, "all tasks", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 5
+	})
+
+	want := "online,active,normal-1,normal-2,dormant"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestDeliveryAckNack(t *testing.T) {
+	dead := newQueue("dead", nil)
+	q := newQueue("tasks", dead)
+
+	q.push(&message{id: "m1"})
+	msg, _ := q.tryPop()
+	d := &delivery{queue: q, msg: msg}
+	d.Nack(true)
+	// Сообщение уже возвращено в очередь, повторное отклонение ничего не меняет
+	d.Nack(false)
+
+	msg, ok := q.tryPop()
+	if !ok || msg.id != "m1" || !msg.redelivered {
+		t.Fatalf("requeued message = %+v, want redelivered m1", msg)
+	}
+	if msgs := dead.peek(10); len(msgs) != 0 {
+		t.Fatalf("dead-letter queue has %d messages after requeue", len(msgs))
+	}
+
+	d = &delivery{queue: q, msg: msg}
+	d.Nack(false)
+	if msgs := dead.peek(10); len(msgs) != 1 || msgs[0].id != "m1" || msgs[0].redelivered {
+		t.Fatalf("dead-letter queue = %+v, want m1", msgs)
+	}
+
+	q.push(&message{id: "m2"})
+	msg, _ = q.tryPop()
+	d = &delivery{queue: q, msg: msg}
+	d.Ack()
+	d.Nack(true)
+	if _, ok := q.tryPop(); ok {
+		t.Error("acked message returned to queue")
+	}
+}
+
+func TestMaterializationRetryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	failing := true
+	if _, err := c.ConsumeFeedMaterializationTasks(ctx, func(_ context.Context, task *model.FeedUpdateTask) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[task.PostID]++
+		if failing {
+			return errors.New("db unavailable")
+		}
+		return nil
+	}, queue.ConsumerOptions{Workers: 2}); err != nil {
+		t.Fatalf("ConsumeFeedMaterializationTasks: %v", err)
+	}
+
+	if err := c.PublishFeedUpdateTask(ctx, newTestTask("p1", model.FeedTaskPriorityNormal)); err != nil {
+		t.Fatalf("PublishFeedUpdateTask: %v", err)
+	}
+
+	var dead []*model.DeadLetterTask
+	waitFor(t, "dead-lettered task", func() bool {
+		dead, _ = c.ListDeadLetters(ctx, 10)
+		return len(dead) == 1
+	})
+
+	mu.Lock()
+	if calls["p1"] != 3 {
+		t.Errorf("task handled %d times, want 3", calls["p1"])
+	}
+	failing = false
+	mu.Unlock()
+
+	if dead[0].Attempts != 3 || dead[0].Error != "db unavailable" || dead[0].FailedAt.IsZero() {
+		t.Errorf("dead letter = %+v, want 3 attempts with error", dead[0])
+	}
+	if dead[0].Task == nil || dead[0].Task.PostID != "p1" {
+		t.Errorf("dead letter task = %+v, want p1", dead[0].Task)
+	}
+
+	replayed, err := c.ReplayDeadLetters(ctx, 10)
+	if err != nil || replayed != 1 {
+		t.Fatalf("ReplayDeadLetters = %d, %v, want 1", replayed, err)
+	}
+	waitFor(t, "replayed task", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["p1"] == 4
+	})
+	if dead, _ := c.ListDeadLetters(ctx, 10); len(dead) != 0 {
+		t.Errorf("dead-letter queue has %d tasks after replay", len(dead))
+	}
+}
+
+func TestInvalidTaskDeadLetteredWithoutRetry(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	handled := make(chan string, 1)
+	if _, err := c.ConsumeFeedMaterializationTasks(ctx, func(_ context.Context, task *model.FeedUpdateTask) error {
+		handled <- task.PostID
+		return nil
+	}, queue.ConsumerOptions{Workers: 1}); err != nil {
+		t.Fatalf("ConsumeFeedMaterializationTasks: %v", err)
+	}
+
+	if err := c.PublishFeedUpdateTask(ctx, &model.FeedUpdateTask{PostID: "p1"}); err != nil {
+		t.Fatalf("PublishFeedUpdateTask: %v", err)
+	}
+
+	waitFor(t, "dead-lettered task", func() bool {
+		dead, _ := c.ListDeadLetters(ctx, 10)
+		return len(dead) == 1 && dead[0].Attempts == 1
+	})
+	select {
+	case postID := <-handled:
+		t.Fatalf("invalid task %s passed to handler", postID)
+	default:
+	}
+
+	purged, err := c.PurgeDeadLetters(ctx)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeDeadLetters = %d, %v, want 1", purged, err)
+	}
+	if dead, _ := c.ListDeadLetters(ctx, 10); len(dead) != 0 {
+		t.Errorf("dead-letter queue has %d tasks after purge", len(dead))
+	}
+}
+
+func TestHandlerPanicDeadLettersTask(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	handled := make(chan string, 1)
+	if _, err := c.ConsumeFeedMaterializationTasks(ctx, func(_ context.Context, task *model.FeedUpdateTask) error {
+		if task.PostID == "poison" {
+			panic("nil map")
+		}
+		handled <- task.PostID
+		return nil
+	}, queue.ConsumerOptions{Workers: 1}); err != nil {
+		t.Fatalf("ConsumeFeedMaterializationTasks: %v", err)
+	}
+
+	for _, postID := range []string{"poison", "p1"} {
+		if err := c.PublishFeedUpdateTask(ctx, newTestTask(postID, model.FeedTaskPriorityNormal)); err != nil {
+			t.Fatalf("PublishFeedUpdateTask: %v", err)
+		}
+	}
+
+	// Обработчик продолжает работать после паники
+	if got := receive(t, handled); got != "p1" {
+		t.Fatalf("handled %s, want p1", got)
+	}
+
+	dead, _ := c.ListDeadLetters(ctx, 10)
+	if len(dead) != 1 || dead[0].Task.PostID != "poison" || dead[0].Error != "panic: nil map" {
+		t.Fatalf("dead letters = %+v, want poison task with panic error", dead)
+	}
+}
+
+func TestStopRequeuesTaskInFlight(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	started := make(chan struct{})
+	consumer, err := c.ConsumeFeedMaterializationTasks(ctx, func(ctx context.Context, _ *model.FeedUpdateTask) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, queue.ConsumerOptions{Workers: 1})
+	if err != nil {
+		t.Fatalf("ConsumeFeedMaterializationTasks: %v", err)
+	}
+
+	if err := c.PublishFeedUpdateTask(ctx, newTestTask("p1", model.FeedTaskPriorityNormal)); err != nil {
+		t.Fatalf("PublishFeedUpdateTask: %v", err)
+	}
+	receive(t, started)
+
+	drainCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := consumer.Stop(drainCtx); err == nil {
+		t.Fatal("Stop with task in flight returned nil error")
+	}
+
+	// Прерванная задача возвращается в очередь без учета попытки и достается следующему потребителю
+	handled := make(chan string, 1)
+	if _, err := c.ConsumeFeedMaterializationTasks(ctx, func(_ context.Context, task *model.FeedUpdateTask) error {
+		handled <- task.PostID
+		return nil
+	}, queue.ConsumerOptions{Workers: 1}); err != nil {
+		t.Fatalf("ConsumeFeedMaterializationTasks: %v", err)
+	}
+	if got := receive(t, handled); got != "p1" {
+		t.Errorf("handled %s, want p1", got)
+	}
+	if dead, _ := c.ListDeadLetters(ctx, 10); len(dead) != 0 {
+		t.Errorf("dead-letter queue has %d tasks", len(dead))
+	}
+}
+
+func TestDomainEventsRetryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	if err := c.ConsumeDomainEvents(ctx, func(_ context.Context, eventType string, payload []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[string(payload)]++
+
+		switch {
+		case eventType != "post.created":
+			return errors.New("unexpected event type " + eventType)
+		case string(payload) == "poison":
+			return errors.New("bad payload")
+		case calls[string(payload)] < 2:
+			return errors.New("temporary error")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("ConsumeDomainEvents: %v", err)
+	}
+
+	for _, payload := range []string{"flaky", "poison"} {
+		if err := c.PublishDomainEvent(ctx, &model.OutboxMessage{
+			ID:        payload,
+			EventType: "post.created",
+			Payload:   []byte(payload),
+			CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("PublishDomainEvent: %v", err)
+		}
+	}
+
+	waitFor(t, "dead-lettered domain event", func() bool {
+		return len(c.domainDead.peek(10)) == 1
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["flaky"] != 2 {
+		t.Errorf("flaky event handled %d times, want 2", calls["flaky"])
+	}
+	if calls["poison"] != 3 {
+		t.Errorf("poison event handled %d times, want 3", calls["poison"])
+	}
+
+	dead := c.domainDead.peek(10)[0]
+	if dead.id != "poison" || dead.attempts != 3 || dead.lastError != "bad payload" || dead.routingKey != "post.created" {
+		t.Errorf("dead domain event = %+v", dead)
+	}
+}
+
+func TestClosedClient(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, 3)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := c.Healthy(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Healthy = %v, want ErrClosed", err)
+	}
+	if err := c.PublishFeedUpdateTask(ctx, newTestTask("p1", model.FeedTaskPriorityNormal)); !errors.Is(err, ErrClosed) {
+		t.Errorf("PublishFeedUpdateTask = %v, want ErrClosed", err)
+	}
+	if _, err := c.ConsumeFeedMaterializationTasks(ctx, nil, queue.ConsumerOptions{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("ConsumeFeedMaterializationTasks = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,296 @@
+package memory
+
+import (
+	"container/heap"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// message сообщение в очереди. Вместо заголовков x-retry-count, x-error и x-failed-at
+// клиента RabbitMQ счетчик попыток и описание ошибки хранятся в полях
+type message struct {
+	id          string
+	routingKey  string
+	body        []byte
+	priority    uint8
+	timestamp   time.Time
+	attempts    int
+	lastError   string
+	failedAt    time.Time
+	redelivered bool
+
+	// seq порядок публикации: при равном приоритете сообщения выдаются в порядке seq
+	seq uint64
+}
+
+// clone копирует сообщение для доставки в другую очередь
+func (m *message) clone() *message {
+	c := *m
+	c.redelivered = false
+	return &c
+}
+
+// messageHeap сообщения по убыванию приоритета, при равном приоритете - по порядку публикации
+type messageHeap []*message
+
+func (h messageHeap) Len() int { return len(h) }
+
+func (h messageHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h messageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *messageHeap) Push(x interface{}) { *h = append(*h, x.(*message)) }
+
+func (h *messageHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	msg := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return msg
+}
+
+// memQueue очередь с приоритетами. Потребители ждут сообщений в pop
+type memQueue struct {
+	name string
+	// deadLetter очередь для сообщений, отклоненных без возврата в очередь. Если не задана, такие сообщения удаляются
+	deadLetter *memQueue
+
+	mu     sync.Mutex
+	items  messageHeap
+	seq    uint64
+	notify chan struct{}
+}
+
+func newQueue(name string, deadLetter *memQueue) *memQueue {
+	return &memQueue{
+		name:       name,
+		deadLetter: deadLetter,
+		notify:     make(chan struct{}, 1),
+	}
+}
+
+// push кладет новое сообщение в очередь
+func (q *memQueue) push(msg *message) {
+	q.mu.Lock()
+	q.seq++
+	msg.seq = q.seq
+	heap.Push(&q.items, msg)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// requeue возвращает сообщение в очередь на прежнее место среди сообщений того же приоритета
+func (q *memQueue) requeue(msg *message) {
+	msg.redelivered = true
+
+	q.mu.Lock()
+	heap.Push(&q.items, msg)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// signal будит одного из ожидающих потребителей
+func (q *memQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// tryPop извлекает сообщение, не дожидаясь его появления
+func (q *memQueue) tryPop() (*message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, false
+	}
+
+	msg := heap.Pop(&q.items).(*message)
+	if len(q.items) > 0 {
+		// Сообщений больше одного: будим следующего потребителя
+		q.signal()
+	}
+
+	return msg, true
+}
+
+// pop ждет сообщение, пока не отменен ctx или не закрыт done. Остановленный потребитель
+// не получает сообщений, даже если они есть в очереди
+func (q *memQueue) pop(ctx context.Context, done <-chan struct{}) (*message, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-done:
+			return nil, false
+		default:
+		}
+
+		if msg, ok := q.tryPop(); ok {
+			return msg, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-done:
+			return nil, false
+		case <-q.notify:
+		}
+	}
+}
+
+// peek возвращает до limit сообщений в порядке выдачи, не извлекая их
+func (q *memQueue) peek(limit int) []*message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make(messageHeap, len(q.items))
+	copy(items, q.items)
+
+	result := make([]*message, 0, min(limit, len(items)))
+	for len(result) < limit && len(items) > 0 {
+		result = append(result, heap.Pop(&items).(*message))
+	}
+
+	return result
+}
+
+// purge удаляет все сообщения и возвращает их количество
+func (q *memQueue) purge() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := len(q.items)
+	q.items = nil
+
+	return count
+}
+
+// delivery доставленное потребителю сообщение, которое нужно подтвердить или отклонить
+type delivery struct {
+	queue *memQueue
+	msg   *message
+
+	settleOnce sync.Once
+}
+
+// Ack подтверждает сообщение
+func (d *delivery) Ack() {
+	d.settleOnce.Do(func() {})
+}
+
+// Nack отклоняет сообщение: возвращает его в очередь или отправляет в dead-letter очередь
+func (d *delivery) Nack(requeue bool) {
+	d.settleOnce.Do(func() {
+		switch {
+		case requeue:
+			d.queue.requeue(d.msg)
+		case d.queue.deadLetter != nil:
+			d.queue.deadLetter.push(d.msg.clone())
+		}
+	})
+}
+
+// binding привязка очереди к topic exchange
+type binding struct {
+	pattern string
+	queue   *memQueue
+}
+
+// topicExchange маршрутизирует сообщения в очереди по шаблону routing key, как topic exchange RabbitMQ
+type topicExchange struct {
+	mu       sync.RWMutex
+	bindings []binding
+}
+
+func (e *topicExchange) bind(q *memQueue, pattern string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.bindings = append(e.bindings, binding{pattern: pattern, queue: q})
+}
+
+func (e *topicExchange) unbind(q *memQueue) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	bindings := e.bindings[:0]
+	for _, b := range e.bindings {
+		if b.queue != q {
+			bindings = append(bindings, b)
+		}
+	}
+	e.bindings = bindings
+}
+
+// unbindPattern удаляет привязку очереди по одному шаблону
+func (e *topicExchange) unbindPattern(q *memQueue, pattern string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	bindings := e.bindings[:0]
+	for _, b := range e.bindings {
+		if b.queue != q || b.pattern != pattern {
+			bindings = append(bindings, b)
+		}
+	}
+	e.bindings = bindings
+}
+
+// publish кладет копию сообщения в каждую очередь, шаблон которой подходит к routing key.
+// Возвращает количество очередей, получивших сообщение
+func (e *topicExchange) publish(msg *message) int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	routed := make(map[*memQueue]struct{}, len(e.bindings))
+	for _, b := range e.bindings {
+		if _, ok := routed[b.queue]; ok {
+			continue
+		}
+		if matchTopic(b.pattern, msg.routingKey) {
+			routed[b.queue] = struct{}{}
+			b.queue.push(msg.clone())
+		}
+	}
+
+	return len(routed)
+}
+
+// matchTopic проверяет routing key по шаблону topic exchange:
+// * - ровно одно слово, # - ноль или больше слов
+func matchTopic(pattern, routingKey string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
@@ -3,10 +3,12 @@ package rabbitmq
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"otus-project/internal/config"
+	"otus-project/internal/client/queue"
 	"otus-project/internal/model"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,49 +17,46 @@ import (
 
 const (
 	// Exchange names
-	FeedEventsExchange = "feed.events"
+	FeedEventsExchange     = "feed.events"
+	FeedDeadLetterExchange = "feed.dlx"
+	DomainEventsExchange   = "domain.events"
 
 	// Queue names
-	FeedMaterializationQueue = "feed.materialization"
-	FeedWebsocketQueuePrefix = "feed.websocket."
+	FeedMaterializationQueue      = "feed.materialization"
+	FeedMaterializationRetryQueue = "feed.materialization.retry"
+	FeedMaterializationDeadQueue  = "feed.materialization.dead"
+	FeedWebsocketQueuePrefix      = "feed.websocket."
+	AnnouncementsQueuePrefix      = "websocket.announcements."
+	DomainEventsQueue             = "domain.events.handlers"
+	DomainEventsRetryQueue        = "domain.events.handlers.retry"
+	DomainEventsDeadQueue         = "domain.events.handlers.dead"
+
+	// feedMaterializationMigrationQueue временная очередь для переноса задач при пересоздании очереди материализации
+	feedMaterializationMigrationQueue = "feed.materialization.migration"
+
+	// FeedMaxPriority максимальный приоритет очереди материализации (x-max-priority)
+	FeedMaxPriority = 5
 
 	// Routing key patterns
 	FeedEventRoutingKey = "feed.event.%s" // feed.event.{user_id}
+	// UserMessageRoutingKey готовые WebSocket сообщения пользователя, например сообщения диалогов
+	UserMessageRoutingKey = "feed.message.%s" // feed.message.{user_id}
+	// UserEventsBindingKey привязка очереди узла ко всем событиям и сообщениям пользователя
+	UserEventsBindingKey = "feed.*.%s"
+	// AnnouncementRoutingKey объявления администратора в exchange событий ленты
+	AnnouncementRoutingKey = "websocket.announcement"
+
+	// Заголовки сообщений с задачами
+	HeaderRetryCount = "x-retry-count"
+	HeaderError      = "x-error"
+	HeaderFailedAt   = "x-failed-at"
 )
 
-type Client struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	config  config.RabbitMQConfig
-}
-
-// NewClient создает новый клиент RabbitMQ
-func NewClient(cfg config.RabbitMQConfig) (*Client, error) {
-	conn, err := amqp.Dial(cfg.DSN())
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
-
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
-	}
-
-	client := &Client{
-		conn:    conn,
-		channel: ch,
-		config:  cfg,
-	}
-
-	// Настраиваем exchange и очереди
-	if err := client.setupExchangeAndQueues(); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to setup exchange and queues: %w", err)
-	}
-
-	return client, nil
-}
+var (
+	_ queue.Client          = (*Client)(nil)
+	_ queue.DeadLetterQueue = (*Client)(nil)
+	_ queue.HealthChecker   = (*Client)(nil)
+)
 
 // setupExchangeAndQueues настраивает exchange и очереди
 func (c *Client) setupExchangeAndQueues() error {
@@ -75,60 +74,362 @@ func (c *Client) setupExchangeAndQueues() error {
 		return fmt.Errorf("failed to declare exchange: %w", err)
 	}
 
-	// Объявляем очередь для материализации ленты
+	// Объявляем приоритетную очередь для материализации ленты
+	if err = c.declareMaterializationQueue(); err != nil {
+		return err
+	}
+
+	// НЕ привязываем очередь материализации к exchange, так как задачи публикуются в default exchange
+	// Очередь материализации работает независимо от feed.events exchange
+
+	// Очередь повторов без потребителей: задача лежит в ней TTL сообщения (задержку повтора),
+	// после чего RabbitMQ возвращает ее в очередь материализации через default exchange
 	_, err = c.channel.QueueDeclare(
-		FeedMaterializationQueue, // name
-		true,                     // durable
-		false,                    // delete when unused
-		false,                    // exclusive
-		false,                    // no-wait
+		FeedMaterializationRetryQueue, // name
+		true,                          // durable
+		false,                         // delete when unused
+		false,                         // exclusive
+		false,                         // no-wait
 		amqp.Table{
-			"x-message-ttl": int32(24 * 60 * 60 * 1000), // 24 часа в миллисекундах
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": FeedMaterializationQueue,
 		}, // arguments
 	)
 	if err != nil {
+		return fmt.Errorf("failed to declare retry queue: %w", err)
+	}
+
+	// Dead-letter exchange и очередь для задач, исчерпавших попытки
+	err = c.channel.ExchangeDeclare(
+		FeedDeadLetterExchange, // name
+		"direct",               // type
+		true,                   // durable
+		false,                  // auto-deleted
+		false,                  // internal
+		false,                  // no-wait
+		nil,                    // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+
+	_, err = c.channel.QueueDeclare(
+		FeedMaterializationDeadQueue, // name
+		true,                         // durable
+		false,                        // delete when unused
+		false,                        // exclusive
+		false,                        // no-wait
+		nil,                          // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+
+	err = c.channel.QueueBind(FeedMaterializationDeadQueue, FeedMaterializationQueue, FeedDeadLetterExchange, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+
+	// Доменные события из outbox: routing key - тип события, общая очередь обработчиков
+	err = c.channel.ExchangeDeclare(
+		DomainEventsExchange, // name
+		"topic",              // type
+		true,                 // durable
+		false,                // auto-deleted
+		false,                // internal
+		false,                // no-wait
+		nil,                  // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare domain events exchange: %w", err)
+	}
+
+	_, err = c.channel.QueueDeclare(
+		DomainEventsQueue, // name
+		true,              // durable
+		false,             // delete when unused
+		false,             // exclusive
+		false,             // no-wait
+		nil,               // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare domain events queue: %w", err)
+	}
+
+	err = c.channel.QueueBind(DomainEventsQueue, "#", DomainEventsExchange, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to bind domain events queue: %w", err)
+	}
+
+	// Повторы и dead-letter доменных событий устроены так же, как у задач материализации
+	_, err = c.channel.QueueDeclare(
+		DomainEventsRetryQueue, // name
+		true,                   // durable
+		false,                  // delete when unused
+		false,                  // exclusive
+		false,                  // no-wait
+		amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": DomainEventsQueue,
+		}, // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare domain events retry queue: %w", err)
+	}
+
+	_, err = c.channel.QueueDeclare(
+		DomainEventsDeadQueue, // name
+		true,                  // durable
+		false,                 // delete when unused
+		false,                 // exclusive
+		false,                 // no-wait
+		nil,                   // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare domain events dead-letter queue: %w", err)
+	}
+
+	err = c.channel.QueueBind(DomainEventsDeadQueue, DomainEventsQueue, FeedDeadLetterExchange, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to bind domain events dead-letter queue: %w", err)
+	}
+
+	return nil
+}
+
+// retryTopology очередь повторов и routing key в dead-letter exchange для сообщений одной очереди
+type retryTopology struct {
+	retryQueue     string
+	deadRoutingKey string
+}
+
+var (
+	materializationRetry = retryTopology{retryQueue: FeedMaterializationRetryQueue, deadRoutingKey: FeedMaterializationQueue}
+	domainEventsRetry    = retryTopology{retryQueue: DomainEventsRetryQueue, deadRoutingKey: DomainEventsQueue}
+)
+
+// materializationQueueArgs аргументы очереди материализации
+func materializationQueueArgs() amqp.Table {
+	return amqp.Table{
+		"x-message-ttl":  int32(24 * 60 * 60 * 1000), // 24 часа в миллисекундах
+		"x-max-priority": int32(FeedMaxPriority),
+	}
+}
+
+// declareMaterializationQueue объявляет очередь материализации как приоритетную.
+// Аргументы существующей очереди изменить нельзя, поэтому очередь, созданная раньше
+// без x-max-priority, пересоздается с переносом задач (см. migrateMaterializationQueue)
+func (c *Client) declareMaterializationQueue() error {
+	_, err := c.channel.QueueDeclare(
+		FeedMaterializationQueue,   // name
+		true,                       // durable
+		false,                      // delete when unused
+		false,                      // exclusive
+		false,                      // no-wait
+		materializationQueueArgs(), // arguments
+	)
+	if err == nil {
+		// Задачи могли остаться во временной очереди, если прошлая миграция прервалась
+		return c.restoreMigratedTasks()
+	}
+	if !isAMQPError(err, amqp.PreconditionFailed) {
 		return fmt.Errorf("failed to declare queue: %w", err)
 	}
 
-	// НЕ привязываем очередь материализации к exchange, так как задачи публикуются в default exchange
-	// Очередь материализации работает независимо от feed.events exchange
+	// Брокер закрывает канал после ошибки объявления
+	if c.channel, err = c.conn.Channel(); err != nil {
+		return fmt.Errorf("failed to reopen channel: %w", err)
+	}
 
+	return c.migrateMaterializationQueue()
+}
+
+// migrateMaterializationQueue переносит задачи во временную очередь, пересоздает очередь
+// материализации с новыми аргументами и возвращает задачи обратно. Если во время переноса
+// в старую очередь пришли новые задачи, перенос повторяется
+func (c *Client) migrateMaterializationQueue() error {
+	log.Printf("Migrating queue %s to priority queue (x-max-priority=%d)", FeedMaterializationQueue, FeedMaxPriority)
+
+	_, err := c.channel.QueueDeclare(feedMaterializationMigrationQueue, true, false, false, false, materializationQueueArgs())
+	if err != nil {
+		return fmt.Errorf("failed to declare migration queue: %w", err)
+	}
+
+	const maxAttempts = 3
+	for attempt := 1; ; attempt++ {
+		moved, err := moveMessages(c.channel, FeedMaterializationQueue, feedMaterializationMigrationQueue)
+		if err != nil {
+			return fmt.Errorf("failed to move tasks to migration queue: %w", err)
+		}
+		log.Printf("Moved %d tasks to %s", moved, feedMaterializationMigrationQueue)
+
+		_, err = c.channel.QueueDelete(FeedMaterializationQueue, false, true, false)
+		if err == nil {
+			break
+		}
+		if !isAMQPError(err, amqp.PreconditionFailed) || attempt == maxAttempts {
+			return fmt.Errorf("failed to delete queue %s: %w", FeedMaterializationQueue, err)
+		}
+
+		if c.channel, err = c.conn.Channel(); err != nil {
+			return fmt.Errorf("failed to reopen channel: %w", err)
+		}
+	}
+
+	_, err = c.channel.QueueDeclare(FeedMaterializationQueue, true, false, false, false, materializationQueueArgs())
+	if err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	return c.restoreMigratedTasks()
+}
+
+// restoreMigratedTasks возвращает задачи из временной очереди миграции и удаляет ее
+func (c *Client) restoreMigratedTasks() error {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	_, err = ch.QueueDeclarePassive(feedMaterializationMigrationQueue, true, false, false, false, nil)
+	if err != nil {
+		if isAMQPError(err, amqp.NotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to check migration queue: %w", err)
+	}
+
+	moved, err := moveMessages(ch, feedMaterializationMigrationQueue, FeedMaterializationQueue)
+	if err != nil {
+		return fmt.Errorf("failed to restore tasks from migration queue: %w", err)
+	}
+
+	if _, err = ch.QueueDelete(feedMaterializationMigrationQueue, false, true, false); err != nil {
+		return fmt.Errorf("failed to delete migration queue: %w", err)
+	}
+
+	log.Printf("Restored %d tasks to %s", moved, FeedMaterializationQueue)
 	return nil
 }
 
-// PublishFeedEvent публикует событие ленты для конкретного пользователя
+// moveMessages переносит все сообщения из очереди from в очередь to
+func moveMessages(ch *amqp.Channel, from, to string) (int, error) {
+	moved := 0
+	for {
+		msg, ok, err := ch.Get(from, false)
+		if err != nil {
+			return moved, err
+		}
+		if !ok {
+			return moved, nil
+		}
+
+		err = ch.PublishWithContext(context.Background(), "", to, false, false, republishing(msg, msg.Headers))
+		if err != nil {
+			return moved, err
+		}
+
+		if err = msg.Ack(false); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+}
+
+// isAMQPError проверяет, что брокер вернул ошибку с указанным кодом
+func isAMQPError(err error, code int) bool {
+	var amqpErr *amqp.Error
+	return errors.As(err, &amqpErr) && amqpErr.Code == code
+}
+
+// amqpPriority переводит приоритет задачи (1 - высокий, 5 - низкий)
+// в приоритет RabbitMQ, где сообщение с большим значением доставляется раньше
+func amqpPriority(priority int) uint8 {
+	priority = max(1, min(priority, FeedMaxPriority))
+	return uint8(FeedMaxPriority + 1 - priority)
+}
+
+// PublishFeedEvent публикует событие ленты для конкретного пользователя и ждет подтверждения брокером
 func (c *Client) PublishFeedEvent(ctx context.Context, userID string, event *model.FeedEvent) error {
-	// Создаем routing key для конкретного пользователя
-	routingKey := fmt.Sprintf(FeedEventRoutingKey, userID)
+	if err := c.PublishFeedEvents(ctx, []string{userID}, event, nil)[0]; err != nil {
+		return err
+	}
 
-	// Сериализуем событие
-	body, err := json.Marshal(event)
+	log.Printf("Published feed event for user %s: %s", userID, event.PostID)
+	return nil
+}
+
+// PublishFeedEvents публикует событие ленты для каждого пользователя и затем дожидается
+// подтверждений всех сообщений, не останавливая публикацию на каждом из них
+func (c *Client) PublishFeedEvents(ctx context.Context, userIDs []string, event *model.FeedEvent, eventIDs []int64) []error {
+	results := make([]error, len(userIDs))
+
+	pub, err := c.activePublisher(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return fillErrors(results, err)
 	}
 
-	// Публикуем сообщение
-	err = c.channel.PublishWithContext(ctx,
-		FeedEventsExchange, // exchange
-		routingKey,         // routing key
-		false,              // mandatory
-		false,              // immediate
-		amqp.Publishing{
+	confirmations := make([]*Confirmation, len(userIDs))
+	for i, userID := range userIDs {
+		body, err := marshalFeedEvent(event, eventIDs, i)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+
+		// Создаем routing key для конкретного пользователя
+		routingKey := fmt.Sprintf(FeedEventRoutingKey, userID)
+
+		confirmations[i], err = pub.publish(ctx, FeedEventsExchange, routingKey, amqp.Publishing{
 			ContentType:  "application/json",
 			Body:         body,
 			DeliveryMode: amqp.Persistent,
 			Timestamp:    time.Now(),
-		},
-	)
+		})
+		if err != nil {
+			results[i] = fmt.Errorf("failed to publish message: %w", err)
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, c.config.ConfirmTimeout())
+	defer cancel()
+
+	for i, confirmation := range confirmations {
+		if confirmation != nil {
+			results[i] = confirmation.Wait(waitCtx)
+		}
+	}
+
+	return results
+}
+
+// marshalFeedEvent сериализует событие ленты для i-го получателя с его номером события, если номера заданы
+func marshalFeedEvent(event *model.FeedEvent, eventIDs []int64, i int) ([]byte, error) {
+	if eventIDs != nil {
+		e := *event
+		e.EventID = eventIDs[i]
+		event = &e
+	}
+
+	body, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	log.Printf("Published feed event for user %s: %s", userID, event.PostID)
-	return nil
+	return body, nil
+}
+
+// fillErrors возвращает результаты пачки, в которых все сообщения завершились одной ошибкой
+func fillErrors(results []error, err error) []error {
+	for i := range results {
+		results[i] = err
+	}
+
+	return results
 }
 
-// PublishFeedUpdateTask публикует задачу обновления ленты
+// PublishFeedUpdateTask публикует задачу обновления ленты и ждет подтверждения брокером
 func (c *Client) PublishFeedUpdateTask(ctx context.Context, task *model.FeedUpdateTask) error {
 	log.Printf("DEBUG: Publishing task - UserID: '%s', PostID: '%s'", task.UserID, task.PostID)
 
@@ -144,17 +445,15 @@ func (c *Client) PublishFeedUpdateTask(ctx context.Context, task *model.FeedUpda
 	messageID := fmt.Sprintf("%s:%s:%d", task.UserID, task.PostID, time.Now().UnixNano())
 
 	// Публикуем сообщение в очередь материализации
-	err = c.channel.PublishWithContext(ctx,
+	err = c.publish(ctx,
 		"",                       // exchange (default)
 		FeedMaterializationQueue, // routing key
-		false,                    // mandatory
-		false,                    // immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
 			Body:         body,
 			DeliveryMode: amqp.Persistent,
 			Timestamp:    time.Now(),
-			Priority:     uint8(task.Priority),
+			Priority:     amqpPriority(task.Priority),
 			MessageId:    messageID,
 		},
 	)
@@ -166,123 +465,514 @@ func (c *Client) PublishFeedUpdateTask(ctx context.Context, task *model.FeedUpda
 	return nil
 }
 
-// ConsumeFeedMaterializationTasks потребляет задачи материализации ленты
-func (c *Client) ConsumeFeedMaterializationTasks(ctx context.Context, handler func(context.Context, *model.FeedUpdateTask) error) error {
-	msgs, err := c.channel.Consume(
-		FeedMaterializationQueue, // queue
-		"",                       // consumer
-		false,                    // auto-ack
-		false,                    // exclusive
-		false,                    // no-local
-		false,                    // no-wait
-		nil,                      // args
-	)
-	if err != nil {
-		return fmt.Errorf("failed to start consuming: %w", err)
-	}
+// ConsumeFeedMaterializationTasks потребляет задачи материализации ленты пулом обработчиков.
+// Задача, прерванная остановкой пула, возвращается в очередь без учета попытки
+func (c *Client) ConsumeFeedMaterializationTasks(ctx context.Context, handler func(context.Context, *model.FeedUpdateTask) error, opts queue.ConsumerOptions) (queue.Consumer, error) {
+	return c.startWorkerPool(ctx, FeedMaterializationQueue, materializationRetry, opts, func(ctx context.Context, msg amqp.Delivery) {
+		log.Printf("DEBUG: Received message from queue, delivery tag: %d, message ID: %s", msg.DeliveryTag, msg.MessageId)
+
+		var task model.FeedUpdateTask
+		if err := json.Unmarshal(msg.Body, &task); err != nil {
+			log.Printf("Error unmarshaling task: %v", err)
+			log.Printf("DEBUG: Raw message body: %s", string(msg.Body))
+			c.settleFailed(ctx, msg, materializationRetry, retryCount(msg.Headers)+1, err, false)
+			return
+		}
+
+		log.Printf("DEBUG: Unmarshaled task - UserID: '%s', PostID: '%s', Priority: %d", task.UserID, task.PostID, task.Priority)
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
+		// Проверяем валидность задачи перед обработкой
+		if !task.IsValid() {
+			log.Printf("ERROR: Invalid task received - UserID: '%s', PostID: '%s', rejecting message", task.UserID, task.PostID)
+			// Не переотправляем невалидные сообщения
+			c.settleFailed(ctx, msg, materializationRetry, retryCount(msg.Headers)+1, errors.New("invalid task"), false)
+			return
+		}
+
+		if err := handler(ctx, &task); err != nil {
+			if ctx.Err() != nil {
+				log.Printf("Task (message ID: %s) interrupted by worker shutdown, returning to queue: %v", msg.MessageId, err)
+				msg.Nack(false, true)
 				return
-			case msg := <-msgs:
-				log.Printf("DEBUG: Received message from queue, delivery tag: %d, message ID: %s", msg.DeliveryTag, msg.MessageId)
-
-				var task model.FeedUpdateTask
-				if err := json.Unmarshal(msg.Body, &task); err != nil {
-					log.Printf("Error unmarshaling task: %v", err)
-					log.Printf("DEBUG: Raw message body: %s", string(msg.Body))
-					msg.Nack(false, false)
-					continue
-				}
-
-				log.Printf("DEBUG: Unmarshaled task - UserID: '%s', PostID: '%s', Priority: %d", task.UserID, task.PostID, task.Priority)
-
-				// Проверяем валидность задачи перед обработкой
-				if task.UserID == "" || task.PostID == "" {
-					log.Printf("ERROR: Invalid task received - UserID: '%s', PostID: '%s', rejecting message", task.UserID, task.PostID)
-					msg.Nack(false, false) // Не переотправляем невалидные сообщения
-					continue
-				}
-
-				if err := handler(ctx, &task); err != nil {
-					log.Printf("Error processing task: %v", err)
-					msg.Nack(false, true) // requeue
-				} else {
-					log.Printf("DEBUG: Successfully processed task, acknowledging message (ID: %s)", msg.MessageId)
-					msg.Ack(false)
-				}
 			}
+
+			log.Printf("Error processing task: %v", err)
+			attempts := retryCount(msg.Headers) + 1
+			c.settleFailed(ctx, msg, materializationRetry, attempts, err, attempts < c.config.RetryMaxAttempts())
+		} else {
+			log.Printf("DEBUG: Successfully processed task, acknowledging message (ID: %s)", msg.MessageId)
+			msg.Ack(false)
 		}
-	}()
+	})
+}
+
+// settleFailed откладывает сообщение в очередь повторов или отправляет его в dead-letter очередь,
+// после чего подтверждает исходное сообщение. Если переложить сообщение не удалось,
+// оно возвращается в исходную очередь
+func (c *Client) settleFailed(ctx context.Context, msg amqp.Delivery, topology retryTopology, attempts int, cause error, retry bool) {
+	var err error
+	if retry {
+		err = c.scheduleRetry(ctx, msg, topology, attempts, cause)
+	} else {
+		err = c.deadLetter(ctx, msg, topology, attempts, cause)
+	}
+
+	if err != nil {
+		log.Printf("Error settling failed message (message ID: %s): %v", msg.MessageId, err)
+		msg.Nack(false, true)
+		return
+	}
+
+	msg.Ack(false)
+}
+
+// scheduleRetry публикует сообщение в очередь повторов с TTL, растущим экспоненциально с числом попыток
+func (c *Client) scheduleRetry(ctx context.Context, msg amqp.Delivery, topology retryTopology, attempts int, cause error) error {
+	delay := c.retryBackoff(attempts)
+
+	headers := copyHeaders(msg.Headers)
+	headers[HeaderRetryCount] = int32(attempts)
+	headers[HeaderError] = cause.Error()
+
+	publishing := republishing(msg, headers)
+	publishing.Expiration = strconv.FormatInt(delay.Milliseconds(), 10)
 
+	err := c.publish(ctx, "", topology.retryQueue, publishing)
+	if err != nil {
+		return fmt.Errorf("failed to publish message to retry queue: %w", err)
+	}
+
+	log.Printf("Message (ID: %s) scheduled for retry %d in %s via %s", msg.MessageId, attempts, delay, topology.retryQueue)
 	return nil
 }
 
-// ConsumeFeedEvents потребляет события ленты и передает userID из routing key
-func (c *Client) ConsumeFeedEvents(ctx context.Context, handler func(context.Context, string, *model.FeedEvent) error) error {
-	// Объявляем временную очередь для этого потребителя
-	q, err := c.channel.QueueDeclare(
-		"",    // name - server-named
-		true,  // durable
-		true,  // auto-delete
-		true,  // exclusive
-		false, // no-wait
-		nil,   // args
-	)
+// deadLetter публикует сообщение в dead-letter exchange с описанием ошибки в заголовках
+func (c *Client) deadLetter(ctx context.Context, msg amqp.Delivery, topology retryTopology, attempts int, cause error) error {
+	headers := copyHeaders(msg.Headers)
+	headers[HeaderRetryCount] = int32(attempts)
+	headers[HeaderError] = cause.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	err := c.publish(ctx, FeedDeadLetterExchange, topology.deadRoutingKey, republishing(msg, headers))
+	if err != nil {
+		return fmt.Errorf("failed to publish message to dead-letter exchange: %w", err)
+	}
+
+	log.Printf("Message (ID: %s) from %s dead-lettered after %d attempts: %v", msg.MessageId, topology.deadRoutingKey, attempts, cause)
+	return nil
+}
+
+// retryBackoff задержка перед повтором: RetryBackoff, удваиваемый с каждой попыткой, но не больше RetryMaxBackoff
+func (c *Client) retryBackoff(attempts int) time.Duration {
+	backoff := c.config.RetryBackoff()
+	maxBackoff := c.config.RetryMaxBackoff()
+
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// ListDeadLetters возвращает до limit задач из dead-letter очереди. Сообщения читаются
+// на отдельном канале без подтверждения и возвращаются в очередь при его закрытии
+func (c *Client) ListDeadLetters(ctx context.Context, limit int) ([]*model.DeadLetterTask, error) {
+	ch, err := c.openChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	var tasks []*model.DeadLetterTask
+	for len(tasks) < limit && ctx.Err() == nil {
+		msg, ok, err := ch.Get(FeedMaterializationDeadQueue, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dead-lettered task: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		tasks = append(tasks, toDeadLetterTask(msg))
+	}
+
+	return tasks, nil
+}
+
+// ReplayDeadLetters переносит до limit задач из dead-letter очереди в очередь материализации
+func (c *Client) ReplayDeadLetters(ctx context.Context, limit int) (int, error) {
+	ch, err := c.openChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	replayed := 0
+	for replayed < limit && ctx.Err() == nil {
+		msg, ok, err := ch.Get(FeedMaterializationDeadQueue, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to get dead-lettered task: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		// Задача начинает попытки заново
+		headers := copyHeaders(msg.Headers)
+		delete(headers, HeaderRetryCount)
+		delete(headers, HeaderError)
+		delete(headers, HeaderFailedAt)
+
+		// Задача подтверждается в dead-letter очереди только после подтверждения публикации
+		err = c.publish(ctx, "", FeedMaterializationQueue, republishing(msg, headers))
+		if err != nil {
+			_ = msg.Nack(false, true)
+			return replayed, fmt.Errorf("failed to replay task: %w", err)
+		}
+
+		if err = msg.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to ack dead-lettered task: %w", err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// PurgeDeadLetters удаляет все задачи из dead-letter очереди
+func (c *Client) PurgeDeadLetters(_ context.Context) (int, error) {
+	ch, err := c.openChannel()
 	if err != nil {
-		return fmt.Errorf("failed to declare ws queue: %w", err)
+		return 0, err
+	}
+	defer ch.Close()
+
+	count, err := ch.QueuePurge(FeedMaterializationDeadQueue, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead-letter queue: %w", err)
+	}
+
+	return count, nil
+}
+
+// toDeadLetterTask собирает описание задачи из сообщения dead-letter очереди
+func toDeadLetterTask(msg amqp.Delivery) *model.DeadLetterTask {
+	dl := &model.DeadLetterTask{
+		MessageID: msg.MessageId,
+		Attempts:  retryCount(msg.Headers),
+	}
+
+	if v, ok := msg.Headers[HeaderError].(string); ok {
+		dl.Error = v
+	}
+	if v, ok := msg.Headers[HeaderFailedAt].(string); ok {
+		dl.FailedAt, _ = time.Parse(time.RFC3339, v)
+	}
+
+	var task model.FeedUpdateTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		dl.Body = string(msg.Body)
+	} else {
+		dl.Task = &task
+	}
+
+	return dl
+}
+
+// retryCount возвращает количество неудачных попыток из заголовков сообщения
+func retryCount(headers amqp.Table) int {
+	switch v := headers[HeaderRetryCount].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	result := make(amqp.Table, len(headers)+3)
+	for k, v := range headers {
+		result[k] = v
 	}
 
-	// Подписка на все feed.event.*
-	if err := c.channel.QueueBind(q.Name, "feed.event.*", FeedEventsExchange, false, nil); err != nil {
-		return fmt.Errorf("failed to bind ws queue: %w", err)
+	return result
+}
+
+// republishing копирует полученное сообщение для повторной публикации
+func republishing(msg amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		Body:         msg.Body,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    msg.Timestamp,
+		Priority:     msg.Priority,
+		MessageId:    msg.MessageId,
+		Type:         msg.Type,
 	}
+}
 
-	msgs, err := c.channel.Consume(q.Name, "", true, true, false, false, nil)
+// PublishDomainEvent публикует событие из outbox с routing key по типу события.
+// Идентификатор записи outbox передается как message id, чтобы потребитель мог отсеять повторы.
+// Ошибка возвращается, пока брокер не подтвердил событие, поэтому relay не отметит его отправленным раньше времени
+func (c *Client) PublishDomainEvent(ctx context.Context, message *model.OutboxMessage) error {
+	err := c.publish(ctx,
+		DomainEventsExchange, // exchange
+		message.EventType,    // routing key
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         message.Payload,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    message.CreatedAt,
+			MessageId:    message.ID,
+			Type:         message.EventType,
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("failed to start consuming ws events: %w", err)
+		return fmt.Errorf("failed to publish domain event: %w", err)
 	}
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
+	return nil
+}
+
+// ConsumeDomainEvents потребляет доменные события. Если обработчик вернул ошибку, событие
+// откладывается в очередь повторов с нарастающей задержкой, а после RetryMaxAttempts попыток
+// уходит в dead-letter очередь domain.events.handlers.dead
+func (c *Client) ConsumeDomainEvents(ctx context.Context, handler func(context.Context, string, []byte) error) error {
+	setup := func(ch *amqp.Channel) (<-chan amqp.Delivery, error) {
+		msgs, err := ch.Consume(
+			DomainEventsQueue, // queue
+			"",                // consumer
+			false,             // auto-ack
+			false,             // exclusive
+			false,             // no-local
+			false,             // no-wait
+			nil,               // args
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start consuming domain events: %w", err)
+		}
+		return msgs, nil
+	}
+
+	return c.consume(ctx, DomainEventsQueue, setup, func(msg amqp.Delivery) {
+		// После повтора событие приходит с routing key очереди, тип сохраняется в свойстве type
+		eventType := msg.Type
+		if eventType == "" {
+			eventType = msg.RoutingKey
+		}
+
+		if err := handler(ctx, eventType, msg.Body); err != nil {
+			if ctx.Err() != nil {
+				msg.Nack(false, true)
 				return
-			case msg := <-msgs:
-				var ev model.FeedEvent
-				if err := json.Unmarshal(msg.Body, &ev); err != nil {
-					log.Printf("Error unmarshaling feed event: %v", err)
-					continue
-				}
-				// routing key вида feed.event.{user_id}
-				rk := msg.RoutingKey
-				parts := strings.Split(rk, ".")
-				if len(parts) < 3 {
-					continue
-				}
-				userID := parts[2]
-				if err := handler(ctx, userID, &ev); err != nil {
-					log.Printf("Error handling ws event for user %s: %v", userID, err)
-				}
 			}
+
+			log.Printf("Error handling domain event %s (message ID: %s): %v", eventType, msg.MessageId, err)
+			attempts := retryCount(msg.Headers) + 1
+			c.settleFailed(ctx, msg, domainEventsRetry, attempts, err, attempts < c.config.RetryMaxAttempts())
+			return
+		}
+		msg.Ack(false)
+	})
+}
+
+// PublishAnnouncement публикует объявление администратора и ждет подтверждения брокером
+func (c *Client) PublishAnnouncement(ctx context.Context, announcement *model.Announcement) error {
+	body, err := json.Marshal(announcement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal announcement: %w", err)
+	}
+
+	err = c.publish(ctx, FeedEventsExchange, AnnouncementRoutingKey, amqp.Publishing{
+		ContentType:  "application/json",
+		Body:         body,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    announcement.ID,
+		Timestamp:    announcement.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish announcement: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeAnnouncements потребляет объявления администратора из временной очереди узла
+func (c *Client) ConsumeAnnouncements(ctx context.Context, handler func(context.Context, *model.Announcement) error) error {
+	// Как и очередь WebSocket событий, очередь объявлений своя у каждого узла и объявляется
+	// заново после переподключения
+	setup := func(ch *amqp.Channel) (<-chan amqp.Delivery, error) {
+		q, err := ch.QueueDeclare(
+			"",    // name - server-named
+			true,  // durable
+			true,  // auto-delete
+			true,  // exclusive
+			false, // no-wait
+			nil,   // args
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to declare announcements queue: %w", err)
+		}
+
+		if err := ch.QueueBind(q.Name, AnnouncementRoutingKey, FeedEventsExchange, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to bind announcements queue: %w", err)
+		}
+
+		msgs, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start consuming announcements: %w", err)
+		}
+		return msgs, nil
+	}
+
+	return c.consume(ctx, AnnouncementsQueuePrefix+"*", setup, func(msg amqp.Delivery) {
+		var announcement model.Announcement
+		if err := json.Unmarshal(msg.Body, &announcement); err != nil {
+			log.Printf("Error unmarshaling announcement: %v", err)
+			return
+		}
+		if err := handler(ctx, &announcement); err != nil {
+			log.Printf("Error handling announcement %s: %v", announcement.ID, err)
 		}
-	}()
+	})
+}
+
+// PublishUserMessage публикует WebSocket сообщение для пользователя и ждет подтверждения брокером
+func (c *Client) PublishUserMessage(ctx context.Context, userID string, message *model.WebSocketMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal websocket message: %w", err)
+	}
+
+	err = c.publish(ctx, FeedEventsExchange, fmt.Sprintf(UserMessageRoutingKey, userID), amqp.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+		Timestamp:   time.Now(),
+		Type:        message.Type,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish websocket message: %w", err)
+	}
+
 	return nil
 }
 
-// Close закрывает соединение с RabbitMQ
-func (c *Client) Close() error {
-	if c.channel != nil {
-		if err := c.channel.Close(); err != nil {
-			log.Printf("Error closing channel: %v", err)
+// ConsumeUserEvents потребляет события ленты и WebSocket сообщения пользователей, привязанных
+// BindUserEvents, и передает userID из routing key
+func (c *Client) ConsumeUserEvents(ctx context.Context, handlers queue.UserEventHandlers) error {
+	// Временная очередь исчезает вместе с соединением, поэтому после переподключения объявляется заново
+	// вместе со всеми привязками
+	setup := func(ch *amqp.Channel) (<-chan amqp.Delivery, error) {
+		c.feedMu.Lock()
+		defer c.feedMu.Unlock()
+
+		q, err := ch.QueueDeclare(
+			"",    // name - server-named
+			true,  // durable
+			true,  // auto-delete
+			true,  // exclusive
+			false, // no-wait
+			nil,   // args
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to declare ws queue: %w", err)
 		}
+
+		// Узел получает события только тех пользователей, у которых на нем есть соединения
+		for userID := range c.feedBindings {
+			if err := ch.QueueBind(q.Name, fmt.Sprintf(UserEventsBindingKey, userID), FeedEventsExchange, false, nil); err != nil {
+				return nil, fmt.Errorf("failed to bind ws queue: %w", err)
+			}
+		}
+
+		msgs, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start consuming ws events: %w", err)
+		}
+
+		c.feedChannel = ch
+		c.feedQueue = q.Name
+
+		return msgs, nil
+	}
+
+	return c.consume(ctx, FeedWebsocketQueuePrefix+"*", setup, func(msg amqp.Delivery) {
+		HandleUserEvent(ctx, handlers, msg.RoutingKey, msg.Body)
+	})
+}
+
+// HandleUserEvent разбирает сообщение очереди WebSocket событий узла по routing key
+// feed.event.{user_id} или feed.message.{user_id} и передает его обработчику.
+// Ошибки только логируются: сообщения подтверждаются автоматически
+func HandleUserEvent(ctx context.Context, handlers queue.UserEventHandlers, routingKey string, body []byte) {
+	parts := strings.Split(routingKey, ".")
+	if len(parts) < 3 {
+		return
 	}
-	if c.conn != nil {
-		if err := c.conn.Close(); err != nil {
-			log.Printf("Error closing connection: %v", err)
+	kind, userID := parts[1], parts[2]
+
+	var err error
+	switch kind {
+	case "event":
+		var ev model.FeedEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			log.Printf("Error unmarshaling feed event: %v", err)
+			return
 		}
+		err = handlers.FeedEvent(ctx, userID, &ev)
+	case "message":
+		var message model.WebSocketMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			log.Printf("Error unmarshaling websocket message: %v", err)
+			return
+		}
+		err = handlers.Message(ctx, userID, &message)
+	default:
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error handling ws %s for user %s: %v", kind, userID, err)
+	}
+}
+
+// BindUserEvents привязывает очередь WebSocket событий узла к событиям и сообщениям пользователя.
+// Привязка запоминается до UnbindUserEvents: если очередь еще не объявлена или соединение
+// разорвано, она будет создана при объявлении очереди
+func (c *Client) BindUserEvents(_ context.Context, userID string) error {
+	c.feedMu.Lock()
+	defer c.feedMu.Unlock()
+
+	c.feedBindings[userID] = struct{}{}
+	if c.feedChannel == nil {
+		return nil
+	}
+
+	if err := c.feedChannel.QueueBind(c.feedQueue, fmt.Sprintf(UserEventsBindingKey, userID), FeedEventsExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind ws queue for user %s: %w", userID, err)
 	}
+
+	return nil
+}
+
+// UnbindUserEvents отвязывает очередь WebSocket событий узла от событий и сообщений пользователя
+func (c *Client) UnbindUserEvents(_ context.Context, userID string) error {
+	c.feedMu.Lock()
+	defer c.feedMu.Unlock()
+
+	delete(c.feedBindings, userID)
+	if c.feedChannel == nil {
+		return nil
+	}
+
+	if err := c.feedChannel.QueueUnbind(c.feedQueue, fmt.Sprintf(UserEventsBindingKey, userID), FeedEventsExchange, nil); err != nil {
+		return fmt.Errorf("failed to unbind ws queue for user %s: %w", userID, err)
+	}
+
 	return nil
 }
@@ -0,0 +1,418 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"otus-project/internal/config"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ConnectionState состояние соединения клиента с брокером
+type ConnectionState string
+
+const (
+	StateConnected    ConnectionState = "connected"
+	StateReconnecting ConnectionState = "reconnecting"
+	StateClosed       ConnectionState = "closed"
+)
+
+var (
+	// ErrNotConnected соединения с брокером нет, и оно не восстановилось за PublishWait
+	ErrNotConnected = errors.New("rabbitmq is not connected")
+	// ErrClosed клиент закрыт
+	ErrClosed = errors.New("rabbitmq client is closed")
+)
+
+// Client клиент RabbitMQ, который сам восстанавливает соединение: после разрыва он
+// переподключается с нарастающей задержкой, заново объявляет exchange и очереди
+// и возобновляет потребление
+type Client struct {
+	config config.RabbitMQConfig
+
+	mu   sync.RWMutex
+	conn *amqp.Connection
+	// channel канал настройки топологии, закрывается после setupExchangeAndQueues
+	channel   *amqp.Channel
+	publisher *publisher
+	state     ConnectionState
+	lastErr   error
+	// connected закрывается, когда соединение установлено, и заменяется новым при разрыве
+	connected chan struct{}
+
+	// feedMu защищает привязки очереди WebSocket событий узла
+	feedMu sync.Mutex
+	// feedBindings пользователи, события ленты и сообщения которых получает узел. Привязки восстанавливаются
+	// при каждом переобъявлении очереди после разрыва соединения
+	feedBindings map[string]struct{}
+	// feedChannel и feedQueue канал потребителя и имя текущей очереди WebSocket событий
+	feedChannel *amqp.Channel
+	feedQueue   string
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewClient создает новый клиент RabbitMQ. Первое подключение должно пройти успешно,
+// дальше соединение восстанавливается автоматически
+func NewClient(cfg config.RabbitMQConfig) (*Client, error) {
+	client := &Client{
+		config:       cfg,
+		connected:    make(chan struct{}),
+		feedBindings: make(map[string]struct{}),
+		closed:       make(chan struct{}),
+	}
+
+	if err := client.connect(); err != nil {
+		return nil, err
+	}
+
+	go client.supervise()
+
+	return client, nil
+}
+
+// connect устанавливает соединение, настраивает топологию и открывает канал публикации
+func (c *Client) connect() error {
+	conn, err := amqp.Dial(c.config.DSN())
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.closed:
+		conn.Close()
+		return ErrClosed
+	default:
+	}
+
+	c.conn = conn
+	c.channel = ch
+
+	// Настраиваем exchange и очереди
+	if err := c.setupExchangeAndQueues(); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to setup exchange and queues: %w", err)
+	}
+
+	// Ошибка закрытия канала настройки не мешает работе соединения
+	_ = c.channel.Close()
+
+	pub, err := newPublisher(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.publisher = pub
+	c.state = StateConnected
+	c.lastErr = nil
+	close(c.connected)
+
+	return nil
+}
+
+// supervise следит за соединением и каналом публикации и переподключается после их закрытия
+func (c *Client) supervise() {
+	for {
+		c.mu.RLock()
+		connClosed := c.conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := c.publisher.ch.NotifyClose(make(chan *amqp.Error, 1))
+		c.mu.RUnlock()
+
+		var cause *amqp.Error
+		select {
+		case <-c.closed:
+			return
+		case cause = <-connClosed:
+		case cause = <-chClosed:
+		}
+
+		c.markDisconnected(cause)
+		if !c.reconnect() {
+			return
+		}
+	}
+}
+
+// markDisconnected переводит клиент в состояние переподключения и закрывает остатки соединения.
+// Потребители видят закрытие своих каналов доставки и ждут нового соединения
+func (c *Client) markDisconnected(cause *amqp.Error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == StateClosed {
+		return
+	}
+
+	c.state = StateReconnecting
+	c.connected = make(chan struct{})
+	if cause != nil {
+		c.lastErr = cause
+	} else {
+		c.lastErr = errors.New("connection closed")
+	}
+
+	// Закрытие уже закрытого соединения возвращает ошибку, которая здесь не важна
+	_ = c.conn.Close()
+
+	log.Printf("RabbitMQ connection lost: %v", c.lastErr)
+}
+
+// reconnect переподключается с задержкой от ReconnectBackoff до ReconnectMaxBackoff.
+// Возвращает false, если клиент закрыли раньше
+func (c *Client) reconnect() bool {
+	backoff := c.config.ReconnectBackoff()
+
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-c.closed:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+
+		err := c.connect()
+		if err == nil {
+			log.Printf("Reconnected to RabbitMQ after %d attempts", attempt)
+			return true
+		}
+		if errors.Is(err, ErrClosed) {
+			return false
+		}
+
+		c.mu.Lock()
+		c.lastErr = err
+		c.mu.Unlock()
+
+		log.Printf("RabbitMQ reconnect attempt %d failed: %v", attempt, err)
+		backoff = min(backoff*2, c.config.ReconnectMaxBackoff())
+	}
+}
+
+// waitConnected ждет соединения с брокером
+func (c *Client) waitConnected(ctx context.Context) error {
+	c.mu.RLock()
+	state, connected := c.state, c.connected
+	c.mu.RUnlock()
+
+	switch state {
+	case StateConnected:
+		return nil
+	case StateClosed:
+		return ErrClosed
+	}
+
+	select {
+	case <-connected:
+		return nil
+	case <-c.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// activePublisher возвращает канал публикации. Пока соединения нет,
+// ждет переподключения не дольше PublishWait
+func (c *Client) activePublisher(ctx context.Context) (*publisher, error) {
+	c.mu.RLock()
+	state, pub := c.state, c.publisher
+	c.mu.RUnlock()
+
+	if state == StateConnected {
+		return pub, nil
+	}
+	if state == StateClosed {
+		return nil, ErrClosed
+	}
+
+	wait := c.config.PublishWait()
+	if wait <= 0 {
+		return nil, ErrNotConnected
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	if err := c.waitConnected(waitCtx); err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrNotConnected
+		}
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.publisher, nil
+}
+
+// publish публикует сообщение и ждет подтверждения брокером не дольше ConfirmTimeout
+func (c *Client) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	pub, err := c.activePublisher(ctx)
+	if err != nil {
+		return err
+	}
+
+	confirmation, err := pub.publish(ctx, exchange, routingKey, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.ConfirmTimeout())
+	defer cancel()
+
+	return confirmation.Wait(ctx)
+}
+
+// openChannel открывает отдельный канал на текущем соединении
+func (c *Client) openChannel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	state, conn := c.state, c.conn
+	c.mu.RUnlock()
+
+	switch state {
+	case StateClosed:
+		return nil, ErrClosed
+	case StateReconnecting:
+		return nil, ErrNotConnected
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	return ch, nil
+}
+
+// consume потребляет очередь на отдельном канале и подписывается заново после каждого
+// переподключения. setup объявляет нужные потребителю очереди и начинает потребление.
+// Первая подписка выполняется сразу, чтобы ошибка настройки вернулась вызывающему
+func (c *Client) consume(ctx context.Context, name string, setup func(ch *amqp.Channel) (<-chan amqp.Delivery, error), handle func(msg amqp.Delivery)) error {
+	ch, msgs, err := c.subscribe(setup)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			drain(ctx, msgs, handle)
+			_ = ch.Close()
+
+			for {
+				if err := c.waitConnected(ctx); err != nil {
+					return
+				}
+
+				if ch, msgs, err = c.subscribe(setup); err == nil {
+					log.Printf("Consumer %s resubscribed", name)
+					break
+				}
+
+				log.Printf("Error resubscribing consumer %s: %v", name, err)
+				timer := time.NewTimer(c.config.ReconnectBackoff())
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// subscribe открывает канал потребителя и начинает потребление
+func (c *Client) subscribe(setup func(ch *amqp.Channel) (<-chan amqp.Delivery, error)) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	ch, err := c.openChannel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msgs, err := setup(ch)
+	if err != nil {
+		_ = ch.Close()
+		return nil, nil, err
+	}
+
+	return ch, msgs, nil
+}
+
+// drain передает сообщения обработчику, пока канал доставки не закрыт или ctx не отменен
+func drain(ctx context.Context, msgs <-chan amqp.Delivery, handle func(msg amqp.Delivery)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			handle(msg)
+		}
+	}
+}
+
+// State возвращает текущее состояние соединения
+func (c *Client) State() ConnectionState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.state
+}
+
+// Healthy возвращает ошибку, если соединения с брокером сейчас нет
+func (c *Client) Healthy(_ context.Context) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	switch c.state {
+	case StateConnected:
+		return nil
+	case StateClosed:
+		return ErrClosed
+	default:
+		return fmt.Errorf("%w: %v", ErrNotConnected, c.lastErr)
+	}
+}
+
+// Close закрывает соединение с RabbitMQ и останавливает переподключение
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.state = StateClosed
+		if c.publisher != nil {
+			if err := c.publisher.close(); err != nil {
+				log.Printf("Error closing publish channel: %v", err)
+			}
+		}
+		if c.conn != nil {
+			if err := c.conn.Close(); err != nil {
+				log.Printf("Error closing connection: %v", err)
+			}
+		}
+	})
+
+	return nil
+}
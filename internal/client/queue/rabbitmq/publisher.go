@@ -0,0 +1,180 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"otus-project/internal/client/queue"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// HeaderPublishSeq номер публикации на канале. По нему возвращенное брокером сообщение
+	// сопоставляется с ожидающим подтверждения
+	HeaderPublishSeq = "x-publish-seq"
+
+	// confirmBufferSize размер буфера подтверждений от брокера
+	confirmBufferSize = 256
+)
+
+// Confirmation результат публикации одного сообщения
+type Confirmation struct {
+	done chan struct{}
+	err  error
+
+	// returned описание возврата, если брокер не смог маршрутизировать сообщение
+	returned string
+}
+
+// Wait ждет подтверждения брокером. Возвращает queue.ErrUnroutable, если сообщение не попало
+// ни в одну очередь, и queue.ErrNacked, если брокер его не принял
+func (c *Confirmation) Wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for publish confirmation: %w", ctx.Err())
+	}
+}
+
+// publisher канал публикации в режиме подтверждений. Все сообщения публикуются с mandatory,
+// поэтому брокер возвращает сообщения, для которых нет ни одной очереди
+type publisher struct {
+	ch *amqp.Channel
+
+	// publishMu публикация и получение номера сообщения должны идти подряд
+	publishMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint64]*Confirmation
+	closed  bool
+}
+
+// newPublisher открывает канал публикации и включает на нем подтверждения
+func newPublisher(conn *amqp.Connection) (*publisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open publish channel: %w", err)
+	}
+
+	if err = ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	p := &publisher{
+		ch:      ch,
+		pending: make(map[uint64]*Confirmation),
+	}
+
+	// Канал возвратов без буфера: библиотека передает возврат до обработки подтверждения того же
+	// сообщения, поэтому run успевает отметить возврат раньше, чем получит подтверждение
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, confirmBufferSize))
+	returns := ch.NotifyReturn(make(chan amqp.Return))
+	go p.run(confirms, returns)
+
+	return p, nil
+}
+
+// run разбирает возвраты и подтверждения до закрытия канала, после чего завершает
+// ожидающие публикации ошибкой
+func (p *publisher) run(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for confirms != nil || returns != nil {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			p.markReturned(ret)
+		case confirm, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			p.resolve(confirm)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for tag, c := range p.pending {
+		c.err = fmt.Errorf("%w: channel closed before confirmation", ErrNotConnected)
+		close(c.done)
+		delete(p.pending, tag)
+	}
+}
+
+// markReturned отмечает сообщение, возвращенное брокером
+func (p *publisher) markReturned(ret amqp.Return) {
+	tag, ok := ret.Headers[HeaderPublishSeq].(int64)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.pending[uint64(tag)]; ok {
+		c.returned = fmt.Sprintf("%d %s (exchange %q, routing key %q)", ret.ReplyCode, ret.ReplyText, ret.Exchange, ret.RoutingKey)
+	}
+}
+
+// resolve завершает ожидание публикации по подтверждению брокера
+func (p *publisher) resolve(confirm amqp.Confirmation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.pending[confirm.DeliveryTag]
+	if !ok {
+		return
+	}
+	delete(p.pending, confirm.DeliveryTag)
+
+	switch {
+	case !confirm.Ack:
+		c.err = queue.ErrNacked
+	case c.returned != "":
+		c.err = fmt.Errorf("%w: %s", queue.ErrUnroutable, c.returned)
+	}
+	close(c.done)
+}
+
+// publish публикует сообщение с mandatory и возвращает ожидание его подтверждения
+func (p *publisher) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) (*Confirmation, error) {
+	p.publishMu.Lock()
+	defer p.publishMu.Unlock()
+
+	tag := p.ch.GetNextPublishSeqNo()
+
+	// Заголовки копируются, чтобы не менять заголовки полученного сообщения при повторной публикации
+	msg.Headers = copyHeaders(msg.Headers)
+	msg.Headers[HeaderPublishSeq] = int64(tag)
+
+	c := &Confirmation{done: make(chan struct{})}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	p.pending[tag] = c
+	p.mu.Unlock()
+
+	if err := p.ch.PublishWithContext(ctx, exchange, routingKey, true, false, msg); err != nil {
+		p.mu.Lock()
+		delete(p.pending, tag)
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// close закрывает канал публикации
+func (p *publisher) close() error {
+	return p.ch.Close()
+}
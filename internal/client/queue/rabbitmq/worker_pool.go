@@ -0,0 +1,262 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"otus-project/internal/client/queue"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var _ queue.Consumer = (*workerPool)(nil)
+
+// workerPool потребитель очереди с пулом обработчиков. Брокер выдает не больше Prefetch
+// неподтвержденных сообщений, а каждый обработчик работает со своим контекстом,
+// который отменяется только при остановке по истечении времени ожидания
+type workerPool struct {
+	client   *Client
+	queue    string
+	prefetch int
+	// topology очередь повторов и dead-letter, куда уходит сообщение, обработка которого вызвала панику
+	topology retryTopology
+	// handle обрабатывает сообщение и обязан подтвердить или отклонить его
+	handle func(ctx context.Context, msg amqp.Delivery)
+
+	jobs     chan amqp.Delivery
+	inFlight atomic.Int64
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	ch      *amqp.Channel
+	tag     string
+	cancels []context.CancelFunc
+
+	// runCtx отменяется при остановке и прерывает получение и переподписку
+	runCtx     context.Context
+	runCancel  context.CancelFunc
+	dispatched chan struct{}
+	stopOnce   sync.Once
+}
+
+// startWorkerPool подписывается на очередь и запускает workers обработчиков
+func (c *Client) startWorkerPool(ctx context.Context, queueName string, topology retryTopology, opts queue.ConsumerOptions, handle func(ctx context.Context, msg amqp.Delivery)) (*workerPool, error) {
+	workers := max(opts.Workers, 1)
+
+	p := &workerPool{
+		client:     c,
+		queue:      queueName,
+		prefetch:   max(opts.Prefetch, workers),
+		topology:   topology,
+		handle:     handle,
+		jobs:       make(chan amqp.Delivery),
+		dispatched: make(chan struct{}),
+	}
+	p.runCtx, p.runCancel = context.WithCancel(context.Background())
+
+	msgs, err := p.subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	// Контексты обработчиков не наследуют отмену ctx: уже начатая задача завершается
+	// или прерывается только при остановке пула
+	workerCtx := context.WithoutCancel(ctx)
+	for range workers {
+		wctx, cancel := context.WithCancel(workerCtx)
+		p.cancels = append(p.cancels, cancel)
+
+		p.wg.Add(1)
+		go p.work(wctx)
+	}
+
+	go p.dispatch(msgs)
+
+	// Отмена ctx останавливает пул без ожидания задач
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = p.Stop(ctx)
+		case <-p.runCtx.Done():
+		}
+	}()
+
+	return p, nil
+}
+
+// subscribe открывает канал, задает prefetch и подписывается на очередь с уникальным тегом,
+// по которому подписку можно отменить, не закрывая канал с неподтвержденными сообщениями
+func (p *workerPool) subscribe() (<-chan amqp.Delivery, error) {
+	ch, err := p.client.openChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ch.Qos(p.prefetch, 0, false); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	tag := fmt.Sprintf("%s.%s", p.queue, uuid.New().String())
+	msgs, err := ch.Consume(
+		p.queue, // queue
+		tag,     // consumer
+		false,   // auto-ack
+		false,   // exclusive
+		false,   // no-local
+		false,   // no-wait
+		nil,     // args
+	)
+	if err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("failed to start consuming: %w", err)
+	}
+
+	p.mu.Lock()
+	p.ch, p.tag = ch, tag
+	p.mu.Unlock()
+
+	return msgs, nil
+}
+
+// dispatch передает сообщения свободным обработчикам и подписывается заново после переподключения
+func (p *workerPool) dispatch(msgs <-chan amqp.Delivery) {
+	defer close(p.dispatched)
+	defer close(p.jobs)
+
+	for {
+		p.forward(msgs)
+		if p.runCtx.Err() != nil {
+			return
+		}
+
+		// Канал закрылся вместе с соединением, неподтвержденные сообщения брокер вернул в очередь
+		p.closeChannel()
+
+		for {
+			if err := p.client.waitConnected(p.runCtx); err != nil {
+				return
+			}
+
+			var err error
+			if msgs, err = p.subscribe(); err == nil {
+				log.Printf("Consumer %s resubscribed", p.queue)
+				break
+			}
+
+			log.Printf("Error resubscribing consumer %s: %v", p.queue, err)
+			timer := time.NewTimer(p.client.config.ReconnectBackoff())
+			select {
+			case <-p.runCtx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// forward передает сообщения обработчикам, пока канал доставки не закрыт или пул не остановлен.
+// Сообщения, полученные после остановки, сразу возвращаются в очередь
+func (p *workerPool) forward(msgs <-chan amqp.Delivery) {
+	for {
+		select {
+		case <-p.runCtx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+
+			select {
+			case p.jobs <- msg:
+			case <-p.runCtx.Done():
+				_ = msg.Nack(false, true)
+				return
+			}
+		}
+	}
+}
+
+// work обрабатывает задачи, пока dispatch не закроет канал задач
+func (p *workerPool) work(ctx context.Context) {
+	defer p.wg.Done()
+
+	for msg := range p.jobs {
+		p.inFlight.Add(1)
+		p.safeHandle(ctx, msg)
+		p.inFlight.Add(-1)
+	}
+}
+
+// safeHandle обрабатывает сообщение. Паника обработчика не останавливает процесс:
+// сообщение сразу уходит в dead-letter очередь, повтор скорее всего упадет так же
+func (p *workerPool) safeHandle(ctx context.Context, msg amqp.Delivery) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic handling message %s from %s: %v\n%s", msg.MessageId, p.queue, r, debug.Stack())
+			p.client.settleFailed(ctx, msg, p.topology, retryCount(msg.Headers)+1, fmt.Errorf("panic: %v", r), false)
+		}
+	}()
+
+	p.handle(ctx, msg)
+}
+
+// Stop отменяет подписку, ждет задачи, уже выданные обработчикам, и закрывает канал.
+// Если ctx отменен раньше, контексты обработчиков отменяются, а их задачи возвращаются в очередь
+func (p *workerPool) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		if err := p.ch.Cancel(p.tag, false); err != nil {
+			log.Printf("Error cancelling consumer %s: %v", p.tag, err)
+		}
+		p.mu.Unlock()
+
+		p.runCancel()
+	})
+
+	<-p.dispatched
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("consumer %s stopped with %d tasks in flight: %w", p.queue, p.inFlight.Load(), ctx.Err())
+		p.cancelWorkers()
+		<-done
+	}
+
+	p.cancelWorkers()
+	p.closeChannel()
+	return err
+}
+
+// cancelWorkers отменяет контексты обработчиков
+func (p *workerPool) cancelWorkers() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cancel := range p.cancels {
+		cancel()
+	}
+}
+
+// closeChannel закрывает текущий канал потребителя
+func (p *workerPool) closeChannel() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Канал мог закрыться вместе с соединением
+	_ = p.ch.Close()
+}
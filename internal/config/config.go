@@ -1,6 +1,9 @@
 package config
 
 import (
+	"os"
+	"strconv"
+
 	"github.com/joho/godotenv"
 )
 
@@ -12,3 +15,13 @@ func Load(path string) error {
 
 	return nil
 }
+
+// intFromEnv читает целое значение из переменной окружения, если она задана
+func intFromEnv(name string, defaultValue int) (int, error) {
+	value := os.Getenv(name)
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
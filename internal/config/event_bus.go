@@ -0,0 +1,101 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	eventBusWorkersEnv        = "EVENT_BUS_WORKERS"
+	eventBusQueueSizeEnv      = "EVENT_BUS_QUEUE_SIZE"
+	eventBusMaxAttemptsEnv    = "EVENT_BUS_MAX_ATTEMPTS"
+	eventBusRetryBackoffEnv   = "EVENT_BUS_RETRY_BACKOFF_MS"
+	eventBusHandlerTimeoutEnv = "EVENT_BUS_HANDLER_TIMEOUT_SEC"
+)
+
+// EventBusConfig настройки подписчиков Event Bus по умолчанию
+type EventBusConfig interface {
+	// Workers сколько обработчиков подписчика выполняется одновременно
+	Workers() int
+	// QueueSize сколько событий ждет свободного обработчика, дальше публикация блокируется
+	QueueSize() int
+	// MaxAttempts сколько раз вызывается обработчик, вернувший ошибку
+	MaxAttempts() int
+	// RetryBackoff задержка перед первым повтором, далее удваивается
+	RetryBackoff() time.Duration
+	// HandlerTimeout максимальное время одного вызова обработчика
+	HandlerTimeout() time.Duration
+}
+
+type eventBusConfig struct {
+	workers        int
+	queueSize      int
+	maxAttempts    int
+	retryBackoff   time.Duration
+	handlerTimeout time.Duration
+}
+
+func NewEventBusConfig() (EventBusConfig, error) {
+	workers, err := intFromEnv(eventBusWorkersEnv, 4)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse event bus workers")
+	}
+	if workers <= 0 {
+		return nil, errors.New("event bus workers must be positive")
+	}
+
+	queueSize, err := intFromEnv(eventBusQueueSizeEnv, 100)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse event bus queue size")
+	}
+	if queueSize < 0 {
+		return nil, errors.New("event bus queue size must not be negative")
+	}
+
+	maxAttempts, err := intFromEnv(eventBusMaxAttemptsEnv, 3)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse event bus max attempts")
+	}
+	if maxAttempts <= 0 {
+		return nil, errors.New("event bus max attempts must be positive")
+	}
+
+	retryBackoff, err := intFromEnv(eventBusRetryBackoffEnv, 100)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse event bus retry backoff")
+	}
+
+	handlerTimeout, err := intFromEnv(eventBusHandlerTimeoutEnv, 30)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse event bus handler timeout")
+	}
+
+	return &eventBusConfig{
+		workers:        workers,
+		queueSize:      queueSize,
+		maxAttempts:    maxAttempts,
+		retryBackoff:   time.Duration(retryBackoff) * time.Millisecond,
+		handlerTimeout: time.Duration(handlerTimeout) * time.Second,
+	}, nil
+}
+
+func (cfg *eventBusConfig) Workers() int {
+	return cfg.workers
+}
+
+func (cfg *eventBusConfig) QueueSize() int {
+	return cfg.queueSize
+}
+
+func (cfg *eventBusConfig) MaxAttempts() int {
+	return cfg.maxAttempts
+}
+
+func (cfg *eventBusConfig) RetryBackoff() time.Duration {
+	return cfg.retryBackoff
+}
+
+func (cfg *eventBusConfig) HandlerTimeout() time.Duration {
+	return cfg.handlerTimeout
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	feedCelebrityThresholdEnvName = "FEED_CELEBRITY_THRESHOLD"
+	feedCacheMaxLengthEnvName     = "FEED_CACHE_MAX_LENGTH"
+	feedCacheTTLEnvName           = "FEED_CACHE_TTL_SEC"
+	feedRetentionMaxEntriesEnv    = "FEED_RETENTION_MAX_ENTRIES"
+	feedRetentionMaxAgeEnv        = "FEED_RETENTION_MAX_AGE_HOURS"
+	feedRetentionIntervalEnv      = "FEED_RETENTION_INTERVAL_SEC"
+	feedRetentionBatchSizeEnv     = "FEED_RETENTION_BATCH_SIZE"
+	feedJobTimeoutEnv             = "FEED_JOB_TIMEOUT_SEC"
+	feedJobMaxAttemptsEnv         = "FEED_JOB_MAX_ATTEMPTS"
+	feedJobRetryBackoffEnv        = "FEED_JOB_RETRY_BACKOFF_SEC"
+	feedJobRetryMaxBackoffEnv     = "FEED_JOB_RETRY_MAX_BACKOFF_SEC"
+	feedJobReaperIntervalEnv      = "FEED_JOB_REAPER_INTERVAL_SEC"
+	feedFanOutChunkSizeEnv        = "FEED_FANOUT_CHUNK_SIZE"
+	feedWorkerCountEnv            = "FEED_WORKER_COUNT"
+	feedWorkerPrefetchEnv         = "FEED_WORKER_PREFETCH"
+	feedWorkerDrainTimeoutEnv     = "FEED_WORKER_DRAIN_TIMEOUT_SEC"
+)
+
+type FeedConfig interface {
+	// CelebrityThreshold количество друзей, начиная с которого посты автора
+	// не раскладываются по лентам при записи, а подмешиваются при чтении
+	CelebrityThreshold() int
+	// CacheMaxLength максимальное количество постов ленты, хранимых в Redis
+	CacheMaxLength() int
+	// CacheTTL время жизни ленты и тел постов в Redis
+	CacheTTL() time.Duration
+	// RetentionMaxEntries сколько последних записей ленты хранить для каждого пользователя, 0 - без ограничения
+	RetentionMaxEntries() int
+	// RetentionMaxAge максимальный возраст записи ленты, 0 - без ограничения
+	RetentionMaxAge() time.Duration
+	// RetentionInterval период запуска очистки ленты
+	RetentionInterval() time.Duration
+	// RetentionBatchSize сколько записей удаляет один запрос очистки по возрасту
+	// и сколько пользователей проходит один запрос очистки по количеству
+	RetentionBatchSize() int
+	// JobTimeout время, после которого задание в processing считается зависшим
+	JobTimeout() time.Duration
+	// JobMaxAttempts максимальное количество попыток выполнить задание
+	JobMaxAttempts() int
+	// JobRetryBackoff задержка перед первым повтором, далее удваивается
+	JobRetryBackoff() time.Duration
+	// JobRetryMaxBackoff максимальная задержка перед повтором
+	JobRetryMaxBackoff() time.Duration
+	// JobReaperInterval период поиска зависших и упавших заданий
+	JobReaperInterval() time.Duration
+	// FanOutChunkSize сколько лент записывается одним запросом при раскладке поста
+	FanOutChunkSize() int
+	// WorkerCount сколько задач материализации обрабатывается параллельно
+	WorkerCount() int
+	// WorkerPrefetch сколько неподтвержденных задач брокер выдает воркеру заранее
+	WorkerPrefetch() int
+	// WorkerDrainTimeout сколько воркер ждет начатые задачи при остановке
+	WorkerDrainTimeout() time.Duration
+}
+
+type feedConfig struct {
+	celebrityThreshold  int
+	cacheMaxLength      int
+	cacheTTL            time.Duration
+	retentionMaxEntries int
+	retentionMaxAge     time.Duration
+	retentionInterval   time.Duration
+	retentionBatchSize  int
+	jobTimeout          time.Duration
+	jobMaxAttempts      int
+	jobRetryBackoff     time.Duration
+	jobRetryMaxBackoff  time.Duration
+	jobReaperInterval   time.Duration
+	fanOutChunkSize     int
+	workerCount         int
+	workerPrefetch      int
+	workerDrainTimeout  time.Duration
+}
+
+func NewFeedConfig() (FeedConfig, error) {
+	celebrityThreshold, err := intFromEnv(feedCelebrityThresholdEnvName, 1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed celebrity threshold")
+	}
+
+	cacheMaxLength, err := intFromEnv(feedCacheMaxLengthEnvName, 1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed cache max length")
+	}
+
+	cacheTTL, err := intFromEnv(feedCacheTTLEnvName, 3600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed cache ttl")
+	}
+
+	retentionMaxEntries, err := intFromEnv(feedRetentionMaxEntriesEnv, 1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed retention max entries")
+	}
+
+	retentionMaxAge, err := intFromEnv(feedRetentionMaxAgeEnv, 720)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed retention max age")
+	}
+
+	retentionInterval, err := intFromEnv(feedRetentionIntervalEnv, 600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed retention interval")
+	}
+	if retentionInterval <= 0 {
+		return nil, errors.New("feed retention interval must be positive")
+	}
+
+	retentionBatchSize, err := intFromEnv(feedRetentionBatchSizeEnv, 1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed retention batch size")
+	}
+	if retentionBatchSize <= 0 {
+		return nil, errors.New("feed retention batch size must be positive")
+	}
+
+	jobTimeout, err := intFromEnv(feedJobTimeoutEnv, 300)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed job timeout")
+	}
+
+	jobMaxAttempts, err := intFromEnv(feedJobMaxAttemptsEnv, 5)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed job max attempts")
+	}
+
+	jobRetryBackoff, err := intFromEnv(feedJobRetryBackoffEnv, 10)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed job retry backoff")
+	}
+
+	jobRetryMaxBackoff, err := intFromEnv(feedJobRetryMaxBackoffEnv, 600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed job retry max backoff")
+	}
+
+	jobReaperInterval, err := intFromEnv(feedJobReaperIntervalEnv, 30)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed job reaper interval")
+	}
+	if jobReaperInterval <= 0 {
+		return nil, errors.New("feed job reaper interval must be positive")
+	}
+
+	fanOutChunkSize, err := intFromEnv(feedFanOutChunkSizeEnv, 500)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed fan-out chunk size")
+	}
+	if fanOutChunkSize <= 0 {
+		return nil, errors.New("feed fan-out chunk size must be positive")
+	}
+
+	workerCount, err := intFromEnv(feedWorkerCountEnv, 4)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed worker count")
+	}
+	if workerCount <= 0 {
+		return nil, errors.New("feed worker count must be positive")
+	}
+
+	// По умолчанию у каждого воркера одна задача в запасе
+	workerPrefetch, err := intFromEnv(feedWorkerPrefetchEnv, 2*workerCount)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed worker prefetch")
+	}
+	if workerPrefetch < workerCount {
+		return nil, errors.New("feed worker prefetch must not be less than worker count")
+	}
+
+	workerDrainTimeout, err := intFromEnv(feedWorkerDrainTimeoutEnv, 30)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse feed worker drain timeout")
+	}
+
+	return &feedConfig{
+		celebrityThreshold:  celebrityThreshold,
+		cacheMaxLength:      cacheMaxLength,
+		cacheTTL:            time.Duration(cacheTTL) * time.Second,
+		retentionMaxEntries: retentionMaxEntries,
+		retentionMaxAge:     time.Duration(retentionMaxAge) * time.Hour,
+		retentionInterval:   time.Duration(retentionInterval) * time.Second,
+		retentionBatchSize:  retentionBatchSize,
+		jobTimeout:          time.Duration(jobTimeout) * time.Second,
+		jobMaxAttempts:      jobMaxAttempts,
+		jobRetryBackoff:     time.Duration(jobRetryBackoff) * time.Second,
+		jobRetryMaxBackoff:  time.Duration(jobRetryMaxBackoff) * time.Second,
+		jobReaperInterval:   time.Duration(jobReaperInterval) * time.Second,
+		fanOutChunkSize:     fanOutChunkSize,
+		workerCount:         workerCount,
+		workerPrefetch:      workerPrefetch,
+		workerDrainTimeout:  time.Duration(workerDrainTimeout) * time.Second,
+	}, nil
+}
+
+func (cfg *feedConfig) CelebrityThreshold() int {
+	return cfg.celebrityThreshold
+}
+
+func (cfg *feedConfig) CacheMaxLength() int {
+	return cfg.cacheMaxLength
+}
+
+func (cfg *feedConfig) CacheTTL() time.Duration {
+	return cfg.cacheTTL
+}
+
+func (cfg *feedConfig) RetentionMaxEntries() int {
+	return cfg.retentionMaxEntries
+}
+
+func (cfg *feedConfig) RetentionMaxAge() time.Duration {
+	return cfg.retentionMaxAge
+}
+
+func (cfg *feedConfig) RetentionInterval() time.Duration {
+	return cfg.retentionInterval
+}
+
+func (cfg *feedConfig) RetentionBatchSize() int {
+	return cfg.retentionBatchSize
+}
+
+func (cfg *feedConfig) JobTimeout() time.Duration {
+	return cfg.jobTimeout
+}
+
+func (cfg *feedConfig) JobMaxAttempts() int {
+	return cfg.jobMaxAttempts
+}
+
+func (cfg *feedConfig) JobRetryBackoff() time.Duration {
+	return cfg.jobRetryBackoff
+}
+
+func (cfg *feedConfig) JobRetryMaxBackoff() time.Duration {
+	return cfg.jobRetryMaxBackoff
+}
+
+func (cfg *feedConfig) JobReaperInterval() time.Duration {
+	return cfg.jobReaperInterval
+}
+
+func (cfg *feedConfig) FanOutChunkSize() int {
+	return cfg.fanOutChunkSize
+}
+
+func (cfg *feedConfig) WorkerCount() int {
+	return cfg.workerCount
+}
+
+func (cfg *feedConfig) WorkerPrefetch() int {
+	return cfg.workerPrefetch
+}
+
+func (cfg *feedConfig) WorkerDrainTimeout() time.Duration {
+	return cfg.workerDrainTimeout
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	outboxPollIntervalEnv    = "OUTBOX_POLL_INTERVAL_MS"
+	outboxBatchSizeEnv       = "OUTBOX_BATCH_SIZE"
+	outboxRetentionEnv       = "OUTBOX_RETENTION_HOURS"
+	outboxCleanupIntervalEnv = "OUTBOX_CLEANUP_INTERVAL_SEC"
+	outboxLeaseEnv           = "OUTBOX_LEASE_SEC"
+)
+
+type OutboxConfig interface {
+	// PollInterval период опроса outbox, когда неотправленных событий нет
+	PollInterval() time.Duration
+	// BatchSize сколько событий отправляется за один проход
+	BatchSize() int
+	// Retention сколько хранить отправленные события
+	Retention() time.Duration
+	// CleanupInterval период удаления отправленных событий
+	CleanupInterval() time.Duration
+	// Lease сколько взятое событие закреплено за relay. Если relay не отметил его отправленным
+	// за это время, событие может взять другой relay
+	Lease() time.Duration
+}
+
+type outboxConfig struct {
+	pollInterval    time.Duration
+	batchSize       int
+	retention       time.Duration
+	cleanupInterval time.Duration
+	lease           time.Duration
+}
+
+func NewOutboxConfig() (OutboxConfig, error) {
+	pollInterval, err := intFromEnv(outboxPollIntervalEnv, 500)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse outbox poll interval")
+	}
+
+	batchSize, err := intFromEnv(outboxBatchSizeEnv, 100)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse outbox batch size")
+	}
+	if batchSize <= 0 {
+		return nil, errors.New("outbox batch size must be positive")
+	}
+
+	retention, err := intFromEnv(outboxRetentionEnv, 24)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse outbox retention")
+	}
+
+	cleanupInterval, err := intFromEnv(outboxCleanupIntervalEnv, 600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse outbox cleanup interval")
+	}
+
+	lease, err := intFromEnv(outboxLeaseEnv, 30)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse outbox lease")
+	}
+	if lease <= 0 {
+		return nil, errors.New("outbox lease must be positive")
+	}
+
+	return &outboxConfig{
+		pollInterval:    time.Duration(pollInterval) * time.Millisecond,
+		batchSize:       batchSize,
+		retention:       time.Duration(retention) * time.Hour,
+		cleanupInterval: time.Duration(cleanupInterval) * time.Second,
+		lease:           time.Duration(lease) * time.Second,
+	}, nil
+}
+
+func (cfg *outboxConfig) PollInterval() time.Duration {
+	return cfg.pollInterval
+}
+
+func (cfg *outboxConfig) BatchSize() int {
+	return cfg.batchSize
+}
+
+func (cfg *outboxConfig) Retention() time.Duration {
+	return cfg.retention
+}
+
+func (cfg *outboxConfig) CleanupInterval() time.Duration {
+	return cfg.cleanupInterval
+}
+
+func (cfg *outboxConfig) Lease() time.Duration {
+	return cfg.lease
+}
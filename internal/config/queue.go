@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	queueDriverEnv = "QUEUE_DRIVER"
+
+	// QueueDriverRabbitMQ очередь сообщений в RabbitMQ
+	QueueDriverRabbitMQ = "rabbitmq"
+	// QueueDriverMemory очередь сообщений в памяти процесса для тестов и локального запуска
+	QueueDriverMemory = "memory"
+)
+
+type QueueConfig interface {
+	// Driver реализация очереди сообщений: rabbitmq или memory
+	Driver() string
+}
+
+type queueConfig struct {
+	driver string
+}
+
+func NewQueueConfig() (QueueConfig, error) {
+	driver := os.Getenv(queueDriverEnv)
+	if driver == "" {
+		driver = QueueDriverRabbitMQ
+	}
+
+	switch driver {
+	case QueueDriverRabbitMQ, QueueDriverMemory:
+	default:
+		return nil, errors.Errorf("unknown queue driver %q", driver)
+	}
+
+	return &queueConfig{
+		driver: driver,
+	}, nil
+}
+
+func (c *queueConfig) Driver() string {
+	return c.driver
+}
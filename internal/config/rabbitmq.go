@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 type RabbitMQConfig interface {
@@ -13,14 +14,36 @@ type RabbitMQConfig interface {
 	Password() string
 	VHost() string
 	DSN() string
+	// RetryMaxAttempts после скольких неудачных попыток задача уходит в dead-letter очередь
+	RetryMaxAttempts() int
+	// RetryBackoff задержка перед первым повтором задачи, далее удваивается
+	RetryBackoff() time.Duration
+	// RetryMaxBackoff максимальная задержка перед повтором задачи
+	RetryMaxBackoff() time.Duration
+	// ReconnectBackoff задержка перед первой попыткой переподключения, далее удваивается
+	ReconnectBackoff() time.Duration
+	// ReconnectMaxBackoff максимальная задержка между попытками переподключения
+	ReconnectMaxBackoff() time.Duration
+	// PublishWait сколько публикация ждет переподключения, 0 - сразу возвращать ошибку
+	PublishWait() time.Duration
+	// ConfirmTimeout сколько ждать подтверждения публикации брокером
+	ConfirmTimeout() time.Duration
 }
 
 type rabbitMQConfig struct {
-	host     string
-	port     int
-	username string
-	password string
-	vhost    string
+	host             string
+	port             int
+	username         string
+	password         string
+	vhost            string
+	retryMaxAttempts int
+	retryBackoff     time.Duration
+	retryMaxBackoff  time.Duration
+
+	reconnectBackoff    time.Duration
+	reconnectMaxBackoff time.Duration
+	publishWait         time.Duration
+	confirmTimeout      time.Duration
 }
 
 func NewRabbitMQConfig() (RabbitMQConfig, error) {
@@ -54,12 +77,61 @@ func NewRabbitMQConfig() (RabbitMQConfig, error) {
 		vhost = "/"
 	}
 
+	retryMaxAttempts, err := intFromEnv("RABBITMQ_RETRY_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RABBITMQ_RETRY_MAX_ATTEMPTS: %w", err)
+	}
+
+	retryBackoff, err := intFromEnv("RABBITMQ_RETRY_BACKOFF_MS", 1000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RABBITMQ_RETRY_BACKOFF_MS: %w", err)
+	}
+
+	retryMaxBackoff, err := intFromEnv("RABBITMQ_RETRY_MAX_BACKOFF_MS", 60000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RABBITMQ_RETRY_MAX_BACKOFF_MS: %w", err)
+	}
+
+	reconnectBackoff, err := intFromEnv("RABBITMQ_RECONNECT_BACKOFF_MS", 500)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RABBITMQ_RECONNECT_BACKOFF_MS: %w", err)
+	}
+	if reconnectBackoff <= 0 {
+		return nil, fmt.Errorf("RABBITMQ_RECONNECT_BACKOFF_MS must be positive")
+	}
+
+	reconnectMaxBackoff, err := intFromEnv("RABBITMQ_RECONNECT_MAX_BACKOFF_MS", 30000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RABBITMQ_RECONNECT_MAX_BACKOFF_MS: %w", err)
+	}
+
+	publishWait, err := intFromEnv("RABBITMQ_PUBLISH_WAIT_MS", 5000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RABBITMQ_PUBLISH_WAIT_MS: %w", err)
+	}
+
+	confirmTimeout, err := intFromEnv("RABBITMQ_CONFIRM_TIMEOUT_MS", 5000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RABBITMQ_CONFIRM_TIMEOUT_MS: %w", err)
+	}
+	if confirmTimeout <= 0 {
+		return nil, fmt.Errorf("RABBITMQ_CONFIRM_TIMEOUT_MS must be positive")
+	}
+
 	return &rabbitMQConfig{
-		host:     host,
-		port:     port,
-		username: username,
-		password: password,
-		vhost:    vhost,
+		host:             host,
+		port:             port,
+		username:         username,
+		password:         password,
+		vhost:            vhost,
+		retryMaxAttempts: retryMaxAttempts,
+		retryBackoff:     time.Duration(retryBackoff) * time.Millisecond,
+		retryMaxBackoff:  time.Duration(retryMaxBackoff) * time.Millisecond,
+
+		reconnectBackoff:    time.Duration(reconnectBackoff) * time.Millisecond,
+		reconnectMaxBackoff: time.Duration(reconnectMaxBackoff) * time.Millisecond,
+		publishWait:         time.Duration(publishWait) * time.Millisecond,
+		confirmTimeout:      time.Duration(confirmTimeout) * time.Millisecond,
 	}, nil
 }
 
@@ -83,6 +155,34 @@ func (c *rabbitMQConfig) VHost() string {
 	return c.vhost
 }
 
+func (c *rabbitMQConfig) RetryMaxAttempts() int {
+	return c.retryMaxAttempts
+}
+
+func (c *rabbitMQConfig) RetryBackoff() time.Duration {
+	return c.retryBackoff
+}
+
+func (c *rabbitMQConfig) RetryMaxBackoff() time.Duration {
+	return c.retryMaxBackoff
+}
+
+func (c *rabbitMQConfig) ReconnectBackoff() time.Duration {
+	return c.reconnectBackoff
+}
+
+func (c *rabbitMQConfig) ReconnectMaxBackoff() time.Duration {
+	return c.reconnectMaxBackoff
+}
+
+func (c *rabbitMQConfig) PublishWait() time.Duration {
+	return c.publishWait
+}
+
+func (c *rabbitMQConfig) ConfirmTimeout() time.Duration {
+	return c.confirmTimeout
+}
+
 func (c *rabbitMQConfig) DSN() string {
 	return fmt.Sprintf("amqp://%s:%s@%s:%d%s", c.username, c.password, c.host, c.port, c.vhost)
 }
@@ -1,22 +1,74 @@
 package config
 
 import (
+	"fmt"
 	"net"
 	"os"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 const (
-	websocketHostEnvName = "WEBSOCKET_HOST"
-	websocketPortEnvName = "WEBSOCKET_PORT"
+	websocketHostEnvName           = "WEBSOCKET_HOST"
+	websocketPortEnvName           = "WEBSOCKET_PORT"
+	websocketPingIntervalEnv       = "WEBSOCKET_PING_INTERVAL_SEC"
+	websocketPongTimeoutEnv        = "WEBSOCKET_PONG_TIMEOUT_SEC"
+	websocketWriteTimeoutEnv       = "WEBSOCKET_WRITE_TIMEOUT_SEC"
+	websocketMaxMessageSizeEnv     = "WEBSOCKET_MAX_MESSAGE_SIZE"
+	websocketSendBufferSizeEnv     = "WEBSOCKET_SEND_BUFFER_SIZE"
+	websocketSlowConsumerPolicyEnv = "WEBSOCKET_SLOW_CONSUMER_POLICY"
+	websocketEventLogSizeEnv       = "WEBSOCKET_EVENT_LOG_SIZE"
+	websocketEventLogTTLEnv        = "WEBSOCKET_EVENT_LOG_TTL_SEC"
+	websocketResumeWaitEnv         = "WEBSOCKET_RESUME_WAIT_MS"
+	websocketNodeIDEnv             = "WEBSOCKET_NODE_ID"
+
+	// SlowConsumerDisconnect закрыть соединение клиента, который не успевает читать сообщения
+	SlowConsumerDisconnect = "disconnect"
+	// SlowConsumerDropOldest отбросить самое старое неотправленное сообщение
+	SlowConsumerDropOldest = "drop_oldest"
+	// SlowConsumerCoalesce заменить все неотправленные сообщения одним сообщением resync
+	SlowConsumerCoalesce = "coalesce"
 )
 
 type WebSocketConfig interface {
 	Address() string
+	// PingInterval период отправки ping клиенту
+	PingInterval() time.Duration
+	// PongTimeout сколько ждать pong или другого сообщения от клиента, прежде чем закрыть соединение
+	PongTimeout() time.Duration
+	// WriteTimeout ограничение на запись одного сообщения в соединение
+	WriteTimeout() time.Duration
+	// MaxMessageSize максимальный размер сообщения от клиента в байтах
+	MaxMessageSize() int64
+	// SendBufferSize сколько сообщений ждут отправки в одно соединение
+	SendBufferSize() int
+	// SlowConsumerPolicy что делать, когда буфер отправки заполнен: disconnect, drop_oldest или coalesce
+	SlowConsumerPolicy() string
+	// EventLogSize сколько последних сообщений хранится в журнале пользователя для повтора после переподключения
+	EventLogSize() int
+	// EventLogTTL время жизни журнала пользователя после последней записи
+	EventLogTTL() time.Duration
+	// ResumeWait сколько ждать первое сообщение клиента с номером последнего полученного события,
+	// придерживая новые события ленты
+	ResumeWait() time.Duration
+	// NodeID идентификатор узла в реестре соединений, уникальный среди запущенных серверов
+	NodeID() string
 }
 
 type websocketConfig struct {
-	host string
-	port string
+	host               string
+	port               string
+	pingInterval       time.Duration
+	pongTimeout        time.Duration
+	writeTimeout       time.Duration
+	maxMessageSize     int64
+	sendBufferSize     int
+	slowConsumerPolicy string
+	eventLogSize       int
+	eventLogTTL        time.Duration
+	resumeWait         time.Duration
+	nodeID             string
 }
 
 func NewWebSocketConfig() (WebSocketConfig, error) {
@@ -32,12 +84,147 @@ func NewWebSocketConfig() (WebSocketConfig, error) {
 		port = "8090"
 	}
 
+	pingInterval, err := intFromEnv(websocketPingIntervalEnv, 30)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket ping interval")
+	}
+	if pingInterval <= 0 {
+		return nil, errors.New("websocket ping interval must be positive")
+	}
+
+	pongTimeout, err := intFromEnv(websocketPongTimeoutEnv, 60)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket pong timeout")
+	}
+	// Клиент отвечает на ping, поэтому ожидание pong должно быть дольше периода ping
+	if pongTimeout <= pingInterval {
+		return nil, errors.New("websocket pong timeout must be greater than ping interval")
+	}
+
+	writeTimeout, err := intFromEnv(websocketWriteTimeoutEnv, 10)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket write timeout")
+	}
+	if writeTimeout <= 0 {
+		return nil, errors.New("websocket write timeout must be positive")
+	}
+
+	maxMessageSize, err := intFromEnv(websocketMaxMessageSizeEnv, 4096)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket max message size")
+	}
+	if maxMessageSize <= 0 {
+		return nil, errors.New("websocket max message size must be positive")
+	}
+
+	sendBufferSize, err := intFromEnv(websocketSendBufferSizeEnv, 256)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket send buffer size")
+	}
+	if sendBufferSize <= 0 {
+		return nil, errors.New("websocket send buffer size must be positive")
+	}
+
+	slowConsumerPolicy := os.Getenv(websocketSlowConsumerPolicyEnv)
+	if slowConsumerPolicy == "" {
+		slowConsumerPolicy = SlowConsumerDisconnect
+	}
+
+	switch slowConsumerPolicy {
+	case SlowConsumerDisconnect, SlowConsumerDropOldest, SlowConsumerCoalesce:
+	default:
+		return nil, errors.Errorf("unknown websocket slow consumer policy %q", slowConsumerPolicy)
+	}
+
+	eventLogSize, err := intFromEnv(websocketEventLogSizeEnv, 100)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket event log size")
+	}
+	// Пропущенные сообщения повторяются в пустой буфер отправки и должны в нем поместиться
+	if eventLogSize <= 0 || eventLogSize >= sendBufferSize {
+		return nil, errors.New("websocket event log size must be positive and less than send buffer size")
+	}
+
+	eventLogTTL, err := intFromEnv(websocketEventLogTTLEnv, 86400)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket event log ttl")
+	}
+	if eventLogTTL <= 0 {
+		return nil, errors.New("websocket event log ttl must be positive")
+	}
+
+	resumeWait, err := intFromEnv(websocketResumeWaitEnv, 1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse websocket resume wait")
+	}
+
+	nodeID := os.Getenv(websocketNodeIDEnv)
+	if nodeID == "" {
+		// По умолчанию узел определяется хостом и процессом, поэтому после перезапуска
+		// старые отметки в реестре не смешиваются с новыми
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get hostname for websocket node id")
+		}
+		nodeID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
 	return &websocketConfig{
-		host: host,
-		port: port,
+		host:               host,
+		port:               port,
+		pingInterval:       time.Duration(pingInterval) * time.Second,
+		pongTimeout:        time.Duration(pongTimeout) * time.Second,
+		writeTimeout:       time.Duration(writeTimeout) * time.Second,
+		maxMessageSize:     int64(maxMessageSize),
+		sendBufferSize:     sendBufferSize,
+		slowConsumerPolicy: slowConsumerPolicy,
+		eventLogSize:       eventLogSize,
+		eventLogTTL:        time.Duration(eventLogTTL) * time.Second,
+		resumeWait:         time.Duration(resumeWait) * time.Millisecond,
+		nodeID:             nodeID,
 	}, nil
 }
 
 func (cfg *websocketConfig) Address() string {
 	return net.JoinHostPort(cfg.host, cfg.port)
 }
+
+func (cfg *websocketConfig) PingInterval() time.Duration {
+	return cfg.pingInterval
+}
+
+func (cfg *websocketConfig) PongTimeout() time.Duration {
+	return cfg.pongTimeout
+}
+
+func (cfg *websocketConfig) WriteTimeout() time.Duration {
+	return cfg.writeTimeout
+}
+
+func (cfg *websocketConfig) MaxMessageSize() int64 {
+	return cfg.maxMessageSize
+}
+
+func (cfg *websocketConfig) SendBufferSize() int {
+	return cfg.sendBufferSize
+}
+
+func (cfg *websocketConfig) SlowConsumerPolicy() string {
+	return cfg.slowConsumerPolicy
+}
+
+func (cfg *websocketConfig) EventLogSize() int {
+	return cfg.eventLogSize
+}
+
+func (cfg *websocketConfig) EventLogTTL() time.Duration {
+	return cfg.eventLogTTL
+}
+
+func (cfg *websocketConfig) ResumeWait() time.Duration {
+	return cfg.resumeWait
+}
+
+func (cfg *websocketConfig) NodeID() string {
+	return cfg.nodeID
+}
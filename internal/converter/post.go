@@ -2,8 +2,8 @@ package converter
 
 import (
 	"otus-project/internal/model"
-	"otus-project/pkg/api"
 	feedModel "otus-project/internal/repository/feed/model"
+	"otus-project/pkg/api"
 )
 
 func ToPostFromService(post *model.Post) *api.Post {
@@ -32,6 +32,6 @@ func MaterializedFeedToPost(mf *feedModel.MaterializedFeed) *model.Post {
 		ID:           &mf.PostID,
 		Text:         &mf.PostText,
 		AuthorUserId: &mf.AuthorID,
+		CreatedAt:    &mf.CreatedAt,
 	}
 }
-
@@ -45,10 +45,12 @@ func ToUserInfoFromApi(info *api.PostUserRegisterJSONBody) *model.UserInfo {
 	}
 }
 
-func ToUserFilterFromApi(info *api.GetUserSearchParams) *model.UserFilter {
+func ToUserFilterFromApi(info *api.GetUserSearchParams, cursor *model.Cursor, limit int) *model.UserFilter {
 	return &model.UserFilter{
 		FirstName: info.FirstName,
 		LastName:  info.LastName,
+		Cursor:    cursor,
+		Limit:     limit,
 	}
 }
 
@@ -16,6 +16,18 @@ type Metrics struct {
 	requestCounter        prometheus.Counter
 	responseCounter       *prometheus.CounterVec
 	histogramResponseTime *prometheus.HistogramVec
+	feedTrimmedRows       *prometheus.CounterVec
+	outboxEvents          *prometheus.CounterVec
+	eventsPublished       *prometheus.CounterVec
+	eventsHandled         *prometheus.CounterVec
+	eventRetries          *prometheus.CounterVec
+	eventHandleDuration   *prometheus.HistogramVec
+	feedWorkers           prometheus.Gauge
+	feedWorkersBusy       prometheus.Gauge
+	feedWorkerUtilization prometheus.Gauge
+	feedTaskLag           *prometheus.HistogramVec
+	wsDroppedMessages     *prometheus.CounterVec
+	wsDisconnects         *prometheus.CounterVec
 }
 
 var metrics *Metrics
@@ -50,6 +62,113 @@ func Init(_ context.Context) error {
 			},
 			[]string{"status"},
 		),
+		feedTrimmedRows: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "feed",
+				Name:      appName + "_trimmed_rows_total",
+				Help:      "Количество записей материализованной ленты, удаленных очисткой",
+			},
+			[]string{"reason"},
+		),
+		outboxEvents: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "outbox",
+				Name:      appName + "_events_total",
+				Help:      "Количество событий outbox по результату публикации",
+			},
+			[]string{"status"},
+		),
+		eventsPublished: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "event_bus",
+				Name:      appName + "_published_total",
+				Help:      "Количество событий, переданных в Event Bus",
+			},
+			[]string{"event_type"},
+		),
+		eventsHandled: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "event_bus",
+				Name:      appName + "_handled_total",
+				Help:      "Количество обработанных событий по подписчику и результату",
+			},
+			[]string{"event_type", "subscriber", "status"},
+		),
+		eventRetries: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "event_bus",
+				Name:      appName + "_retries_total",
+				Help:      "Количество повторных вызовов обработчиков событий",
+			},
+			[]string{"event_type", "subscriber"},
+		),
+		eventHandleDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: "event_bus",
+				Name:      appName + "_handle_duration_seconds",
+				Help:      "Время обработки события подписчиком вместе с повторами",
+				Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 18),
+			},
+			[]string{"event_type", "subscriber"},
+		),
+		feedWorkers: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "feed",
+				Name:      appName + "_workers",
+				Help:      "Размер пула воркеров материализации ленты",
+			},
+		),
+		feedWorkersBusy: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "feed",
+				Name:      appName + "_workers_busy",
+				Help:      "Количество воркеров материализации, занятых задачей",
+			},
+		),
+		feedWorkerUtilization: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "feed",
+				Name:      appName + "_worker_utilization",
+				Help:      "Доля занятых воркеров материализации",
+			},
+		),
+		feedTaskLag: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: "feed",
+				Name:      appName + "_task_lag_seconds",
+				Help:      "Время от публикации задачи материализации до начала ее обработки",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 2, 20),
+			},
+			[]string{"event_type"},
+		),
+		wsDroppedMessages: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "websocket",
+				Name:      appName + "_dropped_messages_total",
+				Help:      "Сообщения, не доставленные медленным клиентам",
+			},
+			[]string{"policy"},
+		),
+		wsDisconnects: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "websocket",
+				Name:      appName + "_disconnects_total",
+				Help:      "Закрытые WebSocket соединения по причине закрытия",
+			},
+			[]string{"reason"},
+		),
 	}
 
 	return nil
@@ -66,3 +185,48 @@ func IncResponseCounter(status string, method string) {
 func HistogramResponseTimeObserve(status string, time float64) {
 	metrics.histogramResponseTime.WithLabelValues(status).Observe(time)
 }
+
+func AddFeedTrimmedRows(reason string, count int64) {
+	metrics.feedTrimmedRows.WithLabelValues(reason).Add(float64(count))
+}
+
+func AddOutboxEvents(status string, count int) {
+	metrics.outboxEvents.WithLabelValues(status).Add(float64(count))
+}
+
+func IncEventPublished(eventType string) {
+	metrics.eventsPublished.WithLabelValues(eventType).Inc()
+}
+
+func IncEventHandled(eventType, subscriber, status string) {
+	metrics.eventsHandled.WithLabelValues(eventType, subscriber, status).Inc()
+}
+
+func IncEventRetries(eventType, subscriber string) {
+	metrics.eventRetries.WithLabelValues(eventType, subscriber).Inc()
+}
+
+func EventHandleDurationObserve(eventType, subscriber string, seconds float64) {
+	metrics.eventHandleDuration.WithLabelValues(eventType, subscriber).Observe(seconds)
+}
+
+func SetFeedWorkers(count int) {
+	metrics.feedWorkers.Set(float64(count))
+}
+
+func SetFeedWorkersBusy(busy, count int) {
+	metrics.feedWorkersBusy.Set(float64(busy))
+	metrics.feedWorkerUtilization.Set(float64(busy) / float64(count))
+}
+
+func FeedTaskLagObserve(eventType string, seconds float64) {
+	metrics.feedTaskLag.WithLabelValues(eventType).Observe(seconds)
+}
+
+func AddWebSocketDroppedMessages(policy string, count int) {
+	metrics.wsDroppedMessages.WithLabelValues(policy).Add(float64(count))
+}
+
+func IncWebSocketDisconnects(reason string) {
+	metrics.wsDisconnects.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// UserActivity сигналы активности пользователя
+type UserActivity struct {
+	UserID         string
+	LastLoginAt    time.Time // нулевое значение - нет данных
+	LastFeedReadAt time.Time // нулевое значение - нет данных
+	Online         bool      // есть открытое WebSocket соединение
+}
+
+// LastSeenAt время последнего входа или чтения ленты
+func (a *UserActivity) LastSeenAt() time.Time {
+	if a.LastFeedReadAt.After(a.LastLoginAt) {
+		return a.LastFeedReadAt
+	}
+
+	return a.LastLoginAt
+}
@@ -4,6 +4,8 @@ import "time"
 
 // DialogMessage представляет сообщение в диалоге
 type DialogMessage struct {
+	// ID Идентификатор сообщения
+	ID string
 	// From Идентификатор пользователя отправителя
 	From string
 	// To Идентификатор пользователя получателя
@@ -13,3 +15,17 @@ type DialogMessage struct {
 	// CreatedAt Время создания сообщения
 	CreatedAt time.Time
 }
+
+// DialogRead позиция прочтения диалога пользователем: все сообщения до MessageID включительно прочитаны
+type DialogRead struct {
+	// UserID Идентификатор прочитавшего пользователя
+	UserID string `json:"user_id"`
+	// PeerID Идентификатор собеседника
+	PeerID string `json:"peer_id"`
+	// MessageID Идентификатор последнего прочитанного сообщения
+	MessageID string `json:"message_id"`
+	// MessageCreatedAt Время создания последнего прочитанного сообщения, по нему сравниваются позиции
+	MessageCreatedAt time.Time `json:"message_created_at"`
+	// ReadAt Время прочтения
+	ReadAt time.Time `json:"read_at"`
+}
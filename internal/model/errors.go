@@ -3,3 +3,12 @@ package model
 import "github.com/pkg/errors"
 
 var ErrorPostNotFound = errors.New("post not found")
+
+// ErrorFeedJobNotPending задание уже взято в работу или завершено
+var ErrorFeedJobNotPending = errors.New("feed job is not pending")
+
+// ErrorUnknownEventType тип доменного события не зарегистрирован в NewEventPayload
+var ErrorUnknownEventType = errors.New("unknown event type")
+
+// ErrorDialogMessageNotFound сообщение не найдено в диалоге
+var ErrorDialogMessageNotFound = errors.New("dialog message not found")
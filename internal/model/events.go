@@ -1,6 +1,25 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event конверт доменного события: метаданные и данные события (*PostCreatedEvent, ...)
+type Event struct {
+	// ID идентификатор события, совпадает с идентификатором записи outbox
+	ID string `json:"id"`
+	// Type тип события (см. EventType*)
+	Type string `json:"type"`
+	// Version версия схемы данных события
+	Version int `json:"version"`
+	// OccurredAt время события
+	OccurredAt time.Time `json:"occurred_at"`
+	// Payload данные события
+	Payload interface{} `json:"payload"`
+}
 
 // PostCreatedEvent событие создания поста
 type PostCreatedEvent struct {
@@ -10,7 +29,124 @@ type PostCreatedEvent struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// PostUpdatedEvent событие изменения текста поста
+type PostUpdatedEvent struct {
+	PostID       string    `json:"post_id"`
+	AuthorUserID string    `json:"author_user_id"`
+	PostText     string    `json:"post_text"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// PostDeletedEvent событие удаления поста
+type PostDeletedEvent struct {
+	PostID       string    `json:"post_id"`
+	AuthorUserID string    `json:"author_user_id"`
+	DeletedAt    time.Time `json:"deleted_at"`
+}
+
+// FriendAddedEvent событие добавления друга
+type FriendAddedEvent struct {
+	UserID    string    `json:"user_id"`
+	FriendID  string    `json:"friend_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FriendRemovedEvent событие удаления друга
+type FriendRemovedEvent struct {
+	UserID    string    `json:"user_id"`
+	FriendID  string    `json:"friend_id"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+// DialogMessageSentEvent событие отправки сообщения в диалог
+type DialogMessageSentEvent struct {
+	MessageID  string    `json:"message_id"`
+	FromUserID string    `json:"from_user_id"`
+	ToUserID   string    `json:"to_user_id"`
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DialogMessagesReadEvent событие прочтения сообщений диалога: позиция прочтения UserID
+// в диалоге с PeerID сдвинулась до MessageID
+type DialogMessagesReadEvent struct {
+	UserID           string    `json:"user_id"`
+	PeerID           string    `json:"peer_id"`
+	MessageID        string    `json:"message_id"`
+	MessageCreatedAt time.Time `json:"message_created_at"`
+	ReadAt           time.Time `json:"read_at"`
+}
+
 // EventType типы событий
 const (
-	EventTypePostCreated = "post.created"
+	EventTypePostCreated   = "post.created"
+	EventTypePostUpdated   = "post.updated"
+	EventTypePostDeleted   = "post.deleted"
+	EventTypeFriendAdded   = "friend.added"
+	EventTypeFriendRemoved = "friend.removed"
+
+	EventTypeDialogMessageSent  = "dialog.message_sent"
+	EventTypeDialogMessagesRead = "dialog.messages_read"
 )
+
+// NewEventPayload возвращает пустое событие указанного типа для разбора из JSON
+func NewEventPayload(eventType string) (interface{}, bool) {
+	switch eventType {
+	case EventTypePostCreated:
+		return &PostCreatedEvent{}, true
+	case EventTypePostUpdated:
+		return &PostUpdatedEvent{}, true
+	case EventTypePostDeleted:
+		return &PostDeletedEvent{}, true
+	case EventTypeFriendAdded:
+		return &FriendAddedEvent{}, true
+	case EventTypeFriendRemoved:
+		return &FriendRemovedEvent{}, true
+	case EventTypeDialogMessageSent:
+		return &DialogMessageSentEvent{}, true
+	case EventTypeDialogMessagesRead:
+		return &DialogMessagesReadEvent{}, true
+	default:
+		return nil, false
+	}
+}
+
+// DecodeEvent разбирает событие из JSON. Тело без конверта, записанное в outbox до его появления,
+// считается данными события eventType версии 1
+func DecodeEvent(eventType string, data []byte) (*Event, error) {
+	var raw struct {
+		ID         string          `json:"id"`
+		Type       string          `json:"type"`
+		Version    int             `json:"version"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal event")
+	}
+
+	if len(raw.Payload) == 0 {
+		raw.Payload = data
+		raw.Version = 1
+	}
+	if raw.Type == "" {
+		raw.Type = eventType
+	}
+
+	payload, ok := NewEventPayload(raw.Type)
+	if !ok {
+		return nil, errors.Wrap(ErrorUnknownEventType, raw.Type)
+	}
+
+	if err := json.Unmarshal(raw.Payload, payload); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s payload", raw.Type)
+	}
+
+	return &Event{
+		ID:         raw.ID,
+		Type:       raw.Type,
+		Version:    raw.Version,
+		OccurredAt: raw.OccurredAt,
+		Payload:    payload,
+	}, nil
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// OutboxMessage доменное событие, сохраненное в outbox и ожидающее публикации в брокер
+type OutboxMessage struct {
+	// ID идентификатор события, передается в брокер как message id
+	ID string
+	// EventType тип события, он же routing key (см. EventType*)
+	EventType string
+	// AggregateID идентификатор сущности, к которой относится событие
+	AggregateID string
+	// Payload конверт события (Event) в JSON
+	Payload []byte
+	// CreatedAt время записи события
+	CreatedAt time.Time
+	// Attempts количество неудачных попыток публикации
+	Attempts int
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrorInvalidCursor = errors.New("invalid cursor")
+
+// Cursor позиция для keyset-пагинации по (created_at, id).
+// Страница содержит записи строго "после" курсора в порядке выдачи.
+type Cursor struct {
+	// CreatedAt время создания последней записи предыдущей страницы
+	CreatedAt time.Time
+	// ID идентификатор последней записи предыдущей страницы
+	ID string
+}
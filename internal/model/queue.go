@@ -4,22 +4,73 @@ import (
 	"time"
 )
 
+// Типы событий ленты
+const (
+	FeedEventTypePostCreated = "post_created"
+	FeedEventTypePostUpdated = "post_updated"
+	FeedEventTypePostDeleted = "post_deleted"
+
+	// FeedEventTypeFriendAdded AuthorUserID - новый друг, его последние посты добавляются в ленту
+	FeedEventTypeFriendAdded = "friend_added"
+	// FeedEventTypeFriendRemoved AuthorUserID - бывший друг, его посты убираются из ленты
+	FeedEventTypeFriendRemoved = "friend_removed"
+)
+
+// Приоритеты задач обновления ленты: чем меньше значение, тем раньше задача будет обработана
+const (
+	FeedTaskPriorityOnline   = 1 // получатель сейчас онлайн
+	FeedTaskPriorityActive   = 2 // заходил в последний час
+	FeedTaskPriorityNormal   = 3 // заходил в последние сутки, или активность неизвестна
+	FeedTaskPriorityInactive = 4 // заходил в последнюю неделю
+	FeedTaskPriorityDormant  = 5 // давно не заходил
+)
+
 // FeedEvent представляет событие для обновления ленты
 type FeedEvent struct {
 	PostID       string    `json:"postId"`
 	AuthorUserID string    `json:"authorUserId"`
 	PostText     string    `json:"postText"`
 	CreatedAt    time.Time `json:"createdAt"`
-	EventType    string    `json:"eventType"` // "post_created", "post_updated", "post_deleted"
+	EventType    string    `json:"eventType"` // "post_created", "post_updated", "post_deleted", "friend_added", "friend_removed"
+	// EventID номер события в журнале WebSocket событий получателя, 0 - событие не записано в журнал
+	EventID int64 `json:"eventId,omitempty"`
 }
 
-// FeedUpdateTask представляет задачу обновления ленты для конкретного пользователя
+// IsFriendshipEvent возвращает true для событий изменения дружбы, которые не привязаны к конкретному посту
+func (e *FeedEvent) IsFriendshipEvent() bool {
+	return e.EventType == FeedEventTypeFriendAdded || e.EventType == FeedEventTypeFriendRemoved
+}
+
+// FeedUpdateTask представляет задачу обновления ленты.
+// Задача по посту публикуется одна на пост без UserID и раскладывается воркером
+// по лентам всех друзей автора; задача дружбы адресована конкретному пользователю
 type FeedUpdateTask struct {
 	UserID    string     `json:"userId"`
 	PostID    string     `json:"postId"`
 	Event     *FeedEvent `json:"event"`
 	Priority  int        `json:"priority"` // Приоритет обработки (1 - высокий, 5 - низкий)
 	CreatedAt time.Time  `json:"createdAt"`
+	JobID     string     `json:"jobId,omitempty"` // задание в feed_jobs, заполняется при повторной публикации
+}
+
+// IsValid проверяет, что в задаче заполнены обязательные поля.
+// Для событий дружбы PostID не задается, для событий поста не обязателен UserID.
+func (t *FeedUpdateTask) IsValid() bool {
+	if t.Event == nil {
+		return false
+	}
+
+	if t.Event.IsFriendshipEvent() {
+		return t.UserID != "" && t.Event.AuthorUserID != ""
+	}
+
+	return t.PostID != "" && t.Event.AuthorUserID != ""
+}
+
+// IsFanOut возвращает true для задачи по посту, которую нужно разложить по лентам всех друзей автора.
+// Задачи по посту с UserID публиковались до перехода на fan-out и обрабатываются для одного получателя
+func (t *FeedUpdateTask) IsFanOut() bool {
+	return t.UserID == "" && t.Event != nil && !t.Event.IsFriendshipEvent()
 }
 
 // QueueConfig конфигурация для очереди сообщений
@@ -41,3 +92,20 @@ type FeedMaterializationJob struct {
 	UpdatedAt time.Time `json:"updatedAt"`
 	Error     string    `json:"error,omitempty"`
 }
+
+// DeadLetterTask задача материализации, не обработанная за допустимое число попыток
+type DeadLetterTask struct {
+	MessageID string          `json:"messageId"`
+	Task      *FeedUpdateTask `json:"task,omitempty"`
+	Body      string          `json:"body,omitempty"` // исходное сообщение, если его не удалось разобрать
+	Error     string          `json:"error"`
+	Attempts  int             `json:"attempts"`
+	FailedAt  time.Time       `json:"failedAt"`
+}
+
+// Announcement объявление администратора, которое рассылается всем WebSocket узлам
+type Announcement struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -35,4 +35,8 @@ type UserInfo struct {
 type UserFilter struct {
 	LastName  string
 	FirstName string
+	// Cursor позиция после последнего пользователя предыдущей страницы, nil - первая страница
+	Cursor *Cursor
+	// Limit размер страницы
+	Limit int
 }
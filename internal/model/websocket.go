@@ -1,5 +1,71 @@
 package model
 
+import (
+	"strings"
+	"time"
+)
+
+// Типы WebSocket сообщений
+const (
+	WebSocketMessageTypePost        = "post"
+	WebSocketMessageTypePostUpdated = "post_updated"
+	WebSocketMessageTypePostDeleted = "post_deleted"
+	// WebSocketMessageTypeResync заменяет сообщения, которые клиент не успел получить: клиенту нужно
+	// перечитать ленту через REST API
+	WebSocketMessageTypeResync = "resync"
+	// WebSocketMessageTypeAnnouncement объявление администратора для всех подписанных соединений
+	WebSocketMessageTypeAnnouncement = "announcement"
+	// WebSocketMessageTypeMessageNew новое сообщение диалога
+	WebSocketMessageTypeMessageNew = "message.new"
+
+	// Сообщения диалога, которые клиент отправляет, а сервер передает собеседнику
+	WebSocketMessageTypeTyping = "typing"
+	WebSocketMessageTypeRead   = "read"
+
+	// Сообщения клиента
+	WebSocketMessageTypeSubscribe   = "subscribe"
+	WebSocketMessageTypeUnsubscribe = "unsubscribe"
+	WebSocketMessageTypeResume      = "resume"
+
+	// Ответы сервера на сообщения клиента
+	WebSocketMessageTypeAck   = "ack"
+	WebSocketMessageTypeError = "error"
+)
+
+// Каналы доставки WebSocket сообщений
+const (
+	// WebSocketChannelFeed лента постов друзей, на нее соединение подписано при подключении
+	WebSocketChannelFeed = "feed"
+	// WebSocketChannelNotifications уведомления пользователя
+	WebSocketChannelNotifications = "notifications"
+	// WebSocketChannelDialogPrefix префикс канала диалога, за ним следует ID собеседника
+	WebSocketChannelDialogPrefix = "dialog."
+	// WebSocketChannelAnnouncements объявления администратора всем пользователям, подписка только по запросу клиента
+	WebSocketChannelAnnouncements = "announcements"
+)
+
+// Коды ошибок в ответ на сообщения клиента
+const (
+	WebSocketErrorInvalidJSON          = "invalid_json"
+	WebSocketErrorInvalidFrame         = "invalid_frame"
+	WebSocketErrorInvalidChannel       = "invalid_channel"
+	WebSocketErrorTooManySubscriptions = "too_many_subscriptions"
+	WebSocketErrorResumeNotAllowed     = "resume_not_allowed"
+	WebSocketErrorMessageNotFound      = "message_not_found"
+	WebSocketErrorInternal             = "internal_error"
+)
+
+// Причины закрытия WebSocket соединения, они же значения метки reason в метриках
+const (
+	WebSocketCloseReasonClientClosed    = "client_closed"
+	WebSocketCloseReasonPongTimeout     = "pong_timeout"
+	WebSocketCloseReasonMessageTooLarge = "message_too_large"
+	WebSocketCloseReasonReadError       = "read_error"
+	WebSocketCloseReasonWriteTimeout    = "write_timeout"
+	WebSocketCloseReasonWriteError      = "write_error"
+	WebSocketCloseReasonSlowConsumer    = "slow_consumer"
+)
+
 // WebSocketPost представляет сообщение о посте для WebSocket
 type WebSocketPost struct {
 	PostID       string `json:"postId"`
@@ -9,22 +75,129 @@ type WebSocketPost struct {
 
 // WebSocketMessage представляет общую структуру WebSocket сообщения
 type WebSocketMessage struct {
-	Type    string      `json:"type"`
+	Type string `json:"type"`
+	// ID идентификатор запроса клиента, на который отвечает сервер
+	ID string `json:"id,omitempty"`
+	// Channel канал, по подписке на который доставляется сообщение. Пустой канал - всем соединениям
+	Channel string `json:"channel,omitempty"`
+	// EventID номер сообщения в журнале пользователя, по нему клиент запрашивает пропущенные сообщения
+	EventID int64       `json:"eventId,omitempty"`
 	Payload interface{} `json:"payload"`
 }
 
-// WebSocketConnection представляет WebSocket соединение
+// NewFeedWebSocketMessage преобразует событие ленты в сообщение канала feed
+func NewFeedWebSocketMessage(event *FeedEvent) *WebSocketMessage {
+	messageType := WebSocketMessageTypePost
+	switch event.EventType {
+	case FeedEventTypePostUpdated:
+		messageType = WebSocketMessageTypePostUpdated
+	case FeedEventTypePostDeleted:
+		messageType = WebSocketMessageTypePostDeleted
+	}
+
+	return &WebSocketMessage{
+		Type:    messageType,
+		Channel: WebSocketChannelFeed,
+		EventID: event.EventID,
+		Payload: &WebSocketPost{
+			PostID:       event.PostID,
+			PostText:     event.PostText,
+			AuthorUserID: event.AuthorUserID,
+		},
+	}
+}
+
+// WebSocketDialogMessage новое сообщение диалога
+type WebSocketDialogMessage struct {
+	MessageID  string    `json:"message_id"`
+	FromUserID string    `json:"from_user_id"`
+	ToUserID   string    `json:"to_user_id"`
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebSocketTyping собеседник набирает сообщение
+type WebSocketTyping struct {
+	UserID string `json:"user_id"`
+}
+
+// NewDialogWebSocketMessage сообщение message.new для участника диалога userID в канал диалога с собеседником
+func NewDialogWebSocketMessage(message *WebSocketDialogMessage, userID string) *WebSocketMessage {
+	peerID := message.ToUserID
+	if userID == message.ToUserID {
+		peerID = message.FromUserID
+	}
+
+	return &WebSocketMessage{
+		Type:    WebSocketMessageTypeMessageNew,
+		Channel: WebSocketDialogChannel(peerID),
+		Payload: message,
+	}
+}
+
+// NewDialogReadWebSocketMessage сообщение read о позиции прочтения для участника диалога userID
+// в канал диалога с собеседником
+func NewDialogReadWebSocketMessage(read *DialogRead, userID string) *WebSocketMessage {
+	peerID := read.PeerID
+	if userID == read.PeerID {
+		peerID = read.UserID
+	}
+
+	return &WebSocketMessage{
+		Type:    WebSocketMessageTypeRead,
+		Channel: WebSocketDialogChannel(peerID),
+		Payload: read,
+	}
+}
+
+// WebSocketSubscription запрос подписки или отписки от канала
+type WebSocketSubscription struct {
+	Channel string `json:"channel"`
+}
+
+// WebSocketResume запрос сообщений, пропущенных с момента отключения
+type WebSocketResume struct {
+	// LastEventID номер последнего полученного сообщения журнала
+	LastEventID int64 `json:"last_event_id"`
+}
+
+// WebSocketReadRequest запрос клиента отметить сообщения диалога прочитанными до MessageID включительно
+type WebSocketReadRequest struct {
+	Channel   string `json:"channel"`
+	MessageID string `json:"message_id"`
+}
+
+// WebSocketAck подтверждение запроса клиента
+type WebSocketAck struct {
+	Channel       string   `json:"channel,omitempty"`
+	Subscriptions []string `json:"subscriptions"`
+}
+
+// WebSocketError ошибка в ответ на сообщение клиента
+type WebSocketError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// WebSocketDialogChannel канал диалога с пользователем userID
+func WebSocketDialogChannel(userID string) string {
+	return WebSocketChannelDialogPrefix + userID
+}
+
+// WebSocketDialogPeer возвращает собеседника из канала диалога и false, если канал не диалог
+func WebSocketDialogPeer(channel string) (string, bool) {
+	if !strings.HasPrefix(channel, WebSocketChannelDialogPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(channel, WebSocketChannelDialogPrefix), true
+}
+
+// WebSocketConnection представляет WebSocket соединение. У пользователя может быть
+// несколько соединений (вкладки, устройства), каждое со своим ID
 type WebSocketConnection struct {
 	ID     string
 	UserID string
 	Send   chan []byte
-	Hub    *WebSocketHub
-}
-
-// WebSocketHub управляет всеми WebSocket соединениями
-type WebSocketHub struct {
-	Connections map[string]*WebSocketConnection
-	Register    chan *WebSocketConnection
-	Unregister  chan *WebSocketConnection
-	Broadcast   chan *WebSocketPost
+	// Done закрывается, когда соединение нужно закрыть
+	Done chan struct{}
 }
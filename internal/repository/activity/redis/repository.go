@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"otus-project/internal/client/cache"
+	"otus-project/internal/model"
+	"otus-project/internal/repository"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	fieldLastLogin    = "last_login"
+	fieldLastFeedRead = "last_feed_read"
+
+	// activityTTL сколько хранятся сигналы активности: дольше пользователь считается неактивным
+	activityTTL = 30 * 24 * time.Hour
+)
+
+type repo struct {
+	cl cache.RedisClient
+}
+
+// NewRepository создает репозиторий активности пользователей в Redis
+func NewRepository(cl cache.RedisClient) repository.ActivityRepository {
+	return &repo{cl: cl}
+}
+
+func activityKey(userId string) string {
+	return fmt.Sprintf("activity:%s", userId)
+}
+
+func onlineKey(userId string) string {
+	return fmt.Sprintf("online:%s", userId)
+}
+
+// TouchLogin запоминает время входа пользователя
+func (r *repo) TouchLogin(ctx context.Context, userId string) error {
+	return r.touch(ctx, userId, fieldLastLogin)
+}
+
+// TouchFeedRead запоминает время чтения ленты пользователем
+func (r *repo) TouchFeedRead(ctx context.Context, userId string) error {
+	return r.touch(ctx, userId, fieldLastFeedRead)
+}
+
+func (r *repo) touch(ctx context.Context, userId, field string) error {
+	return r.cl.HSet(ctx, activityKey(userId), map[string]int64{field: time.Now().Unix()}, activityTTL)
+}
+
+// SetOnline отмечает пользователя онлайн на ttl
+func (r *repo) SetOnline(ctx context.Context, userId string, ttl time.Duration) error {
+	return r.cl.Set(ctx, onlineKey(userId), 1, ttl)
+}
+
+// SetOffline снимает отметку онлайн
+func (r *repo) SetOffline(ctx context.Context, userId string) error {
+	return r.cl.Del(ctx, onlineKey(userId))
+}
+
+// storedActivity сигналы активности в хэше activity:{user_id}
+type storedActivity struct {
+	LastLogin    int64 `redis:"last_login"`
+	LastFeedRead int64 `redis:"last_feed_read"`
+}
+
+// GetActivity возвращает сигналы активности пользователя
+func (r *repo) GetActivity(ctx context.Context, userId string) (*model.UserActivity, error) {
+	activities, err := r.GetActivities(ctx, []string{userId})
+	if err != nil {
+		return nil, err
+	}
+
+	return activities[0], nil
+}
+
+// GetActivities возвращает сигналы активности нескольких пользователей за два запроса к Redis
+func (r *repo) GetActivities(ctx context.Context, userIds []string) ([]*model.UserActivity, error) {
+	activityKeys := make([]string, 0, len(userIds))
+	onlineKeys := make([]string, 0, len(userIds))
+	for _, userId := range userIds {
+		activityKeys = append(activityKeys, activityKey(userId))
+		onlineKeys = append(onlineKeys, onlineKey(userId))
+	}
+
+	values, err := r.cl.HGetAllMulti(ctx, activityKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	online, err := r.cl.MGet(ctx, onlineKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	activities := make([]*model.UserActivity, 0, len(userIds))
+	for i, userId := range userIds {
+		var stored storedActivity
+		if err = redigo.ScanStruct(values[i], &stored); err != nil {
+			return nil, err
+		}
+
+		activity := &model.UserActivity{
+			UserID: userId,
+			Online: online[i] != nil,
+		}
+		if stored.LastLogin > 0 {
+			activity.LastLoginAt = time.Unix(stored.LastLogin, 0)
+		}
+		if stored.LastFeedRead > 0 {
+			activity.LastFeedReadAt = time.Unix(stored.LastFeedRead, 0)
+		}
+
+		activities = append(activities, activity)
+	}
+
+	return activities, nil
+}
@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"otus-project/internal/client/cache"
+	"otus-project/internal/repository"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+type repo struct {
+	cl cache.RedisClient
+}
+
+// NewRepository создает реестр WebSocket соединений в Redis
+func NewRepository(cl cache.RedisClient) repository.ConnectionRegistryRepository {
+	return &repo{cl: cl}
+}
+
+// nodesKey ключ хэша узлов пользователя: поле - ID узла, значение - unix время, до которого
+// отметка узла действительна. Отметки упавших узлов перестают учитываться по истечении этого времени
+func nodesKey(userId string) string {
+	return fmt.Sprintf("ws:nodes:%s", userId)
+}
+
+// Register отмечает узел в хэшах пользователей и продлевает их время жизни
+func (r *repo) Register(ctx context.Context, nodeId string, userIds []string, ttl time.Duration) error {
+	expiresAt := time.Now().Add(ttl).Unix()
+	for _, userId := range userIds {
+		if err := r.cl.HSet(ctx, nodesKey(userId), map[string]int64{nodeId: expiresAt}, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unregister удаляет узел из хэша пользователя
+func (r *repo) Unregister(ctx context.Context, nodeId, userId string) error {
+	return r.cl.HDel(ctx, nodesKey(userId), nodeId)
+}
+
+// Nodes читает хэши узлов пользователей одним проходом и отбрасывает истекшие отметки
+func (r *repo) Nodes(ctx context.Context, userIds []string) (map[string][]string, error) {
+	keys := make([]string, 0, len(userIds))
+	for _, userId := range userIds {
+		keys = append(keys, nodesKey(userId))
+	}
+
+	values, err := r.cl.HGetAllMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	result := make(map[string][]string)
+	for i, userId := range userIds {
+		nodes, err := redigo.Int64Map(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for nodeId, expiresAt := range nodes {
+			if expiresAt > now {
+				result[userId] = append(result[userId], nodeId)
+			}
+		}
+	}
+
+	return result, nil
+}
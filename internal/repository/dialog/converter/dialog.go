@@ -8,6 +8,7 @@ import (
 // ToDialogMessageFromRepo конвертирует модель репозитория в сервисную модель
 func ToDialogMessageFromRepo(msg *repoModel.DialogMessage) *model.DialogMessage {
 	return &model.DialogMessage{
+		ID:        msg.ID,
 		From:      msg.FromUserID,
 		To:        msg.ToUserID,
 		Text:      msg.Text,
@@ -29,3 +30,14 @@ func ToDialogMessagesFromRepo(messages []*repoModel.DialogMessage) []*model.Dial
 	}
 	return result
 }
+
+// ToDialogReadFromRepo конвертирует позицию прочтения репозитория в сервисную модель
+func ToDialogReadFromRepo(read *repoModel.DialogRead) *model.DialogRead {
+	return &model.DialogRead{
+		UserID:           read.UserID,
+		PeerID:           read.PeerID,
+		MessageID:        read.MessageID,
+		MessageCreatedAt: read.MessageCreatedAt,
+		ReadAt:           read.ReadAt,
+	}
+}
@@ -15,3 +15,17 @@ type DialogMessage struct {
 	// CreatedAt время создания сообщения
 	CreatedAt time.Time
 }
+
+// DialogRead позиция прочтения диалога для репозитория
+type DialogRead struct {
+	// UserID идентификатор прочитавшего пользователя
+	UserID string
+	// PeerID идентификатор собеседника
+	PeerID string
+	// MessageID идентификатор последнего прочитанного сообщения
+	MessageID string
+	// MessageCreatedAt время создания последнего прочитанного сообщения
+	MessageCreatedAt time.Time
+	// ReadAt время прочтения
+	ReadAt time.Time
+}
@@ -12,11 +12,13 @@ import (
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/pkg/errors"
 )
 
 const (
-	tableName = "dialog_messages"
+	tableName      = "dialog_messages"
+	readsTableName = "dialog_reads"
 
 	idColumn         = "id"
 	fromUserIdColumn = "from_user_id"
@@ -24,6 +26,12 @@ const (
 	textColumn       = "text"
 	createdAtColumn  = "created_at"
 	dialogKeyColumn  = "dialog_key"
+
+	userIdColumn           = "user_id"
+	peerIdColumn           = "peer_id"
+	messageIdColumn        = "message_id"
+	messageCreatedAtColumn = "message_created_at"
+	readAtColumn           = "read_at"
 )
 
 type repo struct {
@@ -35,17 +43,24 @@ func NewRepository(db db.Client) repository.DialogRepository {
 }
 
 // SendMessage сохраняет сообщение в диалоге
-func (r *repo) SendMessage(ctx context.Context, fromUserId, toUserId, text string) error {
+func (r *repo) SendMessage(ctx context.Context, fromUserId, toUserId, text string) (*model.DialogMessage, error) {
 	key := utils.GenerateDialogKey(fromUserId, toUserId)
+	message := &model.DialogMessage{
+		ID:        uuid.New().String(),
+		From:      fromUserId,
+		To:        toUserId,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
 
 	builder := sq.Insert(tableName).
 		PlaceholderFormat(sq.Dollar).
 		Columns(idColumn, fromUserIdColumn, toUserIdColumn, textColumn, createdAtColumn, dialogKeyColumn).
-		Values(uuid.New().String(), fromUserId, toUserId, text, time.Now(), key)
+		Values(message.ID, message.From, message.To, message.Text, message.CreatedAt, key)
 
 	query, args, err := builder.ToSql()
 	if err != nil {
-		return errors.Wrap(err, "failed to build insert query")
+		return nil, errors.Wrap(err, "failed to build insert query")
 	}
 
 	q := db.Query{
@@ -55,14 +70,15 @@ func (r *repo) SendMessage(ctx context.Context, fromUserId, toUserId, text strin
 
 	_, err = r.db.DB().ExecContext(ctx, q, args...)
 	if err != nil {
-		return errors.Wrap(err, "failed to execute insert query")
+		return nil, errors.Wrap(err, "failed to execute insert query")
 	}
 
-	return nil
+	return message, nil
 }
 
 // GetDialogList возвращает список сообщений диалога между двумя пользователями
-func (r *repo) GetDialogList(ctx context.Context, userId1, userId2 string) ([]*model.DialogMessage, error) {
+// в хронологическом порядке. Пагинация по (created_at, id), курсор nil означает первую страницу.
+func (r *repo) GetDialogList(ctx context.Context, userId1, userId2 string, cursor *model.Cursor, limit int) ([]*model.DialogMessage, error) {
 	// Для Citus: используем равенство по dialog_key для таргетинга шардирования (Эффект Леди Гаги)
 	key := utils.GenerateDialogKey(userId1, userId2)
 
@@ -70,7 +86,12 @@ func (r *repo) GetDialogList(ctx context.Context, userId1, userId2 string) ([]*m
 		PlaceholderFormat(sq.Dollar).
 		From(tableName).
 		Where(sq.Eq{dialogKeyColumn: key}).
-		OrderBy(createdAtColumn + " ASC")
+		OrderBy(createdAtColumn+" ASC", idColumn+" ASC").
+		Limit(uint64(limit))
+
+	if cursor != nil {
+		builder = builder.Where(sq.Expr("("+createdAtColumn+", "+idColumn+") > (?, ?)", cursor.CreatedAt, cursor.ID))
+	}
 
 	query, args, err := builder.ToSql()
 	if err != nil {
@@ -105,3 +126,103 @@ func (r *repo) GetDialogList(ctx context.Context, userId1, userId2 string) ([]*m
 	// Конвертируем в сервисные модели
 	return converter.ToDialogMessagesFromRepo(messages), nil
 }
+
+// MarkRead сдвигает позицию прочтения вперед. Позиции сравниваются по (created_at, id) сообщения,
+// поэтому запоздавшее прочтение более раннего сообщения с другого устройства позицию не откатывает
+func (r *repo) MarkRead(ctx context.Context, userId, peerId, messageId string) (*model.DialogRead, error) {
+	key := utils.GenerateDialogKey(userId, peerId)
+
+	builder := sq.Select(createdAtColumn).
+		PlaceholderFormat(sq.Dollar).
+		From(tableName).
+		Where(sq.Eq{dialogKeyColumn: key, idColumn: messageId})
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build select query")
+	}
+
+	q := db.Query{
+		Name:     "dialog_repository.MarkRead.Message",
+		QueryRaw: query,
+	}
+
+	read := repoModel.DialogRead{
+		UserID:    userId,
+		PeerID:    peerId,
+		MessageID: messageId,
+		ReadAt:    time.Now(),
+	}
+	if err = r.db.DB().QueryRowContext(ctx, q, args...).Scan(&read.MessageCreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, model.ErrorDialogMessageNotFound
+		}
+		return nil, errors.Wrap(err, "failed to execute select query")
+	}
+
+	query = `
+		INSERT INTO ` + readsTableName + ` (dialog_key, user_id, peer_id, message_id, message_created_at, read_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (dialog_key, user_id) DO UPDATE SET
+			message_id = EXCLUDED.message_id,
+			message_created_at = EXCLUDED.message_created_at,
+			read_at = EXCLUDED.read_at
+		WHERE (` + readsTableName + `.message_created_at, ` + readsTableName + `.message_id) < (EXCLUDED.message_created_at, EXCLUDED.message_id)
+	`
+
+	q = db.Query{
+		Name:     "dialog_repository.MarkRead",
+		QueryRaw: query,
+	}
+
+	tag, err := r.db.DB().ExecContext(ctx, q, key, read.UserID, read.PeerID, read.MessageID, read.MessageCreatedAt, read.ReadAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute upsert query")
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, nil
+	}
+
+	return converter.ToDialogReadFromRepo(&read), nil
+}
+
+// GetReads возвращает позиции прочтения участников диалога
+func (r *repo) GetReads(ctx context.Context, userId1, userId2 string) ([]*model.DialogRead, error) {
+	key := utils.GenerateDialogKey(userId1, userId2)
+
+	builder := sq.Select(userIdColumn, peerIdColumn, messageIdColumn, messageCreatedAtColumn, readAtColumn).
+		PlaceholderFormat(sq.Dollar).
+		From(readsTableName).
+		Where(sq.Eq{dialogKeyColumn: key})
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build select query")
+	}
+
+	q := db.Query{
+		Name:     "dialog_repository.GetReads",
+		QueryRaw: query,
+	}
+
+	rows, err := r.db.DB().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute select query")
+	}
+	defer rows.Close()
+
+	reads := make([]*model.DialogRead, 0, 2)
+	for rows.Next() {
+		var read repoModel.DialogRead
+		if err := rows.Scan(&read.UserID, &read.PeerID, &read.MessageID, &read.MessageCreatedAt, &read.ReadAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan row")
+		}
+		reads = append(reads, converter.ToDialogReadFromRepo(&read))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating rows")
+	}
+
+	return reads, nil
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"otus-project/internal/client/cache"
+	"otus-project/internal/model"
+	"otus-project/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+type repo struct {
+	cl        cache.RedisClient
+	maxLength int
+	ttl       time.Duration
+}
+
+// NewRepository создает журнал WebSocket сообщений в Redis. В журнале пользователя хранится
+// не больше maxLength последних сообщений, журнал удаляется через ttl после последней записи
+func NewRepository(cl cache.RedisClient, maxLength int, ttl time.Duration) repository.EventLogRepository {
+	return &repo{
+		cl:        cl,
+		maxLength: maxLength,
+		ttl:       ttl,
+	}
+}
+
+// seqKey ключ счетчика номеров сообщений пользователя
+func seqKey(userId string) string {
+	return fmt.Sprintf("ws:events:seq:%s", userId)
+}
+
+// logKey ключ отсортированного множества сообщений пользователя. Счет - номер сообщения,
+// элемент - "<номер>:<сообщение в JSON>", номер делает одинаковые сообщения разными элементами
+func logKey(userId string) string {
+	return fmt.Sprintf("ws:events:%s", userId)
+}
+
+// Append записывает сообщение в журналы пользователей одним проходом
+func (r *repo) Append(ctx context.Context, userIds []string, message *model.WebSocketMessage) ([]int64, error) {
+	// Номер не хранится в теле сообщения, он восстанавливается из счета при чтении
+	stored := *message
+	stored.EventID = 0
+
+	body, err := json.Marshal(&stored)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]cache.ZAppendEntry, 0, len(userIds))
+	for _, userId := range userIds {
+		entries = append(entries, cache.ZAppendEntry{
+			SeqKey: seqKey(userId),
+			Key:    logKey(userId),
+			Value:  string(body),
+		})
+	}
+
+	return r.cl.ZAppend(ctx, entries, r.maxLength, r.ttl)
+}
+
+// Since возвращает сообщения журнала после afterId
+func (r *repo) Since(ctx context.Context, userId string, afterId int64, limit int) ([]*model.WebSocketMessage, bool, error) {
+	// Счетчик читается раньше журнала: сообщения, записанные между запросами, не влияют на проверку
+	seq, err := redigo.Int64(r.cl.Get(ctx, seqKey(userId)))
+	if err != nil && err != redigo.ErrNil {
+		return nil, false, err
+	}
+
+	// Номер больше текущего значения счетчика: журнал истек и нумерация началась заново
+	if afterId > seq {
+		return nil, false, nil
+	}
+	if afterId == seq {
+		return nil, true, nil
+	}
+
+	min := "(" + strconv.FormatInt(afterId, 10)
+	members, err := r.cl.ZRangeByScore(ctx, logKey(userId), min, "+inf", 0, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Первое сообщение после afterId вытеснено из журнала или пропущенных сообщений больше limit
+	if len(members) == 0 || int64(members[0].Score) != afterId+1 || len(members) > limit {
+		return nil, false, nil
+	}
+
+	messages := make([]*model.WebSocketMessage, 0, len(members))
+	for _, member := range members {
+		_, body, ok := strings.Cut(member.Member, ":")
+		if !ok {
+			return nil, false, fmt.Errorf("invalid event log entry %q", member.Member)
+		}
+
+		var message model.WebSocketMessage
+		if err := json.Unmarshal([]byte(body), &message); err != nil {
+			return nil, false, err
+		}
+		message.EventID = int64(member.Score)
+
+		messages = append(messages, &message)
+	}
+
+	return messages, true, nil
+}
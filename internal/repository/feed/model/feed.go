@@ -10,19 +10,31 @@ type MaterializedFeed struct {
 	UserID    string    `db:"user_id"`
 	PostID    string    `db:"post_id"`
 	AuthorID  string    `db:"author_id"`
-	PostText  string    `db:"post_text"`
+	PostText  string    `db:"post_text"` // подтягивается из posts при чтении
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 }
 
+// Статусы заданий на материализацию ленты
+const (
+	FeedJobStatusPending    = "pending" // задание повторно опубликовано в очередь
+	FeedJobStatusProcessing = "processing"
+	FeedJobStatusCompleted  = "completed"
+	FeedJobStatusFailed     = "failed"
+)
+
 // FeedJob представляет задание на материализацию ленты
 type FeedJob struct {
-	ID        string    `db:"id"`
-	UserID    string    `db:"user_id"`
-	PostID    string    `db:"post_id"`
-	Status    string    `db:"status"` // pending, processing, completed, failed
-	Priority  int       `db:"priority"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
-	Error     *string   `db:"error"`
+	ID          string     `db:"id"`
+	UserID      string     `db:"user_id"` // пусто для раскладки поста по всем лентам
+	PostID      string     `db:"post_id"`
+	EventType   string     `db:"event_type"`
+	Status      string     `db:"status"` // pending, processing, completed, failed
+	Priority    int        `db:"priority"`
+	Attempts    int        `db:"attempts"`
+	NextRetryAt *time.Time `db:"next_retry_at"`
+	Payload     []byte     `db:"payload"` // исходная задача в JSON
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	Error       *string    `db:"error"`
 }
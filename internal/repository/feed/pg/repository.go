@@ -3,11 +3,13 @@ package pg
 import (
 	"context"
 	"otus-project/internal/client/db"
+	"otus-project/internal/model"
 	"otus-project/internal/repository/feed"
 	feedModel "otus-project/internal/repository/feed/model"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
 )
 
 type repository struct {
@@ -21,43 +23,55 @@ func NewRepository(db db.Client) feed.Repository {
 	}
 }
 
-// AddToFeed добавляет пост в материализованную ленту пользователя
-func (r *repository) AddToFeed(ctx context.Context, userID, postID, authorID, postText string) error {
+// AddToFeeds добавляет ссылку на пост в материализованные ленты пользователей
+// одним многострочным INSERT ... ON CONFLICT
+func (r *repository) AddToFeeds(ctx context.Context, userIDs []string, postID, authorID string, createdAt time.Time) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
 	query := `
-		INSERT INTO materialized_feeds (id, user_id, post_id, author_id, post_text, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO materialized_feeds (id, user_id, post_id, author_id, created_at, updated_at)
+		SELECT gen_random_uuid(), u.user_id, $2, $3, $4, $5
+		FROM unnest($1::uuid[]) AS u(user_id)
 		ON CONFLICT (user_id, post_id) DO UPDATE SET
-			post_text = EXCLUDED.post_text,
 			updated_at = EXCLUDED.updated_at
 	`
 
-	now := time.Now()
 	q := db.Query{
-		Name:     "feed_repository.AddToFeed",
+		Name:     "feed_repository.AddToFeeds",
 		QueryRaw: query,
 	}
 
 	_, err := r.db.DB().ExecContext(ctx, q,
-		uuid.New().String(),
-		userID,
+		userIDs,
 		postID,
 		authorID,
-		postText,
-		now,
-		now,
+		createdAt,
+		time.Now(),
 	)
 
 	return err
 }
 
-// GetFeed получает материализованную ленту пользователя
-func (r *repository) GetFeed(ctx context.Context, userID string, offset, limit int) ([]*feedModel.MaterializedFeed, error) {
+// GetFeed получает материализованную ленту пользователя.
+// Пагинация по (created_at, post_id), курсор nil означает первую страницу.
+func (r *repository) GetFeed(ctx context.Context, userID string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error) {
+	args := []interface{}{userID, limit}
+	cursorCondition := ""
+	if cursor != nil {
+		cursorCondition = "AND (mf.created_at, mf.post_id) < ($3, $4)"
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	// Текст поста подтягивается из posts, удаленные посты в ленту не попадают
 	query := `
-		SELECT id, user_id, post_id, author_id, post_text, created_at, updated_at
-		FROM materialized_feeds
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
+		SELECT mf.id, mf.user_id, mf.post_id, mf.author_id, COALESCE(p.content, ''), mf.created_at, mf.updated_at
+		FROM materialized_feeds mf
+		JOIN posts p ON p.id = mf.post_id
+		WHERE mf.user_id = $1 ` + cursorCondition + `
+		ORDER BY mf.created_at DESC, mf.post_id DESC
+		LIMIT $2
 	`
 
 	q := db.Query{
@@ -65,7 +79,7 @@ func (r *repository) GetFeed(ctx context.Context, userID string, offset, limit i
 		QueryRaw: query,
 	}
 
-	rows, err := r.db.DB().QueryContext(ctx, q, userID, limit, offset)
+	rows, err := r.db.DB().QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -92,22 +106,127 @@ func (r *repository) GetFeed(ctx context.Context, userID string, offset, limit i
 	return feeds, nil
 }
 
-// RemoveFromFeed удаляет пост из материализованной ленты пользователя
-func (r *repository) RemoveFromFeed(ctx context.Context, userID, postID string) error {
-	query := `DELETE FROM materialized_feeds WHERE user_id = $1 AND post_id = $2`
+// RemovePostFromFeeds удаляет пост из всех материализованных лент
+func (r *repository) RemovePostFromFeeds(ctx context.Context, postID string) error {
+	query := `DELETE FROM materialized_feeds WHERE post_id = $1`
+	q := db.Query{
+		Name:     "feed_repository.RemovePostFromFeeds",
+		QueryRaw: query,
+	}
+	_, err := r.db.DB().ExecContext(ctx, q, postID)
+	return err
+}
+
+// BackfillFromAuthor добавляет в ленту пользователя последние limit постов автора
+func (r *repository) BackfillFromAuthor(ctx context.Context, userID, authorID string, limit int) error {
+	query := `
+		INSERT INTO materialized_feeds (id, user_id, post_id, author_id, created_at, updated_at)
+		SELECT gen_random_uuid(), $1, p.id, p.author_user_id, p.created_at, $3
+		FROM (
+			SELECT id, author_user_id, created_at
+			FROM posts
+			WHERE author_user_id = $2
+			ORDER BY created_at DESC
+			LIMIT $4
+		) p
+		ON CONFLICT (user_id, post_id) DO NOTHING
+	`
+
 	q := db.Query{
-		Name:     "feed_repository.RemoveFromFeed",
+		Name:     "feed_repository.BackfillFromAuthor",
 		QueryRaw: query,
 	}
-	_, err := r.db.DB().ExecContext(ctx, q, userID, postID)
+	_, err := r.db.DB().ExecContext(ctx, q, userID, authorID, time.Now(), limit)
 	return err
 }
 
+// RemoveAuthorFromFeed удаляет из ленты пользователя все посты автора
+func (r *repository) RemoveAuthorFromFeed(ctx context.Context, userID, authorID string) error {
+	query := `DELETE FROM materialized_feeds WHERE user_id = $1 AND author_id = $2`
+	q := db.Query{
+		Name:     "feed_repository.RemoveAuthorFromFeed",
+		QueryRaw: query,
+	}
+	_, err := r.db.DB().ExecContext(ctx, q, userID, authorID)
+	return err
+}
+
+// TrimByCount проходит batchSize пользователей с ID больше afterUserID и удаляет записи их лент,
+// не входящие в maxEntries последних. Границу каждой ленты находит OFFSET по
+// idx_materialized_feeds_user_created_post, поэтому таблица целиком не сортируется
+func (r *repository) TrimByCount(ctx context.Context, afterUserID string, maxEntries, batchSize int) (string, int64, error) {
+	query := `
+		WITH batch AS (
+			SELECT id AS user_id
+			FROM users
+			WHERE $1 = '' OR id > $1::uuid
+			ORDER BY id
+			LIMIT $3
+		), boundary AS (
+			SELECT b.user_id, c.created_at, c.post_id
+			FROM batch b
+			CROSS JOIN LATERAL (
+				SELECT mf.created_at, mf.post_id
+				FROM materialized_feeds mf
+				WHERE mf.user_id = b.user_id
+				ORDER BY mf.created_at DESC, mf.post_id DESC
+				OFFSET $2
+				LIMIT 1
+			) c
+		), deleted AS (
+			DELETE FROM materialized_feeds mf
+			USING boundary b
+			WHERE mf.user_id = b.user_id AND (mf.created_at, mf.post_id) <= (b.created_at, b.post_id)
+			RETURNING 1
+		)
+		SELECT COALESCE((SELECT MAX(user_id)::text FROM batch), ''), (SELECT COUNT(*) FROM deleted)
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.TrimByCount",
+		QueryRaw: query,
+	}
+
+	var (
+		lastUserID string
+		deleted    int64
+	)
+	if err := r.db.DB().QueryRowContext(ctx, q, afterUserID, maxEntries, batchSize).Scan(&lastUserID, &deleted); err != nil {
+		return "", 0, err
+	}
+
+	return lastUserID, deleted, nil
+}
+
+// TrimByAge удаляет до batchSize записей ленты, созданных раньше olderThan
+func (r *repository) TrimByAge(ctx context.Context, olderThan time.Time, batchSize int) (int64, error) {
+	query := `
+		DELETE FROM materialized_feeds
+		WHERE id IN (
+			SELECT id
+			FROM materialized_feeds
+			WHERE created_at < $1
+			LIMIT $2
+		)
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.TrimByAge",
+		QueryRaw: query,
+	}
+	tag, err := r.db.DB().ExecContext(ctx, q, olderThan, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
 // CreateJob создает задание на материализацию ленты
 func (r *repository) CreateJob(ctx context.Context, job *feedModel.FeedJob) error {
 	query := `
-		INSERT INTO feed_jobs (id, user_id, post_id, status, priority, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO feed_jobs (id, user_id, post_id, event_type, status, priority, attempts, payload, created_at, updated_at)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 
 	now := time.Now()
@@ -120,8 +239,11 @@ func (r *repository) CreateJob(ctx context.Context, job *feedModel.FeedJob) erro
 		job.ID,
 		job.UserID,
 		job.PostID,
+		job.EventType,
 		job.Status,
 		job.Priority,
+		job.Attempts,
+		job.Payload,
 		now,
 		now,
 	)
@@ -146,22 +268,189 @@ func (r *repository) UpdateJobStatus(ctx context.Context, jobID, status string,
 	return err
 }
 
-// GetPendingJobs получает задания со статусом pending
-func (r *repository) GetPendingJobs(ctx context.Context, limit int) ([]*feedModel.FeedJob, error) {
+// StartJob переводит повторно опубликованное задание в processing
+func (r *repository) StartJob(ctx context.Context, jobID string) (int, error) {
+	query := `
+		UPDATE feed_jobs
+		SET status = $1, attempts = attempts + 1, updated_at = $2
+		WHERE id = $3 AND status = $4
+		RETURNING attempts
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.StartJob",
+		QueryRaw: query,
+	}
+
+	var attempts int
+	err := r.db.DB().QueryRowContext(ctx, q,
+		feedModel.FeedJobStatusProcessing,
+		time.Now(),
+		jobID,
+		feedModel.FeedJobStatusPending,
+	).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, model.ErrorFeedJobNotPending
+		}
+
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// FailJob помечает задание упавшим и назначает время следующей попытки
+func (r *repository) FailJob(ctx context.Context, jobID, errorMsg string, nextRetryAt time.Time) error {
+	query := `
+		UPDATE feed_jobs
+		SET status = $1, error = $2, next_retry_at = $3, updated_at = $4
+		WHERE id = $5
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.FailJob",
+		QueryRaw: query,
+	}
+	_, err := r.db.DB().ExecContext(ctx, q, feedModel.FeedJobStatusFailed, errorMsg, nextRetryAt, time.Now(), jobID)
+	return err
+}
+
+// TouchJob обновляет updated_at задания в processing после очередной пачки получателей
+func (r *repository) TouchJob(ctx context.Context, jobID string) error {
 	query := `
-		SELECT id, user_id, post_id, status, priority, created_at, updated_at, error
-		FROM feed_jobs
-		WHERE status = 'pending'
-		ORDER BY priority ASC, created_at ASC
-		LIMIT $1
+		UPDATE feed_jobs
+		SET updated_at = $1
+		WHERE id = $2 AND status = $3
 	`
 
 	q := db.Query{
-		Name:     "feed_repository.GetPendingJobs",
+		Name:     "feed_repository.TouchJob",
 		QueryRaw: query,
 	}
+	_, err := r.db.DB().ExecContext(ctx, q, time.Now(), jobID, feedModel.FeedJobStatusProcessing)
+	return err
+}
+
+// AddJobRecipients отмечает получателей, по лентам которых задание уже разложило событие
+func (r *repository) AddJobRecipients(ctx context.Context, jobID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO feed_job_recipients (job_id, user_id)
+		SELECT $1, u.user_id
+		FROM unnest($2::uuid[]) AS u(user_id)
+		ON CONFLICT (job_id, user_id) DO NOTHING
+	`
 
-	rows, err := r.db.DB().QueryContext(ctx, q, limit)
+	q := db.Query{
+		Name:     "feed_repository.AddJobRecipients",
+		QueryRaw: query,
+	}
+	_, err := r.db.DB().ExecContext(ctx, q, jobID, userIDs)
+	return err
+}
+
+// GetJobRecipients возвращает получателей, отмеченных AddJobRecipients
+func (r *repository) GetJobRecipients(ctx context.Context, jobID string) ([]string, error) {
+	query := `SELECT user_id FROM feed_job_recipients WHERE job_id = $1`
+	q := db.Query{
+		Name:     "feed_repository.GetJobRecipients",
+		QueryRaw: query,
+	}
+
+	rows, err := r.db.DB().QueryContext(ctx, q, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// DeleteJobRecipients удаляет отметки получателей завершенного задания
+func (r *repository) DeleteJobRecipients(ctx context.Context, jobID string) error {
+	query := `DELETE FROM feed_job_recipients WHERE job_id = $1`
+	q := db.Query{
+		Name:     "feed_repository.DeleteJobRecipients",
+		QueryRaw: query,
+	}
+	_, err := r.db.DB().ExecContext(ctx, q, jobID)
+	return err
+}
+
+// FailStuckJobs помечает упавшими задания, зависшие в processing или pending. Задание в processing
+// продлевается после каждой пачки получателей, а pending до next_retry_at ждет в очереди повторов.
+// Повтор назначается сразу: таймаут уже выдержан
+func (r *repository) FailStuckJobs(ctx context.Context, stuckBefore time.Time) (int64, error) {
+	query := `
+		UPDATE feed_jobs
+		SET status = $1, error = 'job timed out', next_retry_at = $2, updated_at = $2
+		WHERE status IN ($3, $4) AND updated_at < $5
+			AND NOT (status = $4 AND next_retry_at > $2)
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.FailStuckJobs",
+		QueryRaw: query,
+	}
+	tag, err := r.db.DB().ExecContext(ctx, q,
+		feedModel.FeedJobStatusFailed,
+		time.Now(),
+		feedModel.FeedJobStatusProcessing,
+		feedModel.FeedJobStatusPending,
+		stuckBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ClaimJobsForRetry переводит в pending упавшие задания, готовые к повтору.
+// SKIP LOCKED позволяет нескольким воркерам забирать задания без дублей
+func (r *repository) ClaimJobsForRetry(ctx context.Context, maxAttempts, limit int) ([]*feedModel.FeedJob, error) {
+	query := `
+		UPDATE feed_jobs
+		SET status = $1, updated_at = $2
+		WHERE id IN (
+			SELECT id
+			FROM feed_jobs
+			WHERE status = $3
+				AND attempts < $4
+				AND payload IS NOT NULL
+				AND (next_retry_at IS NULL OR next_retry_at <= $2)
+			ORDER BY priority ASC, created_at ASC
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, COALESCE(user_id::text, ''), post_id, COALESCE(event_type, ''), status, priority, attempts, next_retry_at, payload, created_at, updated_at, error
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.ClaimJobsForRetry",
+		QueryRaw: query,
+	}
+
+	rows, err := r.db.DB().QueryContext(ctx, q,
+		feedModel.FeedJobStatusPending,
+		time.Now(),
+		feedModel.FeedJobStatusFailed,
+		maxAttempts,
+		limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -174,8 +463,12 @@ func (r *repository) GetPendingJobs(ctx context.Context, limit int) ([]*feedMode
 			&job.ID,
 			&job.UserID,
 			&job.PostID,
+			&job.EventType,
 			&job.Status,
 			&job.Priority,
+			&job.Attempts,
+			&job.NextRetryAt,
+			&job.Payload,
 			&job.CreatedAt,
 			&job.UpdatedAt,
 			&job.Error,
@@ -186,7 +479,7 @@ func (r *repository) GetPendingJobs(ctx context.Context, limit int) ([]*feedMode
 		jobs = append(jobs, job)
 	}
 
-	return jobs, nil
+	return jobs, rows.Err()
 }
 
 // GetFriendsOfUser получает список друзей пользователя
@@ -223,3 +516,125 @@ func (r *repository) GetFriendsOfUser(ctx context.Context, userID string) ([]str
 
 	return friends, nil
 }
+
+// GetFriendsSample получает до limit друзей пользователя. UNION ALL без сортировки позволяет
+// остановить чтение после limit строк, поэтому друг, связанный в обе стороны, может повториться
+func (r *repository) GetFriendsSample(ctx context.Context, userID string, limit int) ([]string, error) {
+	query := `
+		SELECT friend_id FROM friends WHERE user_id = $1
+		UNION ALL
+		SELECT user_id FROM friends WHERE friend_id = $1
+		LIMIT $2
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.GetFriendsSample",
+		QueryRaw: query,
+	}
+
+	rows, err := r.db.ReplicaDB().QueryContext(ctx, q, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var friends []string
+	for rows.Next() {
+		var friendID string
+		if err := rows.Scan(&friendID); err != nil {
+			return nil, err
+		}
+		friends = append(friends, friendID)
+	}
+
+	return friends, rows.Err()
+}
+
+// GetCelebrityFriends получает друзей пользователя, у которых больше threshold друзей.
+// Количество друзей берется из users.friend_count, который поддерживает триггер на friends
+func (r *repository) GetCelebrityFriends(ctx context.Context, userID string, threshold int) ([]string, error) {
+	query := `
+		SELECT u.id
+		FROM users u
+		WHERE u.id IN (
+			SELECT friend_id FROM friends WHERE user_id = $1
+			UNION
+			SELECT user_id FROM friends WHERE friend_id = $1
+		)
+		AND u.friend_count > $2
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.GetCelebrityFriends",
+		QueryRaw: query,
+	}
+
+	rows, err := r.db.ReplicaDB().QueryContext(ctx, q, userID, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var celebrities []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		celebrities = append(celebrities, id)
+	}
+
+	return celebrities, rows.Err()
+}
+
+// GetRecentPostsByAuthors получает последние посты авторов в формате записей ленты.
+// Пагинация по (created_at, id), курсор nil означает первую страницу.
+func (r *repository) GetRecentPostsByAuthors(ctx context.Context, authorIDs []string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error) {
+	if len(authorIDs) == 0 {
+		return nil, nil
+	}
+
+	args := []interface{}{authorIDs, limit}
+	cursorCondition := ""
+	if cursor != nil {
+		cursorCondition = "AND (created_at, id) < ($3, $4)"
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	query := `
+		SELECT id, author_user_id, COALESCE(content, ''), created_at, COALESCE(updated_at, created_at)
+		FROM posts
+		WHERE author_user_id = ANY($1) ` + cursorCondition + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	q := db.Query{
+		Name:     "feed_repository.GetRecentPostsByAuthors",
+		QueryRaw: query,
+	}
+
+	rows, err := r.db.ReplicaDB().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []*feedModel.MaterializedFeed
+	for rows.Next() {
+		feed := &feedModel.MaterializedFeed{}
+		err := rows.Scan(
+			&feed.PostID,
+			&feed.AuthorID,
+			&feed.PostText,
+			&feed.CreatedAt,
+			&feed.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, rows.Err()
+}
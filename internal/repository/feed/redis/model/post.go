@@ -0,0 +1,9 @@
+package model
+
+// Post тело поста в кэше ленты
+type Post struct {
+	ID           string `redis:"id"`
+	AuthorUserID string `redis:"author_user_id"`
+	Text         string `redis:"text"`
+	CreatedAtUs  int64  `redis:"created_at"`
+}
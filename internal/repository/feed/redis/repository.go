@@ -0,0 +1,251 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"otus-project/internal/client/cache"
+	"otus-project/internal/model"
+	"otus-project/internal/repository/feed"
+	feedModel "otus-project/internal/repository/feed/model"
+	modelRepo "otus-project/internal/repository/feed/redis/model"
+	"strconv"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+type repository struct {
+	cl        cache.RedisClient
+	maxLength int
+	ttl       time.Duration
+}
+
+// NewRepository создает кэш материализованной ленты в Redis.
+// В ленте хранится не больше maxLength последних постов.
+func NewRepository(cl cache.RedisClient, maxLength int, ttl time.Duration) feed.CacheRepository {
+	return &repository{
+		cl:        cl,
+		maxLength: maxLength,
+		ttl:       ttl,
+	}
+}
+
+// feedKey ключ отсортированного множества постов ленты. Счет - время создания записи в микросекундах:
+// при равном счете Redis упорядочивает элементы по идентификатору, что совпадает
+// с порядком (created_at, post_id) в базе
+func feedKey(userID string) string {
+	return fmt.Sprintf("feed:%s", userID)
+}
+
+// warmKey временный ключ, в котором собирается прогреваемая лента
+func warmKey(userID string) string {
+	return fmt.Sprintf("feed:%s:warm", userID)
+}
+
+// feedEnd элемент ленты, отмечающий, что ниже него в базе записей нет. У него наименьший счет,
+// поэтому при обрезке ленты до maxLength он удаляется первым, и лента перестает считаться полной
+const feedEnd = "end"
+
+// postKey ключ хэша с телом поста
+func postKey(postID string) string {
+	return fmt.Sprintf("feed:post:%s", postID)
+}
+
+// GetFeed получает страницу ленты из кэша
+func (r *repository) GetFeed(ctx context.Context, userID string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, bool, error) {
+	max := "+inf"
+	if cursor != nil {
+		max = strconv.FormatInt(cursor.CreatedAt.UnixMicro(), 10)
+	}
+
+	// Элементы с тем же счетом, что и у курсора, отбрасываются по идентификатору,
+	// поэтому читаем пачками, пока не наберем страницу или не дойдем до конца ленты
+	members := make([]cache.ZMember, 0, limit)
+	complete := false
+	batch := limit + 1
+	for offset := 0; len(members) < limit && !complete; {
+		page, err := r.cl.ZRevRangeByScore(ctx, feedKey(userID), max, offset, batch)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, m := range page {
+			if len(members) == limit {
+				break
+			}
+			if m.Member == feedEnd {
+				complete = true
+				break
+			}
+			if isAfterCursor(m, cursor) {
+				members = append(members, m)
+			}
+		}
+
+		if len(page) < batch {
+			break
+		}
+		offset += len(page)
+	}
+
+	// Короткая страница без отметки конца ленты может означать, что остаток ленты обрезан
+	// или не прогрет, поэтому такие страницы читаются из базы
+	if len(members) < limit && !complete {
+		return nil, false, nil
+	}
+
+	keys := make([]string, 0, len(members))
+	for _, m := range members {
+		keys = append(keys, postKey(m.Member))
+	}
+
+	values, err := r.cl.HGetAllMulti(ctx, keys)
+	if err != nil {
+		return nil, false, err
+	}
+
+	items := make([]*feedModel.MaterializedFeed, 0, len(values))
+	for i, v := range values {
+		// Тело поста вытеснено или удалено - страница неполная
+		if len(v) == 0 {
+			return nil, false, nil
+		}
+
+		var post modelRepo.Post
+		if err = redigo.ScanStruct(v, &post); err != nil {
+			return nil, false, err
+		}
+
+		items = append(items, &feedModel.MaterializedFeed{
+			UserID:    userID,
+			PostID:    members[i].Member,
+			AuthorID:  post.AuthorUserID,
+			PostText:  post.Text,
+			CreatedAt: time.UnixMicro(int64(members[i].Score)).UTC(),
+		})
+	}
+
+	return items, true, nil
+}
+
+// isAfterCursor проверяет, что элемент идет в ленте строго после курсора
+func isAfterCursor(m cache.ZMember, cursor *model.Cursor) bool {
+	if cursor == nil {
+		return true
+	}
+
+	score := int64(m.Score)
+	cursorScore := cursor.CreatedAt.UnixMicro()
+
+	return score < cursorScore || (score == cursorScore && m.Member < cursor.ID)
+}
+
+// WarmFeed записывает в кэш ленту пользователя, прочитанную из базы. Лента собирается во временном
+// ключе и одной командой заменяет закэшированную, посты, добавленные раскладкой после чтения
+// из базы, сохраняются. complete означает, что items - вся лента пользователя
+func (r *repository) WarmFeed(ctx context.Context, userID string, items []*feedModel.MaterializedFeed, complete bool) error {
+	members := make([]cache.ZMember, 0, len(items)+1)
+	for _, item := range items {
+		if err := r.setPost(ctx, item); err != nil {
+			return err
+		}
+		members = append(members, toMember(item))
+	}
+	if complete {
+		members = append(members, cache.ZMember{Member: feedEnd, Score: 0})
+	}
+
+	return r.cl.ZMergeStore(ctx, feedKey(userID), warmKey(userID), members, r.maxLength, r.ttl)
+}
+
+// AddToFeeds добавляет пост в ленты пользователей. Если ленты нет в кэше, она не создается,
+// иначе в кэше окажутся только новые посты и чтение вернет неполную ленту
+func (r *repository) AddToFeeds(ctx context.Context, userIDs []string, item *feedModel.MaterializedFeed) error {
+	postStored := false
+	for _, userID := range userIDs {
+		exists, err := r.cl.Exists(ctx, feedKey(userID))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		// Тело поста общее для всех лент и сохраняется один раз
+		if !postStored {
+			if err = r.setPost(ctx, item); err != nil {
+				return err
+			}
+			postStored = true
+		}
+
+		if err = r.addMembers(ctx, userID, toMember(item)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdatePost обновляет текст поста, если его тело есть в кэше
+func (r *repository) UpdatePost(ctx context.Context, postID, postText string) error {
+	_, err := r.cl.HSetIfExists(ctx, postKey(postID), "text", postText)
+	return err
+}
+
+// RemoveFromFeeds удаляет пост из лент пользователей вместе с его телом
+func (r *repository) RemoveFromFeeds(ctx context.Context, userIDs []string, postID string) error {
+	// Без тела пост не попадет в выдачу, даже если в какой-то ленте осталась ссылка на него
+	if err := r.cl.Del(ctx, postKey(postID)); err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := r.cl.ZRem(ctx, feedKey(userID), postID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InvalidateFeed удаляет закэшированную ленту пользователя
+func (r *repository) InvalidateFeed(ctx context.Context, userID string) error {
+	return r.cl.Del(ctx, feedKey(userID))
+}
+
+// setPost сохраняет тело поста
+func (r *repository) setPost(ctx context.Context, item *feedModel.MaterializedFeed) error {
+	post := modelRepo.Post{
+		ID:           item.PostID,
+		AuthorUserID: item.AuthorID,
+		Text:         item.PostText,
+		CreatedAtUs:  item.CreatedAt.UnixMicro(),
+	}
+
+	return r.cl.HSet(ctx, postKey(item.PostID), post, r.ttl)
+}
+
+// addMembers добавляет посты в ленту, обрезает ее до maxLength и продлевает время жизни
+func (r *repository) addMembers(ctx context.Context, userID string, members ...cache.ZMember) error {
+	key := feedKey(userID)
+
+	if err := r.cl.ZAdd(ctx, key, members...); err != nil {
+		return err
+	}
+
+	if r.maxLength > 0 {
+		if err := r.cl.ZRemRangeByRank(ctx, key, 0, -(r.maxLength + 1)); err != nil {
+			return err
+		}
+	}
+
+	return r.cl.Expire(ctx, key, r.ttl)
+}
+
+func toMember(item *feedModel.MaterializedFeed) cache.ZMember {
+	return cache.ZMember{
+		Member: item.PostID,
+		Score:  float64(item.CreatedAt.UnixMicro()),
+	}
+}
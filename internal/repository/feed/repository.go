@@ -2,19 +2,36 @@ package feed
 
 import (
 	"context"
+	"otus-project/internal/model"
 	feedModel "otus-project/internal/repository/feed/model"
+	"time"
 )
 
 // Repository интерфейс для работы с материализованной лентой
 type Repository interface {
-	// AddToFeed добавляет пост в материализованную ленту пользователя
-	AddToFeed(ctx context.Context, userID, postID, authorID, postText string) error
+	// AddToFeeds добавляет ссылку на пост в материализованные ленты пользователей одним запросом.
+	// createdAt задает позицию поста в ленте и должен совпадать с позицией в кэше
+	AddToFeeds(ctx context.Context, userIDs []string, postID, authorID string, createdAt time.Time) error
 
-	// GetFeed получает материализованную ленту пользователя
-	GetFeed(ctx context.Context, userID string, offset, limit int) ([]*feedModel.MaterializedFeed, error)
+	// GetFeed получает материализованную ленту пользователя с текстами постов из posts
+	GetFeed(ctx context.Context, userID string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error)
 
-	// RemoveFromFeed удаляет пост из материализованной ленты пользователя
-	RemoveFromFeed(ctx context.Context, userID, postID string) error
+	// RemovePostFromFeeds удаляет пост из всех материализованных лент
+	RemovePostFromFeeds(ctx context.Context, postID string) error
+
+	// BackfillFromAuthor добавляет в ленту пользователя последние limit постов автора
+	BackfillFromAuthor(ctx context.Context, userID, authorID string, limit int) error
+
+	// RemoveAuthorFromFeed удаляет из ленты пользователя все посты автора
+	RemoveAuthorFromFeed(ctx context.Context, userID, authorID string) error
+
+	// TrimByCount оставляет maxEntries последних записей в лентах batchSize пользователей,
+	// следующих по ID за afterUserID (пустой - с начала). Возвращает ID последнего пройденного
+	// пользователя, пустой, если пользователей не осталось, и количество удаленных записей
+	TrimByCount(ctx context.Context, afterUserID string, maxEntries, batchSize int) (lastUserID string, deleted int64, err error)
+
+	// TrimByAge удаляет до batchSize записей ленты, созданных раньше olderThan
+	TrimByAge(ctx context.Context, olderThan time.Time, batchSize int) (int64, error)
 
 	// CreateJob создает задание на материализацию ленты
 	CreateJob(ctx context.Context, job *feedModel.FeedJob) error
@@ -22,9 +39,67 @@ type Repository interface {
 	// UpdateJobStatus обновляет статус задания
 	UpdateJobStatus(ctx context.Context, jobID, status string, error *string) error
 
-	// GetPendingJobs получает задания со статусом pending
-	GetPendingJobs(ctx context.Context, limit int) ([]*feedModel.FeedJob, error)
+	// StartJob переводит повторно опубликованное задание в processing и увеличивает счетчик попыток.
+	// Возвращает model.ErrorFeedJobNotPending, если задание уже взято или завершено
+	StartJob(ctx context.Context, jobID string) (attempts int, err error)
+
+	// FailJob помечает задание упавшим и назначает время следующей попытки
+	FailJob(ctx context.Context, jobID, errorMsg string, nextRetryAt time.Time) error
+
+	// TouchJob продлевает задание в processing, чтобы reaper не посчитал долгую раскладку зависшей
+	TouchJob(ctx context.Context, jobID string) error
+
+	// AddJobRecipients отмечает получателей, по лентам которых задание уже разложило событие
+	AddJobRecipients(ctx context.Context, jobID string, userIDs []string) error
+
+	// GetJobRecipients возвращает получателей, отмеченных AddJobRecipients
+	GetJobRecipients(ctx context.Context, jobID string) ([]string, error)
+
+	// DeleteJobRecipients удаляет отметки получателей завершенного задания
+	DeleteJobRecipients(ctx context.Context, jobID string) error
+
+	// FailStuckJobs помечает упавшими задания, которые не продвигались с stuckBefore.
+	// Задания pending, время повтора которых еще не подошло, не трогаются
+	FailStuckJobs(ctx context.Context, stuckBefore time.Time) (int64, error)
+
+	// ClaimJobsForRetry переводит в pending упавшие задания, у которых осталось меньше
+	// maxAttempts попыток и подошло время повтора, и возвращает их
+	ClaimJobsForRetry(ctx context.Context, maxAttempts, limit int) ([]*feedModel.FeedJob, error)
 
 	// GetFriendsOfUser получает список друзей пользователя
 	GetFriendsOfUser(ctx context.Context, userID string) ([]string, error)
+
+	// GetFriendsSample получает до limit друзей пользователя, не читая весь список. Друг может повториться
+	GetFriendsSample(ctx context.Context, userID string, limit int) ([]string, error)
+
+	// GetCelebrityFriends получает друзей пользователя, у которых больше threshold друзей
+	GetCelebrityFriends(ctx context.Context, userID string, threshold int) ([]string, error)
+
+	// GetRecentPostsByAuthors получает последние посты авторов в формате записей ленты
+	GetRecentPostsByAuthors(ctx context.Context, authorIDs []string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error)
+}
+
+// CacheRepository интерфейс кэша материализованной ленты.
+// Лента хранится как отсортированное множество идентификаторов постов,
+// тела постов - в отдельных хэшах, общих для всех лент.
+type CacheRepository interface {
+	// GetFeed получает страницу ленты из кэша. ok=false означает промах,
+	// и страницу нужно прочитать из базы
+	GetFeed(ctx context.Context, userID string, cursor *model.Cursor, limit int) (items []*feedModel.MaterializedFeed, ok bool, err error)
+
+	// WarmFeed заменяет закэшированную ленту пользователя переданными записями, сохраняя посты,
+	// добавленные в нее после чтения записей. complete означает, что записи - вся лента пользователя
+	WarmFeed(ctx context.Context, userID string, items []*feedModel.MaterializedFeed, complete bool) error
+
+	// AddToFeeds добавляет пост в ленты пользователей, которые уже есть в кэше
+	AddToFeeds(ctx context.Context, userIDs []string, item *feedModel.MaterializedFeed) error
+
+	// UpdatePost обновляет текст закэшированного поста
+	UpdatePost(ctx context.Context, postID, postText string) error
+
+	// RemoveFromFeeds удаляет пост из лент пользователей
+	RemoveFromFeeds(ctx context.Context, userIDs []string, postID string) error
+
+	// InvalidateFeed удаляет закэшированную ленту пользователя
+	InvalidateFeed(ctx context.Context, userID string) error
 }
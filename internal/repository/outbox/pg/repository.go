@@ -0,0 +1,204 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"otus-project/internal/client/db"
+	"otus-project/internal/model"
+	"otus-project/internal/repository"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+const (
+	tableName = "outbox"
+
+	idColumn           = "id"
+	eventTypeColumn    = "event_type"
+	aggregateIdColumn  = "aggregate_id"
+	payloadColumn      = "payload"
+	createdAtColumn    = "created_at"
+	dispatchedAtColumn = "dispatched_at"
+	attemptsColumn     = "attempts"
+	lastErrorColumn    = "last_error"
+	lockedUntilColumn  = "locked_until"
+)
+
+type repo struct {
+	db db.Client
+}
+
+func NewRepository(db db.Client) repository.OutboxRepository {
+	return &repo{db: db}
+}
+
+// Add сохраняет конверт события в outbox. В транзакции TxManager запрос выполняется в ней же
+func (r *repo) Add(ctx context.Context, aggregateId string, event *model.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal outbox event")
+	}
+
+	builder := sq.Insert(tableName).
+		PlaceholderFormat(sq.Dollar).
+		Columns(idColumn, eventTypeColumn, aggregateIdColumn, payloadColumn, createdAtColumn).
+		Values(event.ID, event.Type, aggregateId, body, event.OccurredAt)
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build insert query")
+	}
+
+	q := db.Query{
+		Name:     "outbox_repository.Add",
+		QueryRaw: query,
+	}
+
+	if _, err = r.db.DB().ExecContext(ctx, q, args...); err != nil {
+		return errors.Wrap(err, "failed to execute insert query")
+	}
+
+	return nil
+}
+
+// ClaimPending закрепляет неотправленные события за relay до lockedUntil одним запросом.
+// FOR UPDATE SKIP LOCKED во вложенном запросе не дает двум relay взять одно событие
+func (r *repo) ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]*model.OutboxMessage, error) {
+	query := `
+		WITH claimed AS (
+			SELECT id
+			FROM outbox
+			WHERE dispatched_at IS NULL AND (locked_until IS NULL OR locked_until < $1)
+			ORDER BY created_at ASC, id ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox o
+		SET locked_until = $3
+		FROM claimed
+		WHERE o.id = claimed.id
+		RETURNING o.id, o.event_type, o.aggregate_id, o.payload, o.created_at, o.attempts
+	`
+
+	q := db.Query{
+		Name:     "outbox_repository.ClaimPending",
+		QueryRaw: query,
+	}
+
+	rows, err := r.db.DB().QueryContext(ctx, q, time.Now(), limit, lockedUntil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute claim query")
+	}
+	defer rows.Close()
+
+	var messages []*model.OutboxMessage
+	for rows.Next() {
+		message := &model.OutboxMessage{}
+		if err = rows.Scan(
+			&message.ID,
+			&message.EventType,
+			&message.AggregateID,
+			&message.Payload,
+			&message.CreatedAt,
+			&message.Attempts,
+		); err != nil {
+			return nil, errors.Wrap(err, "failed to scan outbox message")
+		}
+		messages = append(messages, message)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING не сохраняет порядок вложенного запроса
+	sort.Slice(messages, func(i, j int) bool {
+		if !messages[i].CreatedAt.Equal(messages[j].CreatedAt) {
+			return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+		}
+		return messages[i].ID < messages[j].ID
+	})
+
+	return messages, nil
+}
+
+// MarkDispatched отмечает события отправленными
+func (r *repo) MarkDispatched(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	builder := sq.Update(tableName).
+		PlaceholderFormat(sq.Dollar).
+		Set(dispatchedAtColumn, time.Now()).
+		Where(sq.Eq{idColumn: ids})
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build update query")
+	}
+
+	q := db.Query{
+		Name:     "outbox_repository.MarkDispatched",
+		QueryRaw: query,
+	}
+
+	if _, err = r.db.DB().ExecContext(ctx, q, args...); err != nil {
+		return errors.Wrap(err, "failed to execute update query")
+	}
+
+	return nil
+}
+
+// MarkFailed увеличивает счетчик попыток события, сохраняет ошибку публикации
+// и снимает закрепление, чтобы событие отправилось на следующем проходе
+func (r *repo) MarkFailed(ctx context.Context, id string, errMsg string) error {
+	builder := sq.Update(tableName).
+		PlaceholderFormat(sq.Dollar).
+		Set(attemptsColumn, sq.Expr(attemptsColumn+" + 1")).
+		Set(lastErrorColumn, errMsg).
+		Set(lockedUntilColumn, nil).
+		Where(sq.Eq{idColumn: id})
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build update query")
+	}
+
+	q := db.Query{
+		Name:     "outbox_repository.MarkFailed",
+		QueryRaw: query,
+	}
+
+	if _, err = r.db.DB().ExecContext(ctx, q, args...); err != nil {
+		return errors.Wrap(err, "failed to execute update query")
+	}
+
+	return nil
+}
+
+// DeleteDispatched удаляет пачку отправленных событий старше olderThan
+func (r *repo) DeleteDispatched(ctx context.Context, olderThan time.Time, batchSize int) (int64, error) {
+	query := `
+		DELETE FROM outbox
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE dispatched_at IS NOT NULL AND dispatched_at < $1
+			LIMIT $2
+		)
+	`
+
+	q := db.Query{
+		Name:     "outbox_repository.DeleteDispatched",
+		QueryRaw: query,
+	}
+
+	tag, err := r.db.DB().ExecContext(ctx, q, olderThan, batchSize)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute delete query")
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -67,7 +67,7 @@ func (r *repo) Create(ctx context.Context, post *model.Post) (*string, error) {
 
 func (r *repo) Get(ctx context.Context, offset *float32, limit *float32) (*model.Post, error) {
 	if offset == nil {
-		val := float32(1)
+		val := float32(0)
 		offset = &val
 	}
 	if limit == nil {
@@ -106,28 +106,19 @@ func (r *repo) Get(ctx context.Context, offset *float32, limit *float32) (*model
 	return converter.ToPostFromRepo(&post), nil
 }
 
-// Feed Получить посты друзей
-func (r *repo) Feed(ctx context.Context, id string, offset *float32, limit *float32) ([]*model.Post, error) {
-	if offset == nil {
-		val := float32(1)
-		offset = &val
-	}
-	if limit == nil {
-		val := float32(1000)
-		limit = &val
-	}
-	// Приводим float32 к uint64
-	off := uint64(*offset)
-	lim := uint64(*limit)
-
+// Feed Получить посты друзей. Пагинация по (created_at, id), курсор nil означает первую страницу.
+func (r *repo) Feed(ctx context.Context, id string, cursor *model.Cursor, limit int) ([]*model.Post, error) {
 	builder := sq.Select(idColumn, textColumn, authorUserIdColumn, "posts.created_at", "posts.updated_at").
 		PlaceholderFormat(sq.Dollar).
 		From(tableName).
 		Where(sq.Eq{userIdColumn: id}).
 		Join("friends f ON f.friend_id = posts.author_user_id").
-		OrderBy("posts.created_at DESC").
-		Offset(off).
-		Limit(lim)
+		OrderBy("posts.created_at DESC", "posts.id DESC").
+		Limit(uint64(limit))
+
+	if cursor != nil {
+		builder = builder.Where(sq.Expr("(posts.created_at, posts.id) < (?, ?)", cursor.CreatedAt, cursor.ID))
+	}
 
 	query, args, err := builder.ToSql()
 	if err != nil {
@@ -157,11 +148,6 @@ func (r *repo) Feed(ctx context.Context, id string, offset *float32, limit *floa
 	return posts, nil
 }
 
-// CacheFeed Кэш постов друзей для редиса. Тут как болванка
-func (r *repo) CacheFeed(ctx context.Context, userId string, posts []*model.Post) error {
-	return nil
-}
-
 // GetByID получает пост по ID
 func (r *repo) GetByID(ctx context.Context, id string) (*model.Post, error) {
 	builder := sq.Select(idColumn, textColumn, authorUserIdColumn, createdAtColumn, updatedAtColumn).
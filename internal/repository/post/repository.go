@@ -11,6 +11,5 @@ type PostRepository interface {
 	GetByID(ctx context.Context, id string) (*model.Post, error)
 	Update(ctx context.Context, id string, text string) error
 	Delete(ctx context.Context, id string) error
-	Feed(ctx context.Context, id string, offset *float32, limit *float32) ([]*model.Post, error)
-	CacheFeed(ctx context.Context, userId string, posts []*model.Post) error
+	Feed(ctx context.Context, id string, cursor *model.Cursor, limit int) ([]*model.Post, error)
 }
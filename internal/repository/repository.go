@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"otus-project/internal/model"
+	"time"
 )
 
 type UserRepository interface {
@@ -15,8 +16,7 @@ type UserRepository interface {
 type PostRepository interface {
 	Create(ctx context.Context, info *model.Post) (*string, error)
 	Get(ctx context.Context, offset *float32, limit *float32) (*model.Post, error)
-	Feed(ctx context.Context, id string, offset *float32, limit *float32) ([]*model.Post, error)
-	CacheFeed(ctx context.Context, userId string, posts []*model.Post) error
+	Feed(ctx context.Context, id string, cursor *model.Cursor, limit int) ([]*model.Post, error)
 	GetByID(ctx context.Context, id string) (*model.Post, error)
 	Update(ctx context.Context, id string, text string) error
 	Delete(ctx context.Context, id string) error
@@ -34,8 +34,76 @@ type FriendRepository interface {
 }
 
 type DialogRepository interface {
-	// SendMessage сохраняет сообщение в диалоге
-	SendMessage(ctx context.Context, fromUserId, toUserId, text string) error
+	// SendMessage сохраняет сообщение в диалоге и возвращает его
+	SendMessage(ctx context.Context, fromUserId, toUserId, text string) (*model.DialogMessage, error)
 	// GetDialogList возвращает список сообщений диалога между двумя пользователями
-	GetDialogList(ctx context.Context, userId1, userId2 string) ([]*model.DialogMessage, error)
+	GetDialogList(ctx context.Context, userId1, userId2 string, cursor *model.Cursor, limit int) ([]*model.DialogMessage, error)
+	// MarkRead сдвигает позицию прочтения userId в диалоге с peerId до сообщения messageId.
+	// Возвращает nil, если позиция уже не раньше этого сообщения, и model.ErrorDialogMessageNotFound,
+	// если сообщения нет в диалоге
+	MarkRead(ctx context.Context, userId, peerId, messageId string) (*model.DialogRead, error)
+	// GetReads возвращает позиции прочтения обоих участников диалога, у которых они есть
+	GetReads(ctx context.Context, userId1, userId2 string) ([]*model.DialogRead, error)
+}
+
+type ActivityRepository interface {
+	// TouchLogin запоминает время входа пользователя
+	TouchLogin(ctx context.Context, userId string) error
+
+	// TouchFeedRead запоминает время чтения ленты пользователем
+	TouchFeedRead(ctx context.Context, userId string) error
+
+	// SetOnline отмечает пользователя онлайн на ttl
+	SetOnline(ctx context.Context, userId string, ttl time.Duration) error
+
+	// SetOffline снимает отметку онлайн
+	SetOffline(ctx context.Context, userId string) error
+
+	// GetActivity возвращает сигналы активности пользователя
+	GetActivity(ctx context.Context, userId string) (*model.UserActivity, error)
+
+	// GetActivities возвращает сигналы активности нескольких пользователей в порядке userIds
+	GetActivities(ctx context.Context, userIds []string) ([]*model.UserActivity, error)
+}
+
+type EventLogRepository interface {
+	// Append записывает сообщение в журналы пользователей и возвращает номера, присвоенные ему
+	// в журнале каждого пользователя, в порядке userIds. Номера в журнале пользователя возрастают
+	Append(ctx context.Context, userIds []string, message *model.WebSocketMessage) ([]int64, error)
+
+	// Since возвращает до limit сообщений журнала пользователя с номерами больше afterId по возрастанию.
+	// complete = false, если часть сообщений после afterId уже вытеснена из журнала или их больше limit
+	Since(ctx context.Context, userId string, afterId int64, limit int) (messages []*model.WebSocketMessage, complete bool, err error)
+}
+
+type ConnectionRegistryRepository interface {
+	// Register отмечает на ttl, что у пользователей есть WebSocket соединения на узле nodeId.
+	// Повторный вызов продлевает отметку
+	Register(ctx context.Context, nodeId string, userIds []string, ttl time.Duration) error
+
+	// Unregister снимает отметку узла nodeId для пользователя
+	Unregister(ctx context.Context, nodeId, userId string) error
+
+	// Nodes возвращает узлы, на которых подключен каждый из пользователей.
+	// Пользователей без живых отметок в результате нет
+	Nodes(ctx context.Context, userIds []string) (map[string][]string, error)
+}
+
+type OutboxRepository interface {
+	// Add сохраняет событие в outbox. Вызывается в транзакции изменения данных,
+	// чтобы событие появилось только вместе с ним
+	Add(ctx context.Context, aggregateId string, event *model.Event) error
+
+	// ClaimPending закрепляет за relay до lockedUntil до limit неотправленных событий и возвращает их
+	// в порядке записи. События, закрепленные за другим relay, пропускаются
+	ClaimPending(ctx context.Context, limit int, lockedUntil time.Time) ([]*model.OutboxMessage, error)
+
+	// MarkDispatched отмечает события отправленными
+	MarkDispatched(ctx context.Context, ids []string) error
+
+	// MarkFailed увеличивает счетчик попыток события, сохраняет ошибку публикации и снимает закрепление
+	MarkFailed(ctx context.Context, id string, errMsg string) error
+
+	// DeleteDispatched удаляет до batchSize событий, отправленных раньше olderThan
+	DeleteDispatched(ctx context.Context, olderThan time.Time, batchSize int) (int64, error)
 }
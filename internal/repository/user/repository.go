@@ -158,7 +158,17 @@ func (r *repo) Search(ctx context.Context, filter *model.UserFilter) ([]*model.U
 		builder = builder.Where(sq.Like{secondNameColumn: filter.LastName + "%"})
 	}
 
-	builder = builder.OrderBy(idColumn).Limit(300)
+	// Keyset-пагинация по (created_at, id) в том же порядке, что и ORDER BY
+	if filter.Cursor != nil {
+		builder = builder.Where(sq.Expr("("+createdAtColumn+", "+idColumn+") > (?, ?)", filter.Cursor.CreatedAt, filter.Cursor.ID))
+	}
+
+	limit := uint64(300)
+	if filter.Limit > 0 && uint64(filter.Limit) < limit {
+		limit = uint64(filter.Limit)
+	}
+
+	builder = builder.OrderBy(createdAtColumn, idColumn).Limit(limit)
 
 	query, args, err := builder.ToSql()
 	if err != nil {
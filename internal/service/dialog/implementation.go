@@ -2,24 +2,78 @@ package dialog
 
 import (
 	"context"
+	"otus-project/internal/client/db"
 	"otus-project/internal/model"
 	"otus-project/internal/repository"
+	eventBusService "otus-project/internal/service/event_bus"
 )
 
 type Implementation struct {
-	dialogRepo repository.DialogRepository
+	dialogRepo       repository.DialogRepository
+	outboxRepository repository.OutboxRepository
+	txManager        db.TxManager
 }
 
-func NewImplementation(dialogRepo repository.DialogRepository) *Implementation {
+func NewImplementation(
+	dialogRepo repository.DialogRepository,
+	outboxRepository repository.OutboxRepository,
+	txManager db.TxManager,
+) *Implementation {
 	return &Implementation{
-		dialogRepo: dialogRepo,
+		dialogRepo:       dialogRepo,
+		outboxRepository: outboxRepository,
+		txManager:        txManager,
 	}
 }
 
+// SendMessage сохраняет сообщение и событие о нем в outbox в одной транзакции
 func (i *Implementation) SendMessage(ctx context.Context, fromUserId, toUserId string, text string) error {
-	return i.dialogRepo.SendMessage(ctx, fromUserId, toUserId, text)
+	return i.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		message, err := i.dialogRepo.SendMessage(ctx, fromUserId, toUserId, text)
+		if err != nil {
+			return err
+		}
+
+		return i.outboxRepository.Add(ctx, message.ID, eventBusService.DialogMessageSent.New(&model.DialogMessageSentEvent{
+			MessageID:  message.ID,
+			FromUserID: message.From,
+			ToUserID:   message.To,
+			Text:       message.Text,
+			CreatedAt:  message.CreatedAt,
+		}))
+	})
+}
+
+func (i *Implementation) GetDialogList(ctx context.Context, userId1, userId2 string, cursor *model.Cursor, limit int) ([]*model.DialogMessage, error) {
+	return i.dialogRepo.GetDialogList(ctx, userId1, userId2, cursor, limit)
+}
+
+// MarkRead сдвигает позицию прочтения и пишет событие о ней в outbox в одной транзакции.
+// Если позиция не сдвинулась, событие не создается
+func (i *Implementation) MarkRead(ctx context.Context, userId, peerId, messageId string) (*model.DialogRead, error) {
+	var read *model.DialogRead
+	err := i.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		var err error
+		read, err = i.dialogRepo.MarkRead(ctx, userId, peerId, messageId)
+		if err != nil || read == nil {
+			return err
+		}
+
+		return i.outboxRepository.Add(ctx, userId, eventBusService.DialogMessagesRead.New(&model.DialogMessagesReadEvent{
+			UserID:           read.UserID,
+			PeerID:           read.PeerID,
+			MessageID:        read.MessageID,
+			MessageCreatedAt: read.MessageCreatedAt,
+			ReadAt:           read.ReadAt,
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return read, nil
 }
 
-func (i *Implementation) GetDialogList(ctx context.Context, userId1, userId2 string) ([]*model.DialogMessage, error) {
-	return i.dialogRepo.GetDialogList(ctx, userId1, userId2)
+func (i *Implementation) GetReads(ctx context.Context, userId1, userId2 string) ([]*model.DialogRead, error) {
+	return i.dialogRepo.GetReads(ctx, userId1, userId2)
 }
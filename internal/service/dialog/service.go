@@ -10,5 +10,10 @@ type DialogService interface {
 	// SendMessage отправляет сообщение в диалог
 	SendMessage(ctx context.Context, fromUserId, toUserId string, text string) error
 	// GetDialogList возвращает список сообщений диалога между двумя пользователями
-	GetDialogList(ctx context.Context, userId1, userId2 string) ([]*model.DialogMessage, error)
+	GetDialogList(ctx context.Context, userId1, userId2 string, cursor *model.Cursor, limit int) ([]*model.DialogMessage, error)
+	// MarkRead отмечает сообщения диалога с peerId прочитанными до messageId включительно.
+	// Возвращает nil, если позиция прочтения не сдвинулась
+	MarkRead(ctx context.Context, userId, peerId, messageId string) (*model.DialogRead, error)
+	// GetReads возвращает позиции прочтения участников диалога
+	GetReads(ctx context.Context, userId1, userId2 string) ([]*model.DialogRead, error)
 }
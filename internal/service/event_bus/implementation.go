@@ -2,66 +2,196 @@ package eventBus
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"otus-project/internal/config"
+	"otus-project/internal/metric"
+	"otus-project/internal/model"
 	"sync"
 )
 
 type service struct {
-	handlers map[string][]func(context.Context, interface{}) error
-	mu       sync.RWMutex
-	ctx      context.Context
-	cancel   context.CancelFunc
+	cfg config.EventBusConfig
+
+	mu          sync.RWMutex
+	subscribers map[string][]*subscriber
+	started     bool
+	stopped     bool
+
+	wg sync.WaitGroup
 }
 
-// NewService создает новый Event Bus
-func NewService() EventBus {
-	ctx, cancel := context.WithCancel(context.Background())
+// NewService создает новый Event Bus. Настройки подписчиков по умолчанию берутся из cfg
+func NewService(cfg config.EventBusConfig) EventBus {
 	return &service{
-		handlers: make(map[string][]func(context.Context, interface{}) error),
-		ctx:      ctx,
-		cancel:   cancel,
+		cfg:         cfg,
+		subscribers: make(map[string][]*subscriber),
+	}
+}
+
+// Publish передает событие подписчикам, не дожидаясь обработки. Обработка не зависит
+// от отмены ctx, так как вызывающий обычно завершается раньше обработчиков
+func (s *service) Publish(ctx context.Context, event *model.Event) error {
+	_, err := s.deliver(ctx, event, false)
+	return err
+}
+
+// Dispatch передает событие подписчикам и ждет обработки. Используется потребителем
+// доменных событий из брокера: при ошибке событие возвращается в очередь и доставляется повторно
+func (s *service) Dispatch(ctx context.Context, event *model.Event) error {
+	results, err := s.deliver(ctx, event, true)
+
+	errs := make([]error, 0, len(results)+1)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, done := range results {
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			return errors.Join(append(errs, ctx.Err())...)
+		}
 	}
+
+	return errors.Join(errs...)
 }
 
-// PublishEvent публикует событие
-func (s *service) PublishEvent(ctx context.Context, eventType string, payload interface{}) error {
+// deliver ставит событие в очереди подписчиков. Если wait, возвращает каналы с результатами обработки
+func (s *service) deliver(ctx context.Context, event *model.Event, wait bool) ([]chan error, error) {
 	s.mu.RLock()
-	handlers, exists := s.handlers[eventType]
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
 
-	if !exists {
-		return nil // Нет подписчиков
+	if s.stopped {
+		return nil, ErrStopped
 	}
 
-	// Выполняем обработчики асинхронно
-	for _, handler := range handlers {
-		go func(h func(context.Context, interface{}) error) {
-			if err := h(ctx, payload); err != nil {
-				log.Printf("Error handling event %s: %v", eventType, err)
+	metric.IncEventPublished(event.Type)
+
+	subscribers := s.subscribers[event.Type]
+	results := make([]chan error, 0, len(subscribers))
+	for _, sub := range subscribers {
+		j := &job{ctx: ctx, event: event}
+		if wait {
+			done := make(chan error, 1)
+			j.done = done
+			results = append(results, done)
+		} else {
+			j.ctx = context.WithoutCancel(ctx)
+		}
+
+		select {
+		case sub.jobs <- j:
+		case <-ctx.Done():
+			if wait {
+				results = results[:len(results)-1]
 			}
-		}(handler)
+			return results, ctx.Err()
+		}
 	}
 
-	return nil
+	return results, nil
 }
 
-// Subscribe подписывается на события определенного типа
-func (s *service) Subscribe(eventType string, handler func(context.Context, interface{}) error) error {
+// Subscribe регистрирует подписчика. Подписчики, добавленные после Start, запускаются сразу
+func (s *service) Subscribe(eventType, name string, handler Handler, opts ...SubscriberOption) error {
+	options := subscriberOptions{
+		workers:      s.cfg.Workers(),
+		queueSize:    s.cfg.QueueSize(),
+		maxAttempts:  s.cfg.MaxAttempts(),
+		retryBackoff: s.cfg.RetryBackoff(),
+		timeout:      s.cfg.HandlerTimeout(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.handlers[eventType] = append(s.handlers[eventType], handler)
+	if s.stopped {
+		return ErrStopped
+	}
+
+	for _, sub := range s.subscribers[eventType] {
+		if sub.name == name {
+			return fmt.Errorf("%w: %s on %s", ErrDuplicateSubscriber, name, eventType)
+		}
+	}
+
+	sub := newSubscriber(eventType, name, handler, options)
+	s.subscribers[eventType] = append(s.subscribers[eventType], sub)
+
+	if s.started {
+		s.startWorkers(sub)
+	}
+
 	return nil
 }
 
-// Start запускает обработку событий
-func (s *service) Start(ctx context.Context) error {
-	// Простая реализация - обработка уже происходит в PublishEvent
+// Start запускает пулы обработчиков. События, опубликованные до запуска, ждут в очередях подписчиков
+func (s *service) Start(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return ErrStopped
+	}
+	if s.started {
+		return nil
+	}
+	s.started = true
+
+	for _, subscribers := range s.subscribers {
+		for _, sub := range subscribers {
+			s.startWorkers(sub)
+		}
+	}
+
 	return nil
 }
 
-// Stop останавливает обработку событий
+// startWorkers запускает пул обработчиков подписчика
+func (s *service) startWorkers(sub *subscriber) {
+	for i := 0; i < sub.opts.workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			sub.run()
+		}()
+	}
+}
+
+// Stop перестает принимать события и дожидается, пока обработчики разберут очереди,
+// но не дольше, чем позволяет ctx
 func (s *service) Stop(ctx context.Context) error {
-	s.cancel()
-	return nil
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+
+	for _, subscribers := range s.subscribers {
+		for _, sub := range subscribers {
+			close(sub.jobs)
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
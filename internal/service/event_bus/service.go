@@ -2,19 +2,118 @@ package eventBus
 
 import (
 	"context"
+	"errors"
+	"otus-project/internal/model"
+	"time"
 )
 
+var (
+	// ErrStopped шина остановлена и не принимает события и подписчиков
+	ErrStopped = errors.New("event bus is stopped")
+	// ErrDuplicateSubscriber подписчик с таким именем уже получает события этого типа
+	ErrDuplicateSubscriber = errors.New("duplicate subscriber")
+	// ErrHandlerPanic обработчик события запаниковал
+	ErrHandlerPanic = errors.New("event handler panicked")
+)
+
+// Handler обрабатывает событие
+type Handler func(ctx context.Context, event *model.Event) error
+
 // EventBus интерфейс для системы событий
 type EventBus interface {
-	// PublishEvent публикует событие
-	PublishEvent(ctx context.Context, eventType string, payload interface{}) error
+	// Publish передает событие подписчикам, не дожидаясь обработки.
+	// Блокируется, пока очередь подписчика заполнена
+	Publish(ctx context.Context, event *model.Event) error
+
+	// Dispatch передает событие подписчикам и ждет обработки, возвращает ошибки обработчиков
+	Dispatch(ctx context.Context, event *model.Event) error
 
-	// Subscribe подписывается на события определенного типа
-	Subscribe(eventType string, handler func(context.Context, interface{}) error) error
+	// Subscribe регистрирует подписчика name на события eventType с собственным пулом обработчиков
+	Subscribe(eventType, name string, handler Handler, opts ...SubscriberOption) error
 
-	// Start запускает обработку событий
+	// Start запускает пулы обработчиков
 	Start(ctx context.Context) error
 
-	// Stop останавливает обработку событий
+	// Stop перестает принимать события и дожидается обработки уже принятых
 	Stop(ctx context.Context) error
 }
+
+type subscriberOptions struct {
+	workers      int
+	queueSize    int
+	maxAttempts  int
+	retryBackoff time.Duration
+	timeout      time.Duration
+}
+
+// SubscriberOption переопределяет настройки подписчика из EventBusConfig
+type SubscriberOption func(*subscriberOptions)
+
+// WithWorkers сколько обработчиков подписчика выполняется одновременно
+func WithWorkers(workers int) SubscriberOption {
+	return func(opts *subscriberOptions) {
+		opts.workers = max(workers, 1)
+	}
+}
+
+// WithQueueSize сколько событий ждет свободного обработчика
+func WithQueueSize(size int) SubscriberOption {
+	return func(opts *subscriberOptions) {
+		opts.queueSize = max(size, 0)
+	}
+}
+
+// WithRetry сколько раз вызывать обработчик и задержка перед первым повтором
+func WithRetry(maxAttempts int, backoff time.Duration) SubscriberOption {
+	return func(opts *subscriberOptions) {
+		opts.maxAttempts = max(maxAttempts, 1)
+		opts.retryBackoff = backoff
+	}
+}
+
+// WithTimeout максимальное время одного вызова обработчика, 0 - без ограничения
+func WithTimeout(timeout time.Duration) SubscriberOption {
+	return func(opts *subscriberOptions) {
+		opts.timeout = timeout
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent помечает ошибку обработчика как неисправимую повтором
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable проверяет, может ли повторная доставка события исправить ошибку.
+// Для ошибок Dispatch повтор нужен, если его ждет хотя бы один подписчик
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if IsRetryable(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var permanent *permanentError
+	return !errors.As(err, &permanent) && !errors.Is(err, ErrHandlerPanic)
+}
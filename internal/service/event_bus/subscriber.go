@@ -0,0 +1,127 @@
+package eventBus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"otus-project/internal/metric"
+	"otus-project/internal/model"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	handleStatusOK    = "ok"
+	handleStatusError = "error"
+	handleStatusPanic = "panic"
+
+	// maxRetryBackoff максимальная задержка между повторами обработчика
+	maxRetryBackoff = 5 * time.Second
+)
+
+// job событие в очереди подписчика. Для Dispatch результат обработки возвращается в done
+type job struct {
+	ctx   context.Context
+	event *model.Event
+	done  chan<- error
+}
+
+// subscriber подписчик с собственной очередью и пулом обработчиков
+type subscriber struct {
+	eventType string
+	name      string
+	handler   Handler
+	opts      subscriberOptions
+	jobs      chan *job
+}
+
+func newSubscriber(eventType, name string, handler Handler, opts subscriberOptions) *subscriber {
+	return &subscriber{
+		eventType: eventType,
+		name:      name,
+		handler:   handler,
+		opts:      opts,
+		jobs:      make(chan *job, opts.queueSize),
+	}
+}
+
+// run обрабатывает события, пока очередь не закрыта и не разобрана
+func (sub *subscriber) run() {
+	for j := range sub.jobs {
+		sub.process(j)
+	}
+}
+
+// process обрабатывает событие с повторами и публикует метрики
+func (sub *subscriber) process(j *job) {
+	started := time.Now()
+	err := sub.handle(j.ctx, j.event)
+	metric.EventHandleDurationObserve(sub.eventType, sub.name, time.Since(started).Seconds())
+
+	switch {
+	case err == nil:
+		metric.IncEventHandled(sub.eventType, sub.name, handleStatusOK)
+	case errors.Is(err, ErrHandlerPanic):
+		metric.IncEventHandled(sub.eventType, sub.name, handleStatusPanic)
+	default:
+		metric.IncEventHandled(sub.eventType, sub.name, handleStatusError)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("subscriber %s: %w", sub.name, err)
+	}
+
+	if j.done != nil {
+		j.done <- err
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error handling event %s (ID: %s): %v", j.event.Type, j.event.ID, err)
+	}
+}
+
+// handle вызывает обработчик, пока он не выполнится, не исчерпает попытки или не вернет
+// ошибку, которую повтор не исправит. Задержка между повторами удваивается
+func (sub *subscriber) handle(ctx context.Context, event *model.Event) error {
+	backoff := sub.opts.retryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := sub.invoke(ctx, event)
+		if err == nil || attempt >= sub.opts.maxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		metric.IncEventRetries(sub.eventType, sub.name)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// invoke вызывает обработчик с таймаутом. Паника обработчика превращается в ошибку
+// и не затрагивает остальные события и подписчиков
+func (sub *subscriber) invoke(ctx context.Context, event *model.Event) (err error) {
+	if sub.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sub.opts.timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in %s handler of event %s (ID: %s): %v\n%s", sub.name, event.Type, event.ID, r, debug.Stack())
+			err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+		}
+	}()
+
+	return sub.handler(ctx, event)
+}
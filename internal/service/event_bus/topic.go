@@ -0,0 +1,54 @@
+package eventBus
+
+import (
+	"context"
+	"fmt"
+	"otus-project/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Topic связывает тип события с типом его данных и текущей версией схемы
+type Topic[T any] struct {
+	Type    string
+	Version int
+}
+
+var (
+	PostCreated        = Topic[*model.PostCreatedEvent]{Type: model.EventTypePostCreated, Version: 1}
+	PostUpdated        = Topic[*model.PostUpdatedEvent]{Type: model.EventTypePostUpdated, Version: 1}
+	PostDeleted        = Topic[*model.PostDeletedEvent]{Type: model.EventTypePostDeleted, Version: 1}
+	FriendAdded        = Topic[*model.FriendAddedEvent]{Type: model.EventTypeFriendAdded, Version: 1}
+	FriendRemoved      = Topic[*model.FriendRemovedEvent]{Type: model.EventTypeFriendRemoved, Version: 1}
+	DialogMessageSent  = Topic[*model.DialogMessageSentEvent]{Type: model.EventTypeDialogMessageSent, Version: 1}
+	DialogMessagesRead = Topic[*model.DialogMessagesReadEvent]{Type: model.EventTypeDialogMessagesRead, Version: 1}
+)
+
+// New создает конверт события с новым идентификатором
+func (t Topic[T]) New(payload T) *model.Event {
+	return &model.Event{
+		ID:         uuid.New().String(),
+		Type:       t.Type,
+		Version:    t.Version,
+		OccurredAt: time.Now(),
+		Payload:    payload,
+	}
+}
+
+// Subscribe подписывает типизированный обработчик на события topic.
+// Событие с данными другого типа не передается обработчику и не повторяется
+func Subscribe[T any](bus EventBus, topic Topic[T], name string, handler func(context.Context, *model.Event, T) error, opts ...SubscriberOption) error {
+	return bus.Subscribe(topic.Type, name, func(ctx context.Context, event *model.Event) error {
+		payload, ok := event.Payload.(T)
+		if !ok {
+			return Permanent(fmt.Errorf("event %s: unexpected payload %T", event.Type, event.Payload))
+		}
+		return handler(ctx, event, payload)
+	}, opts...)
+}
+
+// Publish создает событие topic и передает его подписчикам, не дожидаясь обработки
+func Publish[T any](ctx context.Context, bus EventBus, topic Topic[T], payload T) error {
+	return bus.Publish(ctx, topic.New(payload))
+}
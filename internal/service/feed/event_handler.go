@@ -18,11 +18,26 @@ func NewEventHandler(feedService Service) *EventHandler {
 }
 
 // HandlePostCreated обрабатывает событие создания поста
-func (h *EventHandler) HandlePostCreated(ctx context.Context, payload interface{}) error {
-	event, ok := payload.(*model.PostCreatedEvent)
-	if !ok {
-		return nil // Игнорируем неправильный тип события
-	}
+func (h *EventHandler) HandlePostCreated(ctx context.Context, _ *model.Event, event *model.PostCreatedEvent) error {
+	return h.feedService.ScheduleFeedUpdate(ctx, event.PostID, event.AuthorUserID, event.PostText, event.CreatedAt)
+}
+
+// HandlePostUpdated обрабатывает событие изменения поста
+func (h *EventHandler) HandlePostUpdated(ctx context.Context, _ *model.Event, event *model.PostUpdatedEvent) error {
+	return h.feedService.ScheduleFeedPostUpdate(ctx, event.PostID, event.AuthorUserID, event.PostText)
+}
+
+// HandlePostDeleted обрабатывает событие удаления поста
+func (h *EventHandler) HandlePostDeleted(ctx context.Context, _ *model.Event, event *model.PostDeletedEvent) error {
+	return h.feedService.ScheduleFeedPostRemoval(ctx, event.PostID, event.AuthorUserID)
+}
+
+// HandleFriendAdded обрабатывает событие добавления друга
+func (h *EventHandler) HandleFriendAdded(ctx context.Context, _ *model.Event, event *model.FriendAddedEvent) error {
+	return h.feedService.ScheduleFriendBackfill(ctx, event.UserID, event.FriendID)
+}
 
-	return h.feedService.ScheduleFeedUpdate(ctx, event.PostID, event.AuthorUserID, event.PostText)
+// HandleFriendRemoved обрабатывает событие удаления друга
+func (h *EventHandler) HandleFriendRemoved(ctx context.Context, _ *model.Event, event *model.FriendRemovedEvent) error {
+	return h.feedService.ScheduleFriendPurge(ctx, event.UserID, event.FriendID)
 }
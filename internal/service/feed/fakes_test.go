@@ -0,0 +1,350 @@
+package feed
+
+import (
+	"context"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/config"
+	"otus-project/internal/model"
+	"otus-project/internal/repository"
+	"otus-project/internal/repository/feed"
+	feedModel "otus-project/internal/repository/feed/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFeedRepository хранит ленты и задания в памяти и считает запросы к базе.
+// Методы, которые тесты не используют, не реализованы и паникуют через встроенный nil интерфейс
+type fakeFeedRepository struct {
+	feed.Repository
+
+	mu            sync.Mutex
+	friends       map[string][]string
+	feeds         map[string]map[string]struct{}
+	jobs          map[string]*feedModel.FeedJob
+	jobRecipients map[string]map[string]struct{}
+	queries       int
+	rows          int
+
+	// bench не хранит ленты и завершенные задания, чтобы память бенчмарка не росла с b.N
+	bench bool
+
+	// failAddToFeeds, если задан, вызывается перед записью пачки и может вернуть ошибку
+	failAddToFeeds func(userIDs []string) error
+}
+
+func newFakeFeedRepository(friends map[string][]string) *fakeFeedRepository {
+	return &fakeFeedRepository{
+		friends:       friends,
+		feeds:         make(map[string]map[string]struct{}),
+		jobs:          make(map[string]*feedModel.FeedJob),
+		jobRecipients: make(map[string]map[string]struct{}),
+	}
+}
+
+func (r *fakeFeedRepository) AddToFeeds(_ context.Context, userIDs []string, postID, _ string, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	if r.failAddToFeeds != nil {
+		if err := r.failAddToFeeds(userIDs); err != nil {
+			return err
+		}
+	}
+
+	r.rows += len(userIDs)
+	if r.bench {
+		return nil
+	}
+
+	for _, userID := range userIDs {
+		if r.feeds[userID] == nil {
+			r.feeds[userID] = make(map[string]struct{})
+		}
+		r.feeds[userID][postID] = struct{}{}
+	}
+
+	return nil
+}
+
+func (r *fakeFeedRepository) CreateJob(_ context.Context, job *feedModel.FeedJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	stored := *job
+	r.jobs[job.ID] = &stored
+	return nil
+}
+
+func (r *fakeFeedRepository) UpdateJobStatus(_ context.Context, jobID, status string, _ *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	if r.bench && status == feedModel.FeedJobStatusCompleted {
+		delete(r.jobs, jobID)
+		return nil
+	}
+	r.jobs[jobID].Status = status
+	return nil
+}
+
+func (r *fakeFeedRepository) StartJob(_ context.Context, jobID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	job, ok := r.jobs[jobID]
+	if !ok || job.Status != feedModel.FeedJobStatusPending {
+		return 0, model.ErrorFeedJobNotPending
+	}
+	job.Status = feedModel.FeedJobStatusProcessing
+	job.Attempts++
+	return job.Attempts, nil
+}
+
+func (r *fakeFeedRepository) FailJob(_ context.Context, jobID, errorMsg string, nextRetryAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	job := r.jobs[jobID]
+	job.Status = feedModel.FeedJobStatusFailed
+	job.Error = &errorMsg
+	job.NextRetryAt = &nextRetryAt
+	return nil
+}
+
+func (r *fakeFeedRepository) TouchJob(context.Context, string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	return nil
+}
+
+func (r *fakeFeedRepository) AddJobRecipients(_ context.Context, jobID string, userIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	if r.jobRecipients[jobID] == nil {
+		r.jobRecipients[jobID] = make(map[string]struct{})
+	}
+	for _, userID := range userIDs {
+		r.jobRecipients[jobID][userID] = struct{}{}
+	}
+	return nil
+}
+
+func (r *fakeFeedRepository) GetJobRecipients(_ context.Context, jobID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	userIDs := make([]string, 0, len(r.jobRecipients[jobID]))
+	for userID := range r.jobRecipients[jobID] {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, nil
+}
+
+func (r *fakeFeedRepository) DeleteJobRecipients(_ context.Context, jobID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	delete(r.jobRecipients, jobID)
+	return nil
+}
+
+func (r *fakeFeedRepository) GetFriendsOfUser(_ context.Context, userID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	return append([]string(nil), r.friends[userID]...), nil
+}
+
+func (r *fakeFeedRepository) TrimByCount(context.Context, string, int, int) (string, int64, error) {
+	return "", 0, nil
+}
+
+func (r *fakeFeedRepository) TrimByAge(context.Context, time.Time, int) (int64, error) {
+	return 0, nil
+}
+
+// jobStatuses возвращает статусы всех заданий
+func (r *fakeFeedRepository) jobStatuses() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]string, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		statuses = append(statuses, job.Status)
+	}
+	return statuses
+}
+
+// hasPost проверяет, есть ли пост в ленте пользователя
+func (r *fakeFeedRepository) hasPost(userID, postID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.feeds[userID][postID]
+	return ok
+}
+
+func (r *fakeFeedRepository) GetFriendsSample(_ context.Context, userID string, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+
+	friends := r.friends[userID]
+	return append([]string(nil), friends[:min(limit, len(friends))]...), nil
+}
+
+// resetCounters обнуляет счетчики запросов и записанных строк
+func (r *fakeFeedRepository) resetCounters() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries, r.rows = 0, 0
+}
+
+// fakeFeedCache кэш лент, который ничего не хранит
+type fakeFeedCache struct {
+	feed.CacheRepository
+}
+
+func (fakeFeedCache) AddToFeeds(context.Context, []string, *feedModel.MaterializedFeed) error {
+	return nil
+}
+
+func (fakeFeedCache) UpdatePost(context.Context, string, string) error {
+	return nil
+}
+
+func (fakeFeedCache) RemoveFromFeeds(context.Context, []string, string) error {
+	return nil
+}
+
+func (fakeFeedCache) InvalidateFeed(context.Context, string) error {
+	return nil
+}
+
+// fakeActivityRepository возвращает заданную активность, остальные пользователи давно не заходили
+type fakeActivityRepository struct {
+	repository.ActivityRepository
+	activities map[string]*model.UserActivity
+}
+
+func (r *fakeActivityRepository) GetActivities(_ context.Context, userIDs []string) ([]*model.UserActivity, error) {
+	activities := make([]*model.UserActivity, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if activity, ok := r.activities[userID]; ok {
+			activities = append(activities, activity)
+			continue
+		}
+		activities = append(activities, &model.UserActivity{UserID: userID})
+	}
+	return activities, nil
+}
+
+// fakeEventLog считает записи в журналы пользователей
+type fakeEventLog struct {
+	repository.EventLogRepository
+
+	mu      sync.Mutex
+	appends map[string]int
+}
+
+// count возвращает количество записей в журнале пользователя
+func (l *fakeEventLog) count(userID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.appends[userID]
+}
+
+func (l *fakeEventLog) Append(_ context.Context, userIDs []string, _ *model.WebSocketMessage) ([]int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.appends == nil {
+		l.appends = make(map[string]int)
+	}
+	eventIDs := make([]int64, len(userIDs))
+	for i, userID := range userIDs {
+		l.appends[userID]++
+		eventIDs[i] = int64(l.appends[userID])
+	}
+	return eventIDs, nil
+}
+
+// fakeConnectionRegistry реестр с заданными узлами пользователей, остальные пользователи не подключены
+type fakeConnectionRegistry struct {
+	repository.ConnectionRegistryRepository
+	nodes map[string][]string
+}
+
+func (r fakeConnectionRegistry) Nodes(_ context.Context, userIDs []string) (map[string][]string, error) {
+	nodes := make(map[string][]string)
+	for _, userID := range userIDs {
+		if userNodes, ok := r.nodes[userID]; ok {
+			nodes[userID] = userNodes
+		}
+	}
+	return nodes, nil
+}
+
+// fakeQueueClient запоминает опубликованные задачи материализации
+type fakeQueueClient struct {
+	queue.Client
+
+	mu    sync.Mutex
+	tasks []*model.FeedUpdateTask
+}
+
+func (c *fakeQueueClient) PublishFeedUpdateTask(_ context.Context, task *model.FeedUpdateTask) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tasks = append(c.tasks, task)
+	return nil
+}
+
+// testFeedConfig настройки ленты по умолчанию с заданным размером пачки
+type testFeedConfig struct {
+	config.FeedConfig
+	chunkSize int
+}
+
+func (c testFeedConfig) FanOutChunkSize() int {
+	return c.chunkSize
+}
+
+// newTestService собирает сервис ленты на фейковых зависимостях
+func newTestService(tb testing.TB, repo *fakeFeedRepository, activities map[string]*model.UserActivity, chunkSize int) (*service, *fakeEventLog, *fakeQueueClient) {
+	tb.Helper()
+
+	feedConfig, err := config.NewFeedConfig()
+	if err != nil {
+		tb.Fatalf("failed to load feed config: %v", err)
+	}
+
+	eventLog := &fakeEventLog{}
+	queueClient := &fakeQueueClient{}
+	s := NewService(
+		repo,
+		fakeFeedCache{},
+		&fakeActivityRepository{activities: activities},
+		eventLog,
+		fakeConnectionRegistry{},
+		queueClient,
+		testFeedConfig{FeedConfig: feedConfig, chunkSize: chunkSize},
+	).(*service)
+
+	return s, eventLog, queueClient
+}
@@ -0,0 +1,240 @@
+package feed
+
+import (
+	"context"
+	"log"
+	"otus-project/internal/model"
+	feedModel "otus-project/internal/repository/feed/model"
+	"sort"
+	"time"
+)
+
+// fanOutPostEvent раскладывает событие поста по лентам получателей пачками по FanOutChunkSize
+// и после записи каждой пачки уведомляет ее получателей через WebSocket.
+// Пачки идут в порядке активности получателей, поэтому пользователи онлайн видят пост первыми.
+// Обработанные пачки отмечаются в задании, и повтор после ошибки пропускает их получателей,
+// чтобы не дублировать уведомления и записи журнала событий
+func (s *service) fanOutPostEvent(ctx context.Context, task *model.FeedUpdateTask, attempts int) (int, error) {
+	event := task.Event
+
+	recipients, err := s.resolveRecipients(ctx, task)
+	if err != nil {
+		return 0, err
+	}
+
+	// Защита от "эффекта Леди Гаги": новые посты популярных авторов не раскладываются по лентам,
+	// а подмешиваются при чтении в GetMaterializedFeed. Изменение и удаление раскладываются всегда,
+	// чтобы не оставить устаревшие записи, созданные до превышения порога.
+	// Задачи прежнего формата адресованы одному пользователю и публиковались только для раскладки
+	materialize := task.UserID != "" || event.EventType != model.FeedEventTypePostCreated || !s.isCelebrity(len(recipients))
+	if !materialize {
+		log.Printf("User %s has %d friends (threshold %d), skipping fan-out on write",
+			event.AuthorUserID, len(recipients), s.feedConfig.CelebrityThreshold())
+	}
+
+	if materialize {
+		switch event.EventType {
+		case model.FeedEventTypePostUpdated:
+			// В базе лента хранит только ссылку на пост, а текст поста в кэше общий для всех лент
+			s.checkCacheError(ctx, recipients, s.feedCache.UpdatePost(ctx, task.PostID, event.PostText))
+		case model.FeedEventTypePostDeleted:
+			// Удаление по посту одним запросом, кэш лент очищается по пачкам ниже
+			if err := s.feedRepository.RemovePostFromFeeds(ctx, task.PostID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if attempts > 1 && task.IsFanOut() {
+		if recipients, err = s.skipDoneRecipients(ctx, task.JobID, recipients); err != nil {
+			return 0, err
+		}
+	}
+
+	recipients = s.orderByPriority(ctx, recipients)
+
+	// Время события одинаково для всех получателей и при повторной доставке, поэтому
+	// позиция поста в базе и в кэше совпадает. В базе время хранится с точностью до микросекунд
+	createdAt := event.CreatedAt.Truncate(time.Microsecond)
+	if createdAt.IsZero() {
+		createdAt = time.Now().Truncate(time.Microsecond)
+	}
+
+	chunkSize := s.feedConfig.FanOutChunkSize()
+	for start := 0; start < len(recipients); start += chunkSize {
+		chunk := recipients[start:min(start+chunkSize, len(recipients))]
+
+		if materialize {
+			if err := s.applyChunk(ctx, task, chunk, createdAt); err != nil {
+				return 0, err
+			}
+		}
+
+		// Для задач прежнего формата уведомление уже отправлено при публикации
+		if task.IsFanOut() {
+			s.notifyRecipients(ctx, chunk, event)
+
+			// Без отметки повтор только заново уведомит пачку, поэтому ошибка не прерывает раскладку
+			if err := s.feedRepository.AddJobRecipients(ctx, task.JobID, chunk); err != nil {
+				log.Printf("Error saving progress of feed job %s: %v", task.JobID, err)
+			}
+		}
+
+		// Долгая раскладка не должна считаться зависшей, пока пачки обрабатываются
+		if err := s.feedRepository.TouchJob(ctx, task.JobID); err != nil {
+			log.Printf("Error touching feed job %s: %v", task.JobID, err)
+		}
+	}
+
+	return len(recipients), nil
+}
+
+// resolveRecipients возвращает получателей события: адресата задачи прежнего формата
+// или друзей автора поста без самого автора
+func (s *service) resolveRecipients(ctx context.Context, task *model.FeedUpdateTask) ([]string, error) {
+	if !task.IsFanOut() {
+		return []string{task.UserID}, nil
+	}
+
+	authorID := task.Event.AuthorUserID
+	friends, err := s.feedRepository.GetFriendsOfUser(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]string, 0, len(friends))
+	for _, friendID := range friends {
+		// Автор не должен видеть свой пост в ленте друзей
+		if friendID == "" || friendID == authorID {
+			continue
+		}
+		recipients = append(recipients, friendID)
+	}
+
+	return recipients, nil
+}
+
+// skipDoneRecipients убирает получателей, обработанных предыдущими попытками задания
+func (s *service) skipDoneRecipients(ctx context.Context, jobID string, recipients []string) ([]string, error) {
+	done, err := s.feedRepository.GetJobRecipients(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(done) == 0 {
+		return recipients, nil
+	}
+
+	skip := make(map[string]struct{}, len(done))
+	for _, userID := range done {
+		skip[userID] = struct{}{}
+	}
+
+	pending := make([]string, 0, len(recipients))
+	for _, userID := range recipients {
+		if _, ok := skip[userID]; !ok {
+			pending = append(pending, userID)
+		}
+	}
+
+	return pending, nil
+}
+
+// orderByPriority упорядочивает получателей по активности. Если активность прочитать
+// не удалось, порядок остается прежним: раскладка важнее порядка
+func (s *service) orderByPriority(ctx context.Context, userIDs []string) []string {
+	if len(userIDs) < 2 {
+		return userIDs
+	}
+
+	activities, err := s.activityRepository.GetActivities(ctx, userIDs)
+	if err != nil {
+		log.Printf("Error getting activity of %d users: %v", len(userIDs), err)
+		return userIDs
+	}
+
+	priorities := make(map[string]int, len(activities))
+	for _, activity := range activities {
+		priorities[activity.UserID] = activityPriority(activity)
+	}
+
+	sort.SliceStable(userIDs, func(i, j int) bool {
+		return priorities[userIDs[i]] < priorities[userIDs[j]]
+	})
+
+	return userIDs
+}
+
+// applyChunk записывает событие в ленты пачки получателей: сначала базу, затем кэш
+func (s *service) applyChunk(ctx context.Context, task *model.FeedUpdateTask, userIDs []string, createdAt time.Time) error {
+	switch task.Event.EventType {
+	case model.FeedEventTypePostCreated:
+		if err := s.feedRepository.AddToFeeds(ctx, userIDs, task.PostID, task.Event.AuthorUserID, createdAt); err != nil {
+			return err
+		}
+		s.checkCacheError(ctx, userIDs, s.feedCache.AddToFeeds(ctx, userIDs, &feedModel.MaterializedFeed{
+			PostID:    task.PostID,
+			AuthorID:  task.Event.AuthorUserID,
+			PostText:  task.Event.PostText,
+			CreatedAt: createdAt,
+		}))
+	case model.FeedEventTypePostDeleted:
+		s.checkCacheError(ctx, userIDs, s.feedCache.RemoveFromFeeds(ctx, userIDs, task.PostID))
+	}
+
+	return nil
+}
+
+// notifyRecipients отправляет событие ленты получателям через WebSocket. Событие сначала записывается
+// в журналы получателей, чтобы переподключившийся клиент мог его получить, и публикуется с номером
+// из журнала только для получателей, подключенных к какому-либо узлу. Узел создает привязку до отметки
+// в реестре и читает журнал после нее, поэтому событие, не опубликованное для только что подключившегося
+// получателя, он получит из журнала. Сообщения пачки публикуются подряд, а подтверждения брокера
+// ожидаются разом для всей пачки
+func (s *service) notifyRecipients(ctx context.Context, userIDs []string, event *model.FeedEvent) {
+	// Без журнала событие все равно доставляется подключенным клиентам, но без номера
+	eventIDs, err := s.eventLog.Append(ctx, userIDs, model.NewFeedWebSocketMessage(event))
+	if err != nil {
+		log.Printf("Error writing feed event to event log: %v", err)
+		eventIDs = nil
+	}
+
+	userIDs, eventIDs = s.connectedRecipients(ctx, userIDs, eventIDs)
+	if len(userIDs) == 0 {
+		return
+	}
+
+	for i, err := range s.queueClient.PublishFeedEvents(ctx, userIDs, event, eventIDs) {
+		if err != nil {
+			log.Printf("Error publishing feed event for user %s: %v", userIDs[i], err)
+		}
+	}
+}
+
+// connectedRecipients оставляет получателей, у которых есть WebSocket соединения, вместе с их
+// номерами событий. Если реестр недоступен, событие публикуется для всех получателей:
+// брокер отбросит сообщения тех, кого нет ни на одном узле
+func (s *service) connectedRecipients(ctx context.Context, userIDs []string, eventIDs []int64) ([]string, []int64) {
+	nodes, err := s.connectionRegistry.Nodes(ctx, userIDs)
+	if err != nil {
+		log.Printf("Error reading connection registry for %d users: %v", len(userIDs), err)
+		return userIDs, eventIDs
+	}
+
+	connected := make([]string, 0, len(nodes))
+	var connectedEventIDs []int64
+	if eventIDs != nil {
+		connectedEventIDs = make([]int64, 0, len(nodes))
+	}
+
+	for i, userID := range userIDs {
+		if len(nodes[userID]) == 0 {
+			continue
+		}
+		connected = append(connected, userID)
+		if eventIDs != nil {
+			connectedEventIDs = append(connectedEventIDs, eventIDs[i])
+		}
+	}
+
+	return connected, connectedEventIDs
+}
@@ -0,0 +1,196 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"otus-project/internal/model"
+	feedModel "otus-project/internal/repository/feed/model"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testAuthorID = "author"
+
+func newPostCreatedTask(userID string) *model.FeedUpdateTask {
+	postID := uuid.New().String()
+	return &model.FeedUpdateTask{
+		UserID: userID,
+		PostID: postID,
+		Event: &model.FeedEvent{
+			PostID:       postID,
+			AuthorUserID: testAuthorID,
+			PostText:     "text",
+			CreatedAt:    time.Now(),
+			EventType:    model.FeedEventTypePostCreated,
+		},
+		Priority:  model.FeedTaskPriorityNormal,
+		CreatedAt: time.Now(),
+	}
+}
+
+func testRecipients(count int) []string {
+	recipients := make([]string, count)
+	for i := range recipients {
+		recipients[i] = fmt.Sprintf("user-%d", i)
+	}
+	return recipients
+}
+
+func TestFanOutRetrySkipsNotifiedRecipients(t *testing.T) {
+	ctx := context.Background()
+	recipients := testRecipients(5)
+	repo := newFakeFeedRepository(map[string][]string{testAuthorID: recipients})
+	s, eventLog, _ := newTestService(t, repo, nil, 2)
+
+	// Пачка с user-2 падает на первой попытке, предыдущая пачка к этому времени уже уведомлена
+	failed := false
+	repo.failAddToFeeds = func(userIDs []string) error {
+		if !failed && slices.Contains(userIDs, "user-2") {
+			failed = true
+			return errors.New("db unavailable")
+		}
+		return nil
+	}
+
+	task := newPostCreatedTask("")
+	if err := s.ProcessFeedUpdateTask(ctx, task); err != nil {
+		t.Fatalf("first attempt: %v", err)
+	}
+	if status := repo.jobs[task.JobID].Status; status != feedModel.FeedJobStatusFailed {
+		t.Fatalf("job status after failed chunk = %q, want %q", status, feedModel.FeedJobStatusFailed)
+	}
+
+	// Reaper возвращает задание в pending и публикует исходную задачу с JobID
+	repo.jobs[task.JobID].Status = feedModel.FeedJobStatusPending
+	if err := s.ProcessFeedUpdateTask(ctx, task); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	if status := repo.jobs[task.JobID].Status; status != feedModel.FeedJobStatusCompleted {
+		t.Fatalf("job status after retry = %q, want %q", status, feedModel.FeedJobStatusCompleted)
+	}
+	for _, userID := range recipients {
+		if _, ok := repo.feeds[userID][task.PostID]; !ok {
+			t.Errorf("post is missing in feed of %s", userID)
+		}
+		if n := eventLog.appends[userID]; n != 1 {
+			t.Errorf("%s notified %d times, want 1", userID, n)
+		}
+	}
+	if _, ok := repo.jobRecipients[task.JobID]; ok {
+		t.Error("job progress is not deleted after completion")
+	}
+}
+
+func TestScheduleFeedUpdatePriority(t *testing.T) {
+	tests := []struct {
+		name       string
+		friends    []string
+		activities map[string]*model.UserActivity
+		want       int
+	}{
+		{
+			name:    "online friend",
+			friends: []string{"dormant", "online"},
+			activities: map[string]*model.UserActivity{
+				"online": {UserID: "online", Online: true},
+			},
+			want: model.FeedTaskPriorityOnline,
+		},
+		{
+			name:    "recently active friend",
+			friends: []string{"dormant", "active"},
+			activities: map[string]*model.UserActivity{
+				"active": {UserID: "active", LastLoginAt: time.Now().Add(-time.Minute)},
+			},
+			want: model.FeedTaskPriorityActive,
+		},
+		{
+			name:    "dormant friends",
+			friends: []string{"a", "b"},
+			want:    model.FeedTaskPriorityDormant,
+		},
+		{
+			name: "no friends",
+			want: model.FeedTaskPriorityNormal,
+		},
+		{
+			// Учитывается только ограниченная выборка друзей
+			name:    "online friend outside sample",
+			friends: append(testRecipients(fanOutPrioritySample), "online"),
+			activities: map[string]*model.UserActivity{
+				"online": {UserID: "online", Online: true},
+			},
+			want: model.FeedTaskPriorityDormant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeFeedRepository(map[string][]string{testAuthorID: tt.friends})
+			s, _, queueClient := newTestService(t, repo, tt.activities, 500)
+
+			if err := s.ScheduleFeedUpdate(context.Background(), uuid.New().String(), testAuthorID, "text", time.Now()); err != nil {
+				t.Fatalf("ScheduleFeedUpdate: %v", err)
+			}
+			if len(queueClient.tasks) != 1 {
+				t.Fatalf("published %d tasks, want 1", len(queueClient.tasks))
+			}
+			if got := queueClient.tasks[0].Priority; got != tt.want {
+				t.Errorf("priority = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkFanOut сравнивает раскладку поста задачей на каждого получателя с раскладкой
+// одной задачей пачками. Кроме времени выводятся запросы к базе и записанные строки на пост
+func BenchmarkFanOut(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	for _, count := range []int{100, 1000} {
+		recipients := testRecipients(count)
+		repo := newFakeFeedRepository(map[string][]string{testAuthorID: recipients})
+		repo.bench = true
+		s, _, _ := newTestService(b, repo, nil, 500)
+
+		b.Run(fmt.Sprintf("per-recipient/%d", count), func(b *testing.B) {
+			repo.resetCounters()
+			for i := 0; i < b.N; i++ {
+				postTask := newPostCreatedTask("")
+				for _, userID := range recipients {
+					task := *postTask
+					task.UserID = userID
+					if err := s.ProcessFeedUpdateTask(ctx, &task); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+			reportRepositoryMetrics(b, repo)
+		})
+
+		b.Run(fmt.Sprintf("batched/%d", count), func(b *testing.B) {
+			repo.resetCounters()
+			for i := 0; i < b.N; i++ {
+				if err := s.ProcessFeedUpdateTask(ctx, newPostCreatedTask("")); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportRepositoryMetrics(b, repo)
+		})
+	}
+}
+
+func reportRepositoryMetrics(b *testing.B, repo *fakeFeedRepository) {
+	b.ReportMetric(float64(repo.queries)/float64(b.N), "queries/op")
+	b.ReportMetric(float64(repo.rows)/float64(b.N), "rows/op")
+}
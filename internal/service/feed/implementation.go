@@ -2,155 +2,232 @@ package feed
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
-	"math/rand"
 	"otus-project/internal/client/queue"
+	"otus-project/internal/config"
+	"otus-project/internal/metric"
 	"otus-project/internal/model"
+	"otus-project/internal/repository"
 	"otus-project/internal/repository/feed"
 	feedModel "otus-project/internal/repository/feed/model"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const (
-	// MaxFriendsPerPost максимальное количество друзей для обработки одного поста
-	// Защита от "эффекта Леди Гаги" - популярные пользователи не должны перегружать систему
-	MaxFriendsPerPost = 100
+	// BackfillPostsPerFriend количество последних постов нового друга, которые попадают в ленту
+	BackfillPostsPerFriend = 20
+
+	// fanOutPrioritySample сколько друзей автора учитывается при выборе приоритета задачи раскладки
+	fanOutPrioritySample = 100
 )
 
 type service struct {
-	feedRepository feed.Repository
-	queueClient    queue.Client
-	workerCtx      context.Context
-	workerCancel   context.CancelFunc
+	feedRepository     feed.Repository
+	feedCache          feed.CacheRepository
+	activityRepository repository.ActivityRepository
+	eventLog           repository.EventLogRepository
+	connectionRegistry repository.ConnectionRegistryRepository
+	queueClient        queue.Client
+	feedConfig         config.FeedConfig
+	workerCtx          context.Context
+	workerCancel       context.CancelFunc
+	workerConsumer     queue.Consumer
+	busyWorkers        atomic.Int64
 }
 
 // NewService создает новый сервис отложенной материализации ленты
-func NewService(feedRepository feed.Repository, queueClient queue.Client) Service {
+func NewService(
+	feedRepository feed.Repository,
+	feedCache feed.CacheRepository,
+	activityRepository repository.ActivityRepository,
+	eventLog repository.EventLogRepository,
+	connectionRegistry repository.ConnectionRegistryRepository,
+	queueClient queue.Client,
+	feedConfig config.FeedConfig,
+) Service {
 	return &service{
-		feedRepository: feedRepository,
-		queueClient:    queueClient,
+		feedRepository:     feedRepository,
+		feedCache:          feedCache,
+		activityRepository: activityRepository,
+		eventLog:           eventLog,
+		connectionRegistry: connectionRegistry,
+		queueClient:        queueClient,
+		feedConfig:         feedConfig,
 	}
 }
 
 // ScheduleFeedUpdate планирует обновление ленты для друзей автора поста
-func (s *service) ScheduleFeedUpdate(ctx context.Context, postID, authorID, postText string) error {
-	log.Printf("DEBUG: ScheduleFeedUpdate called - postID: %s, authorID: %s", postID, authorID)
-
-	// Получаем список друзей автора поста
-	friends, err := s.feedRepository.GetFriendsOfUser(ctx, authorID)
-	if err != nil {
-		return err
-	}
-
-	log.Printf("DEBUG: Got %d friends for user %s", len(friends), authorID)
-
-	// Защита от "эффекта Леди Гаги" - ограничиваем количество друзей для обработки
-	friendsToProcess := s.limitFriendsForProcessing(friends, MaxFriendsPerPost)
-
-	log.Printf("DEBUG: Processing %d friends (limited from %d)", len(friendsToProcess), len(friends))
+func (s *service) ScheduleFeedUpdate(ctx context.Context, postID, authorID, postText string, createdAt time.Time) error {
+	return s.scheduleFeedEvent(ctx, &model.FeedEvent{
+		PostID:       postID,
+		AuthorUserID: authorID,
+		PostText:     postText,
+		CreatedAt:    createdAt,
+		EventType:    model.FeedEventTypePostCreated,
+	})
+}
 
-	// Создаем событие ленты
-	event := &model.FeedEvent{
+// ScheduleFeedPostUpdate планирует обновление текста поста в лентах друзей автора
+func (s *service) ScheduleFeedPostUpdate(ctx context.Context, postID, authorID, postText string) error {
+	return s.scheduleFeedEvent(ctx, &model.FeedEvent{
 		PostID:       postID,
 		AuthorUserID: authorID,
 		PostText:     postText,
 		CreatedAt:    time.Now(),
-		EventType:    "post_created",
+		EventType:    model.FeedEventTypePostUpdated,
+	})
+}
+
+// ScheduleFeedPostRemoval планирует удаление поста из лент друзей автора
+func (s *service) ScheduleFeedPostRemoval(ctx context.Context, postID, authorID string) error {
+	return s.scheduleFeedEvent(ctx, &model.FeedEvent{
+		PostID:       postID,
+		AuthorUserID: authorID,
+		CreatedAt:    time.Now(),
+		EventType:    model.FeedEventTypePostDeleted,
+	})
+}
+
+// scheduleFeedEvent публикует одну задачу на событие поста. Раскладку по лентам друзей автора
+// и уведомления для WebSocket выполняет воркер, поэтому количество сообщений не зависит от числа друзей
+func (s *service) scheduleFeedEvent(ctx context.Context, event *model.FeedEvent) error {
+	task := &model.FeedUpdateTask{
+		PostID:    event.PostID,
+		Event:     event,
+		CreatedAt: time.Now(),
 	}
+	task.Priority = s.calculateFanOutPriority(ctx, event.AuthorUserID)
 
-	// Для каждого выбранного друга создаем задачу обновления ленты
-	for _, friendID := range friendsToProcess {
-		// Пропускаем автора поста (он не должен видеть свой пост в ленте друзей)
-		if friendID == authorID {
-			continue
-		}
+	if err := s.queueClient.PublishFeedUpdateTask(ctx, task); err != nil {
+		return err
+	}
 
-		// Проверяем, что friendID не пустой
-		if friendID == "" {
-			log.Printf("WARNING: Empty friendID found, skipping")
-			continue
-		}
+	log.Printf("Scheduled %s fan-out for post %s of user %s", event.EventType, event.PostID, event.AuthorUserID)
+	return nil
+}
+
+// isCelebrity проверяет, превышает ли количество друзей порог fan-out-on-write
+func (s *service) isCelebrity(friendsCount int) bool {
+	threshold := s.feedConfig.CelebrityThreshold()
+	return threshold > 0 && friendsCount > threshold
+}
 
-		// Определяем приоритет на основе активности пользователя
-		priority := s.calculateTaskPriority(authorID, friendID)
+// ScheduleFriendBackfill планирует добавление последних постов нового друга в ленты обоих пользователей
+func (s *service) ScheduleFriendBackfill(ctx context.Context, userID, friendID string) error {
+	return s.scheduleFriendshipEvent(ctx, model.FeedEventTypeFriendAdded, userID, friendID)
+}
+
+// ScheduleFriendPurge планирует удаление постов бывшего друга из лент обоих пользователей
+func (s *service) ScheduleFriendPurge(ctx context.Context, userID, friendID string) error {
+	return s.scheduleFriendshipEvent(ctx, model.FeedEventTypeFriendRemoved, userID, friendID)
+}
+
+// scheduleFriendshipEvent публикует задачи для обоих участников дружбы,
+// так как лента строится по связи в любую сторону (см. GetFriendsOfUser)
+func (s *service) scheduleFriendshipEvent(ctx context.Context, eventType, userID, friendID string) error {
+	pairs := [][2]string{
+		{userID, friendID},
+		{friendID, userID},
+	}
 
+	for _, pair := range pairs {
 		task := &model.FeedUpdateTask{
-			UserID:    friendID,
-			PostID:    postID,
-			Event:     event,
-			Priority:  priority,
+			UserID: pair[0],
+			Event: &model.FeedEvent{
+				AuthorUserID: pair[1],
+				CreatedAt:    time.Now(),
+				EventType:    eventType,
+			},
+			Priority:  s.calculateTaskPriority(ctx, pair[0]),
 			CreatedAt: time.Now(),
 		}
 
-		log.Printf("DEBUG: Creating task for friend %s, post %s, task.UserID=%s, priority=%d", friendID, postID, task.UserID, priority)
-
-		// Публикуем задачу в очередь
 		if err := s.queueClient.PublishFeedUpdateTask(ctx, task); err != nil {
-			log.Printf("Error publishing feed update task for user %s: %v", friendID, err)
-			continue
+			return err
 		}
+	}
 
-		log.Printf("DEBUG: Successfully published task for friend %s", friendID)
+	log.Printf("Scheduled %s feed updates for users %s and %s", eventType, userID, friendID)
+	return nil
+}
 
-		// Отправляем событие через WebSocket для конкретного пользователя
-		if err := s.queueClient.PublishFeedEvent(ctx, friendID, event); err != nil {
-			log.Printf("Error publishing feed event for user %s: %v", friendID, err)
-		}
+// calculateTaskPriority вычисляет приоритет задачи по активности получателя ленты
+func (s *service) calculateTaskPriority(ctx context.Context, userID string) int {
+	activity, err := s.activityRepository.GetActivity(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting activity of user %s: %v", userID, err)
+		return model.FeedTaskPriorityNormal
 	}
 
-	log.Printf("Scheduled feed updates for %d/%d friends of user %s (limited to prevent celebrity effect)",
-		len(friendsToProcess), len(friends), authorID)
-	return nil
+	return activityPriority(activity)
 }
 
-// calculateTaskPriority вычисляет приоритет задачи на основе активности пользователей
-// Высокий приоритет для активных пользователей, низкий для неактивных
-func (s *service) calculateTaskPriority(authorID, friendID string) int {
-	// Базовая логика приоритизации:
-	// 1 - Высокий приоритет (активные пользователи)
-	// 3 - Средний приоритет (обычные пользователи)
-	// 5 - Низкий приоритет (неактивные пользователи)
+// calculateFanOutPriority вычисляет приоритет задачи раскладки по самому активному из
+// fanOutPrioritySample друзей автора, чтобы пост, который ждут пользователи онлайн, обгонял
+// в очереди раскладку остальных постов. Выборка ограничена, поэтому публикация задачи
+// не зависит от числа друзей: полный список получателей читает только воркер
+func (s *service) calculateFanOutPriority(ctx context.Context, authorID string) int {
+	friends, err := s.feedRepository.GetFriendsSample(ctx, authorID, fanOutPrioritySample)
+	if err != nil {
+		log.Printf("Error getting friends of user %s: %v", authorID, err)
+		return model.FeedTaskPriorityNormal
+	}
 
-	// TODO: В будущем можно добавить более сложную логику:
-	// - Анализ частоты постов автора
-	// - История взаимодействий между пользователями
-	// - Время последней активности друга
+	recipients := make([]string, 0, len(friends))
+	for _, friendID := range friends {
+		if friendID != "" && friendID != authorID {
+			recipients = append(recipients, friendID)
+		}
+	}
+	if len(recipients) == 0 {
+		return model.FeedTaskPriorityNormal
+	}
 
-	// Пока используем случайный приоритет для демонстрации
-	rand.Seed(time.Now().UnixNano() + int64(len(authorID)+len(friendID)))
-	randomValue := rand.Intn(100)
+	activities, err := s.activityRepository.GetActivities(ctx, recipients)
+	if err != nil {
+		log.Printf("Error getting activity of %d users: %v", len(recipients), err)
+		return model.FeedTaskPriorityNormal
+	}
 
-	if randomValue < 20 {
-		return 1 // Высокий приоритет для 20% задач
-	} else if randomValue < 80 {
-		return 3 // Средний приоритет для 60% задач
-	} else {
-		return 5 // Низкий приоритет для 20% задач
+	priority := model.FeedTaskPriorityDormant
+	for _, activity := range activities {
+		priority = min(priority, activityPriority(activity))
+		if priority == model.FeedTaskPriorityOnline {
+			break
+		}
 	}
+
+	return priority
 }
 
-// limitFriendsForProcessing ограничивает количество друзей для обработки
-// Защита от "эффекта Леди Гаги" - если у пользователя слишком много друзей,
-// выбираем случайную выборку для обработки
-func (s *service) limitFriendsForProcessing(friends []string, maxCount int) []string {
-	if len(friends) <= maxCount {
-		return friends
+// activityPriority переводит активность пользователя в приоритет:
+// пользователи онлайн и недавно заходившие получают ленту первыми
+func activityPriority(activity *model.UserActivity) int {
+	if activity.Online {
+		return model.FeedTaskPriorityOnline
 	}
 
-	// Создаем копию слайса для перемешивания
-	friendsCopy := make([]string, len(friends))
-	copy(friendsCopy, friends)
-
-	// Перемешиваем друзей для случайной выборки
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(friendsCopy), func(i, j int) {
-		friendsCopy[i], friendsCopy[j] = friendsCopy[j], friendsCopy[i]
-	})
+	lastSeen := activity.LastSeenAt()
+	if lastSeen.IsZero() {
+		return model.FeedTaskPriorityDormant
+	}
 
-	// Возвращаем только первые maxCount друзей
-	return friendsCopy[:maxCount]
+	switch since := time.Since(lastSeen); {
+	case since < time.Hour:
+		return model.FeedTaskPriorityActive
+	case since < 24*time.Hour:
+		return model.FeedTaskPriorityNormal
+	case since < 7*24*time.Hour:
+		return model.FeedTaskPriorityInactive
+	default:
+		return model.FeedTaskPriorityDormant
+	}
 }
 
 // ProcessFeedUpdateTask обрабатывает задачу обновления ленты
@@ -158,77 +235,310 @@ func (s *service) ProcessFeedUpdateTask(ctx context.Context, task *model.FeedUpd
 	log.Printf("DEBUG: Processing task - UserID: '%s', PostID: '%s', Priority: %d", task.UserID, task.PostID, task.Priority)
 
 	// Проверяем валидность задачи
-	if task.UserID == "" || task.PostID == "" {
+	if !task.IsValid() {
 		log.Printf("ERROR: Invalid task - UserID: '%s', PostID: '%s'", task.UserID, task.PostID)
 		return nil // Пропускаем пустые задачи
 	}
 
-	// Проверяем, не обрабатывали ли мы уже эту задачу
-	// Это поможет избежать дублирующей обработки
-	jobKey := task.UserID + ":" + task.PostID
-	log.Printf("DEBUG: Processing job with key: %s", jobKey)
+	// События дружбы не привязаны к посту, поэтому не отслеживаются в feed_jobs
+	if task.Event.IsFriendshipEvent() {
+		return s.processFriendshipTask(ctx, task)
+	}
+
+	attempts, err := s.startJob(ctx, task)
+	if err != nil {
+		if errors.Is(err, model.ErrorFeedJobNotPending) {
+			log.Printf("Feed job %s is already taken or completed, skipping", task.JobID)
+			return nil
+		}
+
+		log.Printf("Error creating feed job: %v", err)
+		return err
+	}
+
+	// Раскладываем событие по лентам получателей
+	recipients, err := s.fanOutPostEvent(ctx, task, attempts)
+	if err != nil {
+		// Повтор выполняет reaper с нарастающей задержкой, поэтому сообщение подтверждается.
+		// Если сохранить ошибку не удалось, задача возвращается брокеру
+		retryAt := time.Now().Add(s.retryBackoff(attempts))
+		if failErr := s.feedRepository.FailJob(ctx, task.JobID, err.Error(), retryAt); failErr != nil {
+			log.Printf("Error updating job status: %v", failErr)
+			return err
+		}
+
+		if attempts >= s.feedConfig.JobMaxAttempts() {
+			log.Printf("Feed job %s failed after %d attempts, giving up: %v", task.JobID, attempts, err)
+		} else {
+			log.Printf("Feed job %s failed (attempt %d), retry at %s: %v", task.JobID, attempts, retryAt.Format(time.RFC3339), err)
+		}
+		return nil
+	}
+
+	// Обновляем статус задания на completed
+	if err := s.feedRepository.UpdateJobStatus(ctx, task.JobID, feedModel.FeedJobStatusCompleted, nil); err != nil {
+		log.Printf("Error updating job status: %v", err)
+	}
+	if err := s.feedRepository.DeleteJobRecipients(ctx, task.JobID); err != nil {
+		log.Printf("Error deleting progress of feed job %s: %v", task.JobID, err)
+	}
+
+	log.Printf("Processed %s task for post %s, %d recipients", task.Event.EventType, task.PostID, recipients)
+	return nil
+}
+
+// startJob отмечает начало обработки задачи в feed_jobs и возвращает номер попытки.
+// Новая задача получает задание с исходной задачей в payload, чтобы reaper мог ее повторить
+func (s *service) startJob(ctx context.Context, task *model.FeedUpdateTask) (int, error) {
+	if task.JobID != "" {
+		return s.feedRepository.StartJob(ctx, task.JobID)
+	}
+
+	task.JobID = uuid.New().String()
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return 0, err
+	}
 
-	// Создаем задание в БД для отслеживания
 	job := &feedModel.FeedJob{
-		ID:        uuid.New().String(),
+		ID:        task.JobID,
 		UserID:    task.UserID,
 		PostID:    task.PostID,
-		Status:    "processing",
+		EventType: task.Event.EventType,
+		Status:    feedModel.FeedJobStatusProcessing,
 		Priority:  task.Priority,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Attempts:  1,
+		Payload:   payload,
 	}
 
-	if err := s.feedRepository.CreateJob(ctx, job); err != nil {
-		log.Printf("Error creating feed job: %v", err)
-		return err
+	if err = s.feedRepository.CreateJob(ctx, job); err != nil {
+		return 0, err
 	}
 
-	log.Printf("DEBUG: Created job %s for user %s, post %s", job.ID, task.UserID, task.PostID)
+	return job.Attempts, nil
+}
 
-	// Добавляем пост в материализованную ленту пользователя
-	if err := s.feedRepository.AddToFeed(ctx, task.UserID, task.PostID, task.Event.AuthorUserID, task.Event.PostText); err != nil {
-		// Обновляем статус задания на failed
-		errorMsg := err.Error()
-		if updateErr := s.feedRepository.UpdateJobStatus(ctx, job.ID, "failed", &errorMsg); updateErr != nil {
-			log.Printf("Error updating job status: %v", updateErr)
+// processFriendshipTask наполняет или очищает ленту пользователя при изменении списка друзей
+func (s *service) processFriendshipTask(ctx context.Context, task *model.FeedUpdateTask) error {
+	authorID := task.Event.AuthorUserID
+
+	switch task.Event.EventType {
+	case model.FeedEventTypeFriendAdded:
+		if err := s.feedRepository.BackfillFromAuthor(ctx, task.UserID, authorID, BackfillPostsPerFriend); err != nil {
+			return err
+		}
+	case model.FeedEventTypeFriendRemoved:
+		// Связь могла остаться в обратную сторону или быть восстановлена, пока задача ждала в очереди
+		friends, err := s.feedRepository.GetFriendsOfUser(ctx, task.UserID)
+		if err != nil {
+			return err
+		}
+		for _, friendID := range friends {
+			if friendID == authorID {
+				log.Printf("Users %s and %s are still friends, skipping feed purge", task.UserID, authorID)
+				return nil
+			}
+		}
+
+		if err := s.feedRepository.RemoveAuthorFromFeed(ctx, task.UserID, authorID); err != nil {
+			return err
 		}
-		return err
 	}
 
-	// Обновляем статус задания на completed
-	if err := s.feedRepository.UpdateJobStatus(ctx, job.ID, "completed", nil); err != nil {
-		log.Printf("Error updating job status: %v", err)
+	// Набор постов ленты изменился целиком, кэш прогреется при следующем чтении
+	if err := s.feedCache.InvalidateFeed(ctx, task.UserID); err != nil {
+		log.Printf("Error invalidating feed cache for user %s: %v", task.UserID, err)
 	}
 
-	log.Printf("Processed feed update task for user %s, post %s", task.UserID, task.PostID)
+	log.Printf("Processed %s task for user %s, friend %s", task.Event.EventType, task.UserID, authorID)
 	return nil
 }
 
+// checkCacheError сбрасывает кэш лент, если его не удалось обновить,
+// чтобы следующее чтение пошло в базу, а не вернуло устаревшие данные
+func (s *service) checkCacheError(ctx context.Context, userIDs []string, err error) {
+	if err == nil {
+		return
+	}
+
+	log.Printf("Error updating feed cache for %d users: %v", len(userIDs), err)
+	for _, userID := range userIDs {
+		if err = s.feedCache.InvalidateFeed(ctx, userID); err != nil {
+			log.Printf("Error invalidating feed cache for user %s: %v", userID, err)
+		}
+	}
+}
+
 // GetMaterializedFeed получает материализованную ленту пользователя
-func (s *service) GetMaterializedFeed(ctx context.Context, userID string, offset, limit int) ([]*feedModel.MaterializedFeed, error) {
-	return s.feedRepository.GetFeed(ctx, userID, offset, limit)
+// и подмешивает к ней последние посты популярных друзей (fan-out-on-read)
+func (s *service) GetMaterializedFeed(ctx context.Context, userID string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error) {
+	if err := s.activityRepository.TouchFeedRead(ctx, userID); err != nil {
+		log.Printf("Error saving feed read time of user %s: %v", userID, err)
+	}
+
+	if s.feedConfig.CelebrityThreshold() <= 0 {
+		return s.getFeedPage(ctx, userID, cursor, limit)
+	}
+
+	celebrities, err := s.feedRepository.GetCelebrityFriends(ctx, userID, s.feedConfig.CelebrityThreshold())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(celebrities) == 0 {
+		return s.getFeedPage(ctx, userID, cursor, limit)
+	}
+
+	// Оба источника упорядочены по (created_at, post_id), поэтому страницу после курсора
+	// можно собрать из первых limit записей каждого из них
+	materialized, err := s.getFeedPage(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	celebrityPosts, err := s.feedRepository.GetRecentPostsByAuthors(ctx, celebrities, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeFeeds(userID, materialized, celebrityPosts, limit), nil
 }
 
-// StartWorker запускает воркер для обработки задач материализации
+// getFeedPage читает страницу материализованной ленты из кэша, при промахе - из базы.
+// Промах на первой странице прогревает кэш последними CacheMaxLength записями
+func (s *service) getFeedPage(ctx context.Context, userID string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error) {
+	items, ok, err := s.feedCache.GetFeed(ctx, userID, cursor, limit)
+	if err != nil {
+		log.Printf("Error reading feed cache for user %s: %v", userID, err)
+	}
+	if ok {
+		metric.IncResponseCounter("GetMaterializedFeed", "redis")
+		return items, nil
+	}
+
+	metric.IncResponseCounter("GetMaterializedFeed", "db")
+
+	if cursor != nil {
+		return s.feedRepository.GetFeed(ctx, userID, cursor, limit)
+	}
+
+	warmLimit := max(limit, s.feedConfig.CacheMaxLength())
+	items, err = s.feedRepository.GetFeed(ctx, userID, nil, warmLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Если база вернула меньше записей, чем запрошено, в кэше окажется вся лента,
+	// и короткие страницы будут читаться из кэша
+	if err = s.feedCache.WarmFeed(ctx, userID, items, len(items) < warmLimit); err != nil {
+		log.Printf("Error warming feed cache for user %s: %v", userID, err)
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	return items, nil
+}
+
+// mergeFeeds объединяет записи ленты без дублей по посту и упорядочивает их по времени создания
+func mergeFeeds(userID string, materialized, celebrityPosts []*feedModel.MaterializedFeed, limit int) []*feedModel.MaterializedFeed {
+	seen := make(map[string]struct{}, len(materialized)+len(celebrityPosts))
+	merged := make([]*feedModel.MaterializedFeed, 0, len(materialized)+len(celebrityPosts))
+
+	for _, items := range [][]*feedModel.MaterializedFeed{materialized, celebrityPosts} {
+		for _, item := range items {
+			if _, ok := seen[item.PostID]; ok {
+				continue
+			}
+			seen[item.PostID] = struct{}{}
+
+			if item.UserID == "" {
+				item.UserID = userID
+			}
+			merged = append(merged, item)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].PostID > merged[j].PostID
+		}
+		return merged[i].CreatedAt.After(merged[j].CreatedAt)
+	})
+
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+
+	return merged
+}
+
+// StartWorker запускает пул воркеров для обработки задач материализации, фоновую очистку лент и повтор заданий.
+// Повторный запуск уже работающего воркера ничего не делает
 func (s *service) StartWorker(ctx context.Context) error {
+	if s.workerCancel != nil {
+		return nil
+	}
+
 	s.workerCtx, s.workerCancel = context.WithCancel(ctx)
 
 	// Запускаем потребление задач из очереди
-	err := s.queueClient.ConsumeFeedMaterializationTasks(s.workerCtx, s.ProcessFeedUpdateTask)
+	consumer, err := s.queueClient.ConsumeFeedMaterializationTasks(s.workerCtx, s.processQueuedTask, queue.ConsumerOptions{
+		Workers:  s.feedConfig.WorkerCount(),
+		Prefetch: s.feedConfig.WorkerPrefetch(),
+	})
 	if err != nil {
+		s.workerCancel()
+		s.workerCancel = nil
 		return err
 	}
+	s.workerConsumer = consumer
 
-	log.Println("Feed materialization worker started")
+	metric.SetFeedWorkers(s.feedConfig.WorkerCount())
+	metric.SetFeedWorkersBusy(0, s.feedConfig.WorkerCount())
+
+	// Очистка лент и повтор заданий живут столько же, сколько воркер
+	go s.runRetention(s.workerCtx)
+	go s.runReaper(s.workerCtx)
+
+	log.Printf("Feed materialization worker started: %d workers, prefetch %d", s.feedConfig.WorkerCount(), s.feedConfig.WorkerPrefetch())
 	return nil
 }
 
-// StopWorker останавливает воркер
+// processQueuedTask обрабатывает задачу из очереди и учитывает занятость воркеров
+// и время, которое задача провела в очереди
+func (s *service) processQueuedTask(ctx context.Context, task *model.FeedUpdateTask) error {
+	if !task.CreatedAt.IsZero() {
+		metric.FeedTaskLagObserve(task.Event.EventType, time.Since(task.CreatedAt).Seconds())
+	}
+
+	metric.SetFeedWorkersBusy(int(s.busyWorkers.Add(1)), s.feedConfig.WorkerCount())
+	defer func() {
+		metric.SetFeedWorkersBusy(int(s.busyWorkers.Add(-1)), s.feedConfig.WorkerCount())
+	}()
+
+	return s.ProcessFeedUpdateTask(ctx, task)
+}
+
+// StopWorker перестает получать задачи и ждет начатые не дольше WorkerDrainTimeout.
+// Задачи, не завершенные за это время, возвращаются в очередь
 func (s *service) StopWorker(ctx context.Context) error {
-	if s.workerCancel != nil {
-		s.workerCancel()
-		log.Println("Feed materialization worker stopped")
+	if s.workerCancel == nil {
+		return nil
 	}
-	return nil
+
+	var err error
+	if s.workerConsumer != nil {
+		drainCtx, cancel := context.WithTimeout(ctx, s.feedConfig.WorkerDrainTimeout())
+		defer cancel()
+
+		err = s.workerConsumer.Stop(drainCtx)
+	}
+
+	s.workerCancel()
+	s.workerCancel, s.workerConsumer = nil, nil
+
+	log.Println("Feed materialization worker stopped")
+	return err
 }
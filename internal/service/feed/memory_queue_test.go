@@ -0,0 +1,144 @@
+package feed
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/client/queue/memory"
+	"otus-project/internal/config"
+	"otus-project/internal/metric"
+	"otus-project/internal/model"
+	feedModel "otus-project/internal/repository/feed/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testRetryConfig повторы очереди в памяти без заметных задержек
+type testRetryConfig struct{}
+
+func (testRetryConfig) RetryMaxAttempts() int          { return 3 }
+func (testRetryConfig) RetryBackoff() time.Duration    { return time.Millisecond }
+func (testRetryConfig) RetryMaxBackoff() time.Duration { return 5 * time.Millisecond }
+
+// initMetrics регистрирует метрики воркера один раз на процесс
+var initMetrics sync.Once
+
+// userFeedEvent событие ленты, полученное узлом WebSocket
+type userFeedEvent struct {
+	userID string
+	event  *model.FeedEvent
+}
+
+// TestPostFanOutThroughMemoryQueue проходит весь путь поста через очередь в памяти:
+// публикация задачи раскладки, материализация воркером и доставка события узлу WebSocket
+func TestPostFanOutThroughMemoryQueue(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	initMetrics.Do(func() {
+		if err := metric.Init(ctx); err != nil {
+			t.Fatalf("failed to init metrics: %v", err)
+		}
+	})
+
+	recipients := testRecipients(5)
+	repo := newFakeFeedRepository(map[string][]string{testAuthorID: append([]string{testAuthorID}, recipients...)})
+
+	queueClient := memory.NewClient(testRetryConfig{})
+	defer queueClient.Close()
+
+	feedConfig, err := config.NewFeedConfig()
+	if err != nil {
+		t.Fatalf("failed to load feed config: %v", err)
+	}
+
+	// user-3 онлайн и подключен к узлу, остальные получатели не подключены
+	eventLog := &fakeEventLog{}
+	s := NewService(
+		repo,
+		fakeFeedCache{},
+		&fakeActivityRepository{activities: map[string]*model.UserActivity{
+			"user-3": {UserID: "user-3", Online: true},
+		}},
+		eventLog,
+		fakeConnectionRegistry{nodes: map[string][]string{"user-3": {"node-1"}}},
+		queueClient,
+		testFeedConfig{FeedConfig: feedConfig, chunkSize: 2},
+	)
+
+	events := make(chan userFeedEvent, len(recipients))
+	if err := queueClient.ConsumeUserEvents(ctx, queue.UserEventHandlers{
+		FeedEvent: func(_ context.Context, userID string, event *model.FeedEvent) error {
+			events <- userFeedEvent{userID: userID, event: event}
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("ConsumeUserEvents: %v", err)
+	}
+	if err := queueClient.BindUserEvents(ctx, "user-3"); err != nil {
+		t.Fatalf("BindUserEvents: %v", err)
+	}
+
+	if err := s.StartWorker(ctx); err != nil {
+		t.Fatalf("StartWorker: %v", err)
+	}
+	defer func() {
+		if err := s.StopWorker(ctx); err != nil {
+			t.Errorf("StopWorker: %v", err)
+		}
+	}()
+
+	postID := uuid.New().String()
+	if err := s.ScheduleFeedUpdate(ctx, postID, testAuthorID, "hello", time.Now()); err != nil {
+		t.Fatalf("ScheduleFeedUpdate: %v", err)
+	}
+
+	select {
+	case got := <-events:
+		if got.userID != "user-3" || got.event.PostID != postID || got.event.PostText != "hello" || got.event.EventID != 1 {
+			t.Errorf("received event for %s: %+v", got.userID, got.event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for websocket event")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		statuses := repo.jobStatuses()
+		if len(statuses) == 1 && statuses[0] == feedModel.FeedJobStatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job statuses = %v, want one completed job", statuses)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, userID := range recipients {
+		if !repo.hasPost(userID, postID) {
+			t.Errorf("post is missing in feed of %s", userID)
+		}
+		if n := eventLog.count(userID); n != 1 {
+			t.Errorf("%s has %d event log entries, want 1", userID, n)
+		}
+	}
+	if repo.hasPost(testAuthorID, postID) {
+		t.Error("post is added to the author's feed")
+	}
+
+	select {
+	case got := <-events:
+		t.Errorf("unexpected event for disconnected user %s", got.userID)
+	default:
+	}
+
+	if dead, _ := queueClient.ListDeadLetters(ctx, 10); len(dead) != 0 {
+		t.Errorf("dead-letter queue has %d tasks", len(dead))
+	}
+}
@@ -0,0 +1,88 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"otus-project/internal/model"
+	feedModel "otus-project/internal/repository/feed/model"
+	"time"
+)
+
+const (
+	// reaperBatchSize сколько заданий повторяется за один проход
+	reaperBatchSize = 100
+)
+
+// runReaper периодически повторяет зависшие и упавшие задания до остановки воркера
+func (s *service) runReaper(ctx context.Context) {
+	ticker := time.NewTicker(s.feedConfig.JobReaperInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reapJobs(ctx)
+		}
+	}
+}
+
+// reapJobs помечает упавшими задания, зависшие дольше JobTimeout (например, после падения воркера),
+// и заново публикует в очередь упавшие задания, у которых остались попытки и подошло время повтора
+func (s *service) reapJobs(ctx context.Context) {
+	stuck, err := s.feedRepository.FailStuckJobs(ctx, time.Now().Add(-s.feedConfig.JobTimeout()))
+	if err != nil {
+		log.Printf("Error failing stuck feed jobs: %v", err)
+		return
+	}
+	if stuck > 0 {
+		log.Printf("Marked %d stuck feed jobs as failed", stuck)
+	}
+
+	jobs, err := s.feedRepository.ClaimJobsForRetry(ctx, s.feedConfig.JobMaxAttempts(), reaperBatchSize)
+	if err != nil {
+		log.Printf("Error claiming feed jobs for retry: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		if err := s.requeueJob(ctx, job); err != nil {
+			log.Printf("Error requeueing feed job %s: %v", job.ID, err)
+
+			retryAt := time.Now().Add(s.retryBackoff(job.Attempts))
+			if failErr := s.feedRepository.FailJob(ctx, job.ID, err.Error(), retryAt); failErr != nil {
+				log.Printf("Error updating job status: %v", failErr)
+			}
+		}
+	}
+
+	if len(jobs) > 0 {
+		log.Printf("Requeued %d feed jobs", len(jobs))
+	}
+}
+
+// requeueJob публикует исходную задачу задания с привязкой к нему
+func (s *service) requeueJob(ctx context.Context, job *feedModel.FeedJob) error {
+	var task model.FeedUpdateTask
+	if err := json.Unmarshal(job.Payload, &task); err != nil {
+		return err
+	}
+	task.JobID = job.ID
+
+	return s.queueClient.PublishFeedUpdateTask(ctx, &task)
+}
+
+// retryBackoff задержка перед следующей попыткой: JobRetryBackoff, удваиваемый
+// с каждой попыткой, но не больше JobRetryMaxBackoff
+func (s *service) retryBackoff(attempts int) time.Duration {
+	backoff := s.feedConfig.JobRetryBackoff()
+	maxBackoff := s.feedConfig.JobRetryMaxBackoff()
+
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
@@ -0,0 +1,98 @@
+package feed
+
+import (
+	"context"
+	"log"
+	"otus-project/internal/metric"
+	"time"
+)
+
+const (
+	trimReasonCount = "count"
+	trimReasonAge   = "age"
+)
+
+// runRetention периодически очищает материализованные ленты до остановки воркера
+func (s *service) runRetention(ctx context.Context) {
+	ticker := time.NewTicker(s.feedConfig.RetentionInterval())
+	defer ticker.Stop()
+
+	for {
+		s.compactFeeds(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// compactFeeds оставляет в лентах только последние RetentionMaxEntries записей
+// каждого пользователя и записи моложе RetentionMaxAge. Удаление идет пачками,
+// чтобы не держать долгих блокировок на таблице
+func (s *service) compactFeeds(ctx context.Context) {
+	batchSize := s.feedConfig.RetentionBatchSize()
+
+	if maxEntries := s.feedConfig.RetentionMaxEntries(); maxEntries > 0 {
+		s.trimByCount(ctx, maxEntries, batchSize)
+	}
+
+	if maxAge := s.feedConfig.RetentionMaxAge(); maxAge > 0 {
+		olderThan := time.Now().Add(-maxAge)
+		s.trimInBatches(ctx, trimReasonAge, batchSize, func(ctx context.Context) (int64, error) {
+			return s.feedRepository.TrimByAge(ctx, olderThan, batchSize)
+		})
+	}
+}
+
+// trimByCount проходит пользователей по возрастанию ID пачками по batchSize
+// и оставляет в ленте каждого maxEntries последних записей
+func (s *service) trimByCount(ctx context.Context, maxEntries, batchSize int) {
+	var (
+		total       int64
+		afterUserID string
+	)
+	for ctx.Err() == nil {
+		lastUserID, deleted, err := s.feedRepository.TrimByCount(ctx, afterUserID, maxEntries, batchSize)
+		if err != nil {
+			log.Printf("Error trimming materialized feeds by %s: %v", trimReasonCount, err)
+			break
+		}
+
+		total += deleted
+		metric.AddFeedTrimmedRows(trimReasonCount, deleted)
+
+		if lastUserID == "" {
+			break
+		}
+		afterUserID = lastUserID
+	}
+
+	if total > 0 {
+		log.Printf("Trimmed %d materialized feed rows by %s", total, trimReasonCount)
+	}
+}
+
+// trimInBatches повторяет удаление, пока очередная пачка не окажется неполной
+func (s *service) trimInBatches(ctx context.Context, reason string, batchSize int, trim func(ctx context.Context) (int64, error)) {
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := trim(ctx)
+		if err != nil {
+			log.Printf("Error trimming materialized feeds by %s: %v", reason, err)
+			break
+		}
+
+		total += deleted
+		metric.AddFeedTrimmedRows(reason, deleted)
+
+		if deleted < int64(batchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("Trimmed %d materialized feed rows by %s", total, reason)
+	}
+}
@@ -4,20 +4,34 @@ import (
 	"context"
 	"otus-project/internal/model"
 	feedModel "otus-project/internal/repository/feed/model"
+	"time"
 )
 
 // Service интерфейс для работы с отложенной материализацией ленты
 type Service interface {
-	// ScheduleFeedUpdate планирует обновление ленты для друзей автора поста
-	ScheduleFeedUpdate(ctx context.Context, postID, authorID, postText string) error
+	// ScheduleFeedUpdate планирует обновление ленты для друзей автора поста.
+	// createdAt - время создания поста, по нему пост упорядочивается в лентах
+	ScheduleFeedUpdate(ctx context.Context, postID, authorID, postText string, createdAt time.Time) error
+
+	// ScheduleFeedPostUpdate планирует обновление текста поста в лентах друзей автора
+	ScheduleFeedPostUpdate(ctx context.Context, postID, authorID, postText string) error
+
+	// ScheduleFeedPostRemoval планирует удаление поста из лент друзей автора
+	ScheduleFeedPostRemoval(ctx context.Context, postID, authorID string) error
+
+	// ScheduleFriendBackfill планирует добавление последних постов нового друга в ленту
+	ScheduleFriendBackfill(ctx context.Context, userID, friendID string) error
+
+	// ScheduleFriendPurge планирует удаление постов бывшего друга из ленты
+	ScheduleFriendPurge(ctx context.Context, userID, friendID string) error
 
 	// ProcessFeedUpdateTask обрабатывает задачу обновления ленты
 	ProcessFeedUpdateTask(ctx context.Context, task *model.FeedUpdateTask) error
 
 	// GetMaterializedFeed получает материализованную ленту пользователя
-	GetMaterializedFeed(ctx context.Context, userID string, offset, limit int) ([]*feedModel.MaterializedFeed, error)
+	GetMaterializedFeed(ctx context.Context, userID string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error)
 
-	// StartWorker запускает воркер для обработки задач материализации
+	// StartWorker запускает воркер для обработки задач материализации, фоновую очистку лент и повтор заданий
 	StartWorker(ctx context.Context) error
 
 	// StopWorker останавливает воркер
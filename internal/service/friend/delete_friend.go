@@ -2,6 +2,10 @@ package friend
 
 import (
 	"context"
+	"otus-project/internal/model"
+	eventBusService "otus-project/internal/service/event_bus"
+	"time"
+
 	"github.com/pkg/errors"
 )
 
@@ -11,5 +15,16 @@ func (s *serv) DeleteFriend(ctx context.Context, userId, friendId string) error
 		return errors.New("id пользователя или друга не может быть пустым")
 	}
 
-	return s.friendRepository.Delete(ctx, userId, friendId)
+	return s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		if err := s.friendRepository.Delete(ctx, userId, friendId); err != nil {
+			return err
+		}
+
+		// Событие уберет посты бывшего друга из ленты
+		return s.outboxRepository.Add(ctx, userId, eventBusService.FriendRemoved.New(&model.FriendRemovedEvent{
+			UserID:    userId,
+			FriendID:  friendId,
+			RemovedAt: time.Now(),
+		}))
+	})
 }
@@ -9,15 +9,18 @@ import (
 
 type serv struct {
 	friendRepository repository.FriendRepository
+	outboxRepository repository.OutboxRepository
 	txManager        db.TxManager
 }
 
 func NewService(
 	friendRepository repository.FriendRepository,
+	outboxRepository repository.OutboxRepository,
 	txManager db.TxManager,
 ) service.FriendService {
 	return &serv{
 		friendRepository: friendRepository,
+		outboxRepository: outboxRepository,
 		txManager:        txManager,
 	}
 }
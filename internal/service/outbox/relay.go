@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/config"
+	"otus-project/internal/metric"
+	"otus-project/internal/repository"
+	"sync"
+	"time"
+)
+
+const (
+	eventStatusDispatched = "dispatched"
+	eventStatusFailed     = "failed"
+
+	// cleanupBatchSize сколько отправленных событий удаляется за один запрос
+	cleanupBatchSize = 1000
+)
+
+type relay struct {
+	outboxRepository repository.OutboxRepository
+	queueClient      queue.Client
+	outboxConfig     config.OutboxConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRelay создает relay, публикующий события outbox в RabbitMQ.
+// Доставка at-least-once: событие отмечается отправленным только после публикации,
+// поэтому при сбое между публикацией и отметкой оно будет отправлено повторно
+func NewRelay(
+	outboxRepository repository.OutboxRepository,
+	queueClient queue.Client,
+	outboxConfig config.OutboxConfig,
+) Relay {
+	return &relay{
+		outboxRepository: outboxRepository,
+		queueClient:      queueClient,
+		outboxConfig:     outboxConfig,
+	}
+}
+
+// Start запускает отправку событий и удаление отправленных
+func (r *relay) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(2)
+	go r.runDispatch(ctx)
+	go r.runCleanup(ctx)
+
+	log.Println("Outbox relay started")
+	return nil
+}
+
+// Stop останавливает relay и дожидается завершения текущей пачки
+func (r *relay) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Outbox relay stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runDispatch отправляет события пачками. Пока пачки полные, следующая читается сразу,
+// иначе relay ждет PollInterval
+func (r *relay) runDispatch(ctx context.Context) {
+	defer r.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		dispatched, err := r.dispatchBatch(ctx)
+		if err != nil {
+			log.Printf("Error dispatching outbox events: %v", err)
+		}
+
+		if err == nil && dispatched == r.outboxConfig.BatchSize() {
+			timer.Reset(0)
+		} else {
+			timer.Reset(r.outboxConfig.PollInterval())
+		}
+	}
+}
+
+// dispatchBatch закрепляет за relay пачку событий и публикует их вне транзакции, поэтому
+// медленный брокер не держит блокировки строк outbox. Событие, не отмеченное отправленным
+// до истечения Lease, возьмет следующий проход. Порядок событий между проходами и relay
+// не гарантируется
+func (r *relay) dispatchBatch(ctx context.Context) (int, error) {
+	messages, err := r.outboxRepository.ClaimPending(ctx, r.outboxConfig.BatchSize(), time.Now().Add(r.outboxConfig.Lease()))
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		dispatched = make([]string, 0, len(messages))
+		failed     int
+		publishErr error
+	)
+	for _, message := range messages {
+		if err = r.queueClient.PublishDomainEvent(ctx, message); err != nil {
+			publishErr = err
+			failed++
+			if err = r.outboxRepository.MarkFailed(ctx, message.ID, publishErr.Error()); err != nil {
+				log.Printf("Error marking outbox event %s failed: %v", message.ID, err)
+			}
+			continue
+		}
+		dispatched = append(dispatched, message.ID)
+	}
+
+	// Не отмеченные события будут опубликованы повторно после Lease: доставка at-least-once
+	if err = r.outboxRepository.MarkDispatched(ctx, dispatched); err != nil {
+		return 0, err
+	}
+
+	metric.AddOutboxEvents(eventStatusDispatched, len(dispatched))
+	metric.AddOutboxEvents(eventStatusFailed, failed)
+
+	return len(dispatched), publishErr
+}
+
+// runCleanup периодически удаляет отправленные события старше Retention
+func (r *relay) runCleanup(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.outboxConfig.CleanupInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.deleteDispatched(ctx)
+	}
+}
+
+// deleteDispatched удаляет отправленные события пачками, пока очередная пачка не окажется неполной
+func (r *relay) deleteDispatched(ctx context.Context) {
+	olderThan := time.Now().Add(-r.outboxConfig.Retention())
+
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := r.outboxRepository.DeleteDispatched(ctx, olderThan, cleanupBatchSize)
+		if err != nil {
+			log.Printf("Error deleting dispatched outbox events: %v", err)
+			break
+		}
+
+		total += deleted
+		if deleted < cleanupBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("Deleted %d dispatched outbox events", total)
+	}
+}
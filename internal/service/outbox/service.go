@@ -0,0 +1,14 @@
+package outbox
+
+import (
+	"context"
+)
+
+// Relay публикует события из outbox в брокер сообщений
+type Relay interface {
+	// Start запускает отправку событий и удаление отправленных
+	Start(ctx context.Context) error
+
+	// Stop останавливает relay и дожидается завершения текущей пачки
+	Stop(ctx context.Context) error
+}
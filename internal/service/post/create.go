@@ -2,12 +2,13 @@ package post
 
 import (
 	"context"
-	"log"
 	"otus-project/internal/model"
+	eventBusService "otus-project/internal/service/event_bus"
 	"time"
 )
 
-// Create Создание поста
+// Create Создание поста. Событие создания пишется в outbox в той же транзакции,
+// поэтому раскладка по лентам не теряется при сбое после коммита
 func (s *serv) Create(ctx context.Context, info *model.Post) (*string, error) {
 	var id *string
 	err := s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
@@ -16,28 +17,21 @@ func (s *serv) Create(ctx context.Context, info *model.Post) (*string, error) {
 		if errTx != nil {
 			return errTx
 		}
-		return nil
-	})
 
-	if err != nil {
-		return nil, err
-	}
+		if id == nil || info.Text == nil || info.AuthorUserId == nil {
+			return nil
+		}
 
-	// Публикуем событие создания поста
-	if s.eventBus != nil && id != nil && info.Text != nil && info.AuthorUserId != nil {
-		event := &model.PostCreatedEvent{
+		return s.outboxRepository.Add(ctx, *id, eventBusService.PostCreated.New(&model.PostCreatedEvent{
 			PostID:       *id,
 			AuthorUserID: *info.AuthorUserId,
 			PostText:     *info.Text,
 			CreatedAt:    time.Now(),
-		}
+		}))
+	})
 
-		// Публикуем событие асинхронно, чтобы не блокировать создание поста
-		//go func() {
-		if err := s.eventBus.PublishEvent(context.Background(), model.EventTypePostCreated, event); err != nil {
-			log.Printf("Error publishing post created event: %v", err)
-		}
-		//}()
+	if err != nil {
+		return nil, err
 	}
 
 	return id, nil
@@ -7,13 +7,13 @@ import (
 	"otus-project/internal/repository"
 	"otus-project/internal/service"
 	eventBusService "otus-project/internal/service/event_bus"
+	"time"
 )
 
 type serv struct {
 	postPgRepository repository.PostRepository
-	postRRepository  repository.PostRepository
+	outboxRepository repository.OutboxRepository
 	txManager        db.TxManager
-	eventBus         eventBusService.EventBus
 }
 
 // PostService интерфейс сервиса постов
@@ -23,20 +23,18 @@ type PostService interface {
 	GetByID(ctx context.Context, id string) (*model.Post, error)
 	Update(ctx context.Context, id string, text string) error
 	Delete(ctx context.Context, id string) error
-	Feed(ctx context.Context, id string, offset *float32, limit *float32) ([]*model.Post, error)
+	Feed(ctx context.Context, id string, cursor *model.Cursor, limit int) ([]*model.Post, error)
 }
 
 func NewService(
 	postPgRepository repository.PostRepository,
-	postRRepository repository.PostRepository,
+	outboxRepository repository.OutboxRepository,
 	txManager db.TxManager,
-	eventBus eventBusService.EventBus,
 ) service.PostService {
 	return &serv{
 		postPgRepository: postPgRepository,
-		postRRepository:  postRRepository,
+		outboxRepository: outboxRepository,
 		txManager:        txManager,
-		eventBus:         eventBus,
 	}
 }
 
@@ -47,10 +45,51 @@ func (s *serv) GetByID(ctx context.Context, id string) (*model.Post, error) {
 
 // Update обновляет пост
 func (s *serv) Update(ctx context.Context, id string, text string) error {
-	return s.postPgRepository.Update(ctx, id, text)
+	return s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		post, errTx := s.postPgRepository.GetByID(ctx, id)
+		if errTx != nil {
+			return errTx
+		}
+
+		if errTx = s.postPgRepository.Update(ctx, id, text); errTx != nil {
+			return errTx
+		}
+
+		if post.AuthorUserId == nil {
+			return nil
+		}
+
+		// Событие изменения поста обновит материализованные ленты
+		return s.outboxRepository.Add(ctx, id, eventBusService.PostUpdated.New(&model.PostUpdatedEvent{
+			PostID:       id,
+			AuthorUserID: *post.AuthorUserId,
+			PostText:     text,
+			UpdatedAt:    time.Now(),
+		}))
+	})
 }
 
 // Delete удаляет пост
 func (s *serv) Delete(ctx context.Context, id string) error {
-	return s.postPgRepository.Delete(ctx, id)
+	return s.txManager.ReadCommitted(ctx, func(ctx context.Context) error {
+		post, errTx := s.postPgRepository.GetByID(ctx, id)
+		if errTx != nil {
+			return errTx
+		}
+
+		if errTx = s.postPgRepository.Delete(ctx, id); errTx != nil {
+			return errTx
+		}
+
+		if post.AuthorUserId == nil {
+			return nil
+		}
+
+		// Событие удаления поста уберет его из материализованных лент
+		return s.outboxRepository.Add(ctx, id, eventBusService.PostDeleted.New(&model.PostDeletedEvent{
+			PostID:       id,
+			AuthorUserID: *post.AuthorUserId,
+			DeletedAt:    time.Now(),
+		}))
+	})
 }
@@ -25,7 +25,7 @@ type PostService interface {
 	GetByID(ctx context.Context, id string) (*model.Post, error)
 	Update(ctx context.Context, id string, text string) error
 	Delete(ctx context.Context, id string) error
-	Feed(ctx context.Context, id string, offset *float32, limit *float32) ([]*model.Post, error)
+	Feed(ctx context.Context, id string, cursor *model.Cursor, limit int) ([]*model.Post, error)
 }
 
 type FriendService interface {
@@ -40,10 +40,15 @@ type DialogService interface {
 	// SendMessage отправляет сообщение в диалог
 	SendMessage(ctx context.Context, fromUserId, toUserId string, text string) error
 	// GetDialogList возвращает список сообщений диалога между двумя пользователями
-	GetDialogList(ctx context.Context, userId1, userId2 string) ([]*model.DialogMessage, error)
+	GetDialogList(ctx context.Context, userId1, userId2 string, cursor *model.Cursor, limit int) ([]*model.DialogMessage, error)
+	// MarkRead отмечает сообщения диалога с peerId прочитанными до messageId включительно.
+	// Возвращает nil, если позиция прочтения не сдвинулась
+	MarkRead(ctx context.Context, userId, peerId, messageId string) (*model.DialogRead, error)
+	// GetReads возвращает позиции прочтения участников диалога
+	GetReads(ctx context.Context, userId1, userId2 string) ([]*model.DialogRead, error)
 }
 
 type FeedService interface {
 	// GetMaterializedFeed получает материализованную ленту пользователя
-	GetMaterializedFeed(ctx context.Context, userID string, offset, limit int) ([]*feedModel.MaterializedFeed, error)
+	GetMaterializedFeed(ctx context.Context, userID string, cursor *model.Cursor, limit int) ([]*feedModel.MaterializedFeed, error)
 }
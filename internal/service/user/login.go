@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"log"
 	"otus-project/internal/model"
 )
 
@@ -11,5 +12,10 @@ func (s *serv) Login(ctx context.Context, dto *model.LoginDto) (*string, error)
 		return nil, err
 	}
 
+	// Время входа учитывается при выборе приоритета материализации ленты
+	if err = s.activityRepository.TouchLogin(ctx, dto.Id); err != nil {
+		log.Printf("Error saving login time of user %s: %v", dto.Id, err)
+	}
+
 	return user, nil
 }
@@ -7,16 +7,19 @@ import (
 )
 
 type serv struct {
-	userRepository repository.UserRepository
-	txManager      db.TxManager
+	userRepository     repository.UserRepository
+	activityRepository repository.ActivityRepository
+	txManager          db.TxManager
 }
 
 func NewService(
 	userRepository repository.UserRepository,
+	activityRepository repository.ActivityRepository,
 	txManager db.TxManager,
 ) service.UserService {
 	return &serv{
-		userRepository: userRepository,
-		txManager:      txManager,
+		userRepository:     userRepository,
+		activityRepository: activityRepository,
+		txManager:          txManager,
 	}
 }
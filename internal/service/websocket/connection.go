@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"otus-project/internal/model"
+	"sort"
+	"sync"
+)
+
+// Connection WebSocket или SSE соединение узла вместе с состоянием, которое меняют
+// горутины соединения и хаб: причиной закрытия, подписками и восстановлением после переподключения
+type Connection struct {
+	model.WebSocketConnection
+
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+
+	subscriptionsMu sync.RWMutex
+	subscriptions   map[string]struct{}
+
+	// Пока соединение не восстановлено (resume), новые сообщения журнала придерживаются,
+	// чтобы не обогнать пропущенные сообщения, которые повторяются клиенту
+	resumeMu      sync.Mutex
+	resumeClaimed bool
+	resumed       bool
+	held          []heldMessage
+	heldOverflow  bool
+}
+
+// heldMessage сообщение журнала, придержанное до восстановления соединения
+type heldMessage struct {
+	eventID int64
+	data    []byte
+}
+
+// NewConnection создает соединение пользователя с буфером отправки на sendBufferSize сообщений,
+// подписанное на канал feed
+func NewConnection(id, userID string, sendBufferSize int) *Connection {
+	return &Connection{
+		WebSocketConnection: model.WebSocketConnection{
+			ID:     id,
+			UserID: userID,
+			Send:   make(chan []byte, sendBufferSize),
+			Done:   make(chan struct{}),
+		},
+		subscriptions: map[string]struct{}{
+			model.WebSocketChannelFeed: {},
+		},
+	}
+}
+
+// Close запрашивает закрытие соединения с кодом закрытия WebSocket и причиной.
+// Возвращает false, если закрытие уже было запрошено: сохраняется первая причина
+func (c *Connection) Close(code int, reason string) bool {
+	closed := false
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.Done)
+		closed = true
+	})
+	return closed
+}
+
+// CloseStatus возвращает код и причину закрытия. Вызывается после закрытия Done
+func (c *Connection) CloseStatus() (int, string) {
+	return c.closeCode, c.closeReason
+}
+
+// subscribe подписывает соединение на канал. Возвращает false, если подписок уже limit
+func (c *Connection) subscribe(channel string, limit int) bool {
+	c.subscriptionsMu.Lock()
+	defer c.subscriptionsMu.Unlock()
+
+	if _, ok := c.subscriptions[channel]; ok {
+		return true
+	}
+	if len(c.subscriptions) >= limit {
+		return false
+	}
+
+	c.subscriptions[channel] = struct{}{}
+	return true
+}
+
+// unsubscribe отписывает соединение от канала
+func (c *Connection) unsubscribe(channel string) {
+	c.subscriptionsMu.Lock()
+	defer c.subscriptionsMu.Unlock()
+
+	delete(c.subscriptions, channel)
+}
+
+// subscribed проверяет подписку на канал
+func (c *Connection) subscribed(channel string) bool {
+	c.subscriptionsMu.RLock()
+	defer c.subscriptionsMu.RUnlock()
+
+	_, ok := c.subscriptions[channel]
+	return ok
+}
+
+// subscriptionList возвращает каналы, на которые подписано соединение, по алфавиту
+func (c *Connection) subscriptionList() []string {
+	c.subscriptionsMu.RLock()
+	defer c.subscriptionsMu.RUnlock()
+
+	channels := make([]string, 0, len(c.subscriptions))
+	for channel := range c.subscriptions {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	return channels
+}
+
+// claimResume отмечает начало восстановления соединения. Возвращает false, если восстановление
+// уже начато: оно выполняется один раз, по номеру из URL, первому сообщению клиента или таймеру
+func (c *Connection) claimResume() bool {
+	c.resumeMu.Lock()
+	defer c.resumeMu.Unlock()
+
+	if c.resumeClaimed {
+		return false
+	}
+	c.resumeClaimed = true
+	return true
+}
+
+// sendOrHold придерживает сообщение журнала, пока соединение не восстановлено, и вызывает send,
+// если уже восстановлено. Придерживается не больше limit сообщений, о потере остальных
+// сообщает finishResume
+func (c *Connection) sendOrHold(eventID int64, data []byte, limit int, send func([]byte)) {
+	c.resumeMu.Lock()
+	defer c.resumeMu.Unlock()
+
+	if c.resumed {
+		send(data)
+		return
+	}
+
+	if len(c.held) >= limit {
+		c.heldOverflow = true
+		return
+	}
+	c.held = append(c.held, heldMessage{eventID: eventID, data: data})
+}
+
+// finishResume завершает восстановление: передает flush придержанные сообщения и признак того,
+// что часть из них не поместилась. flush выполняется под той же блокировкой, что и sendOrHold,
+// поэтому новые сообщения отправляются только после придержанных
+func (c *Connection) finishResume(flush func(held []heldMessage, overflow bool)) {
+	c.resumeMu.Lock()
+	defer c.resumeMu.Unlock()
+
+	flush(c.held, c.heldOverflow)
+	c.resumed = true
+	c.held = nil
+}
+
+// hub соединения узла и каналы основного цикла хаба
+type hub struct {
+	// connections соединения по ID соединения
+	connections map[string]*Connection
+	// userConnections соединения пользователя по ID пользователя и ID соединения
+	userConnections map[string]map[string]*Connection
+	register        chan *Connection
+	unregister      chan *Connection
+	// broadcast сообщения всем соединениям, подписанным на канал сообщения
+	broadcast chan *model.WebSocketMessage
+}
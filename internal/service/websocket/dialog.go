@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/model"
+)
+
+// handleTyping передает собеседнику, что пользователь набирает сообщение. Событие не сохраняется:
+// если собеседник не подключен ни к одному узлу, оно теряется
+func (s *service) handleTyping(ctx context.Context, connection *Connection, id string, payload json.RawMessage) {
+	var request model.WebSocketSubscription
+	if err := json.Unmarshal(payload, &request); err != nil {
+		s.replyError(connection, id, model.WebSocketErrorInvalidFrame, err.Error())
+		return
+	}
+
+	peerID, ok := s.dialogPeer(connection, id, request.Channel)
+	if !ok {
+		return
+	}
+
+	err := s.queueClient.PublishUserMessage(ctx, peerID, &model.WebSocketMessage{
+		Type:    model.WebSocketMessageTypeTyping,
+		Channel: model.WebSocketDialogChannel(connection.UserID),
+		Payload: &model.WebSocketTyping{UserID: connection.UserID},
+	})
+	if err != nil && !errors.Is(err, queue.ErrUnroutable) {
+		log.Printf("Error relaying typing from user %s to %s: %v", connection.UserID, peerID, err)
+		s.replyError(connection, id, model.WebSocketErrorInternal, "failed to relay typing")
+		return
+	}
+
+	s.replyAck(connection, id, request.Channel)
+}
+
+// handleRead сохраняет позицию прочтения диалога. Собеседник и другие соединения пользователя
+// получают read из события dialog.messages_read, поэтому ack не ждет их доставки
+func (s *service) handleRead(ctx context.Context, connection *Connection, id string, payload json.RawMessage) {
+	var request model.WebSocketReadRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		s.replyError(connection, id, model.WebSocketErrorInvalidFrame, err.Error())
+		return
+	}
+
+	peerID, ok := s.dialogPeer(connection, id, request.Channel)
+	if !ok {
+		return
+	}
+
+	_, err := s.dialogService.MarkRead(ctx, connection.UserID, peerID, request.MessageID)
+	switch {
+	case errors.Is(err, model.ErrorDialogMessageNotFound):
+		s.replyError(connection, id, model.WebSocketErrorMessageNotFound, "message not found in dialog")
+		return
+	case err != nil:
+		log.Printf("Error marking dialog of user %s with %s read: %v", connection.UserID, peerID, err)
+		s.replyError(connection, id, model.WebSocketErrorInternal, "failed to save read position")
+		return
+	}
+
+	s.replyAck(connection, id, request.Channel)
+}
+
+// dialogPeer возвращает собеседника из канала диалога. Формат канала проверяет схема,
+// а диалог с самим собой проверяется здесь
+func (s *service) dialogPeer(connection *Connection, id, channel string) (string, bool) {
+	peerID, ok := model.WebSocketDialogPeer(channel)
+	if !ok || peerID == connection.UserID {
+		s.replyError(connection, id, model.WebSocketErrorInvalidChannel, "channel must be a dialog with another user")
+		return "", false
+	}
+
+	return peerID, true
+}
+
+// sendDialogReads отправляет соединению сохраненные позиции прочтения обоих участников диалога
+func (s *service) sendDialogReads(ctx context.Context, connection *Connection, peerID string) {
+	reads, err := s.dialogService.GetReads(ctx, connection.UserID, peerID)
+	if err != nil {
+		log.Printf("Error reading dialog positions of user %s with %s: %v", connection.UserID, peerID, err)
+		return
+	}
+
+	for _, read := range reads {
+		s.reply(connection, model.NewDialogReadWebSocketMessage(read, connection.UserID))
+	}
+}
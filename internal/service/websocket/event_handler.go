@@ -2,33 +2,69 @@ package websocket
 
 import (
 	"context"
+	"errors"
+	"otus-project/internal/client/queue"
 	"otus-project/internal/model"
 )
 
-// EventHandler обработчик событий для WebSocket сервиса
+// EventHandler передает события диалогов в WebSocket соединения участников. Сообщение публикуется
+// по routing key feed.message.{user_id} и доставляется узлам, к которым подключен пользователь
 type EventHandler struct {
-	websocketService WebSocketService
+	queueClient queue.Client
 }
 
-// NewEventHandler создает новый обработчик событий
-func NewEventHandler(websocketService WebSocketService) *EventHandler {
+// NewEventHandler создает обработчик событий диалогов
+func NewEventHandler(queueClient queue.Client) *EventHandler {
 	return &EventHandler{
-		websocketService: websocketService,
+		queueClient: queueClient,
 	}
 }
 
-// HandlePostCreated обрабатывает событие создания поста
-func (h *EventHandler) HandlePostCreated(ctx context.Context, payload interface{}) error {
-	event, ok := payload.(*model.PostCreatedEvent)
-	if !ok {
-		return nil // Игнорируем неправильный тип события
+// HandleDialogMessageSent отправляет message.new получателю и другим соединениям отправителя.
+// При повторной обработке события сообщение может прийти дважды, клиент отсеивает его по message_id
+func (h *EventHandler) HandleDialogMessageSent(ctx context.Context, _ *model.Event, event *model.DialogMessageSentEvent) error {
+	message := &model.WebSocketDialogMessage{
+		MessageID:  event.MessageID,
+		FromUserID: event.FromUserID,
+		ToUserID:   event.ToUserID,
+		Text:       event.Text,
+		CreatedAt:  event.CreatedAt,
 	}
 
-	wsPost := &model.WebSocketPost{
-		PostID:       event.PostID,
-		PostText:     event.PostText,
-		AuthorUserID: event.AuthorUserID,
+	for _, userID := range []string{event.ToUserID, event.FromUserID} {
+		if err := h.publish(ctx, userID, model.NewDialogWebSocketMessage(message, userID)); err != nil {
+			return err
+		}
 	}
 
-	return h.websocketService.BroadcastPost(ctx, wsPost)
+	return nil
+}
+
+// HandleDialogMessagesRead отправляет read собеседнику и другим соединениям прочитавшего пользователя
+func (h *EventHandler) HandleDialogMessagesRead(ctx context.Context, _ *model.Event, event *model.DialogMessagesReadEvent) error {
+	read := &model.DialogRead{
+		UserID:           event.UserID,
+		PeerID:           event.PeerID,
+		MessageID:        event.MessageID,
+		MessageCreatedAt: event.MessageCreatedAt,
+		ReadAt:           event.ReadAt,
+	}
+
+	for _, userID := range []string{event.PeerID, event.UserID} {
+		if err := h.publish(ctx, userID, model.NewDialogReadWebSocketMessage(read, userID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publish публикует сообщение пользователю. Если пользователь не подключен, сообщение не нужно:
+// сообщения и позиции прочтения он получит через REST API и при подписке на диалог
+func (h *EventHandler) publish(ctx context.Context, userID string, message *model.WebSocketMessage) error {
+	if err := h.queueClient.PublishUserMessage(ctx, userID, message); err != nil && !errors.Is(err, queue.ErrUnroutable) {
+		return err
+	}
+
+	return nil
 }
@@ -4,38 +4,92 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"otus-project/docs"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/config"
+	"otus-project/internal/metric"
 	"otus-project/internal/model"
+	"otus-project/internal/repository"
+	"otus-project/internal/service/dialog"
 	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// onlineTTL время жизни отметки онлайн; пока соединение открыто, она продлевается
+	// каждые onlineRefreshInterval, поэтому после падения узла пользователь перестает
+	// считаться онлайн не позже чем через onlineTTL
+	onlineTTL             = 2 * time.Minute
+	onlineRefreshInterval = time.Minute
 )
 
+// resyncMessage заменяет сообщения, отброшенные политикой coalesce
+var resyncMessage = []byte(`{"type":"` + model.WebSocketMessageTypeResync + `","payload":null}`)
+
 type service struct {
-	hub    *model.WebSocketHub
+	hub                *hub
+	activityRepository repository.ActivityRepository
+	eventLog           repository.EventLogRepository
+	dialogService      dialog.DialogService
+	queueClient        queue.Client
+	router             *router
+	presence           *presence
+	slowConsumerPolicy string
+	eventLogSize       int
+	sendBufferSize     int
+	validator          *frameValidator
+	// mu защищает индексы хаба. Отправка идет под RLock, поэтому соединения удаляются
+	// и их каналы Send закрываются только в runHub под Lock
 	mu     sync.RWMutex
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
 // NewService создает новый WebSocket сервис
-func NewService() WebSocketService {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	hub := &model.WebSocketHub{
-		Connections: make(map[string]*model.WebSocketConnection),
-		Register:    make(chan *model.WebSocketConnection),
-		Unregister:  make(chan *model.WebSocketConnection),
-		Broadcast:   make(chan *model.WebSocketPost),
+func NewService(
+	cfg config.WebSocketConfig,
+	activityRepository repository.ActivityRepository,
+	eventLog repository.EventLogRepository,
+	registry repository.ConnectionRegistryRepository,
+	dialogService dialog.DialogService,
+	queueClient queue.Client,
+) (WebSocketService, error) {
+	validator, err := newFrameValidator(docs.AsyncAPI)
+	if err != nil {
+		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &service{
-		hub:    hub,
-		ctx:    ctx,
-		cancel: cancel,
-	}
+		hub: &hub{
+			connections:     make(map[string]*Connection),
+			userConnections: make(map[string]map[string]*Connection),
+			register:        make(chan *Connection),
+			unregister:      make(chan *Connection),
+			broadcast:       make(chan *model.WebSocketMessage),
+		},
+		activityRepository: activityRepository,
+		eventLog:           eventLog,
+		dialogService:      dialogService,
+		queueClient:        queueClient,
+		router:             newRouter(cfg.NodeID(), queueClient, registry),
+		presence:           newPresence(),
+		slowConsumerPolicy: cfg.SlowConsumerPolicy(),
+		eventLogSize:       cfg.EventLogSize(),
+		sendBufferSize:     cfg.SendBufferSize(),
+		validator:          validator,
+		ctx:                ctx,
+		cancel:             cancel,
+	}, nil
 }
 
 // StartHub запускает WebSocket хаб
 func (s *service) StartHub(ctx context.Context) error {
 	go s.runHub()
+	go s.runPresence()
 	log.Println("WebSocket hub started")
 	return nil
 }
@@ -43,74 +97,254 @@ func (s *service) StartHub(ctx context.Context) error {
 // StopHub останавливает WebSocket хаб
 func (s *service) StopHub(ctx context.Context) error {
 	s.cancel()
+	s.router.releaseAll(ctx)
 	log.Println("WebSocket hub stopped")
 	return nil
 }
 
-// BroadcastPost отправляет сообщение о новом посте всем подписчикам
-func (s *service) BroadcastPost(ctx context.Context, post *model.WebSocketPost) error {
-	s.hub.Broadcast <- post
-	return nil
+// BroadcastAnnouncement отправляет объявление администратора всем соединениям узла,
+// подписанным на канал announcements
+func (s *service) BroadcastAnnouncement(ctx context.Context, announcement *model.Announcement) error {
+	message := &model.WebSocketMessage{
+		Type:    model.WebSocketMessageTypeAnnouncement,
+		Channel: model.WebSocketChannelAnnouncements,
+		Payload: announcement,
+	}
+
+	select {
+	case s.hub.broadcast <- message:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
 }
 
-// GetHub возвращает WebSocket хаб
-func (s *service) GetHub() *model.WebSocketHub {
-	return s.hub
+// RegisterConnection направляет события ленты пользователя на этот узел и регистрирует соединение в хабе.
+// Возвращается после создания привязки, поэтому восстановление соединения не пропустит события.
+// Если хаб остановлен, соединение не регистрируется и привязка снимается
+func (s *service) RegisterConnection(ctx context.Context, connection *Connection) error {
+	s.router.acquire(ctx, connection.UserID)
+
+	select {
+	case s.hub.register <- connection:
+		return nil
+	case <-ctx.Done():
+		s.router.release(context.WithoutCancel(ctx), connection.UserID)
+		return ctx.Err()
+	case <-s.ctx.Done():
+		s.router.release(ctx, connection.UserID)
+		return s.ctx.Err()
+	}
+}
+
+// UnregisterConnection снимает соединение с регистрации в хабе. После остановки хаба ничего не делает
+func (s *service) UnregisterConnection(ctx context.Context, connection *Connection) {
+	select {
+	case s.hub.unregister <- connection:
+	case <-ctx.Done():
+	case <-s.ctx.Done():
+	}
 }
 
 // SendPostToUser отправляет сообщение о новом посте конкретному пользователю
 func (s *service) SendPostToUser(ctx context.Context, userID string, post *model.WebSocketPost) error {
-	message := model.WebSocketMessage{
-		Type:    "post",
+	return s.SendMessageToUser(ctx, userID, &model.WebSocketMessage{
+		Type:    model.WebSocketMessageTypePost,
+		Channel: model.WebSocketChannelFeed,
 		Payload: post,
-	}
+	})
+}
 
+// SendMessageToUser отправляет произвольное сообщение во все соединения пользователя,
+// подписанные на канал сообщения
+func (s *service) SendMessageToUser(ctx context.Context, userID string, message *model.WebSocketMessage) error {
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
 
+	// Новое сообщение диалога получают и соединения, не подписанные на диалог, но подписанные на уведомления
+	var notificationBytes []byte
+	if message.Type == model.WebSocketMessageTypeMessageNew {
+		notification := *message
+		notification.Channel = model.WebSocketChannelNotifications
+		if notificationBytes, err = json.Marshal(&notification); err != nil {
+			return err
+		}
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if conn, ok := s.hub.Connections[userID]; ok {
+	for _, conn := range s.hub.userConnections[userID] {
+		data := messageBytes
+		if message.Channel != "" && !conn.subscribed(message.Channel) {
+			if notificationBytes == nil || !conn.subscribed(model.WebSocketChannelNotifications) {
+				continue
+			}
+			data = notificationBytes
+		}
+
+		// Сообщения журнала не должны обгонять пропущенные, которые повторяются при восстановлении
+		if message.EventID == 0 {
+			s.deliver(conn, data)
+			continue
+		}
+		conn.sendOrHold(message.EventID, data, s.sendBufferSize, func(held []byte) {
+			s.deliver(conn, held)
+		})
+	}
+	return nil
+}
+
+// deliver кладет сообщение в буфер соединения, а если буфер заполнен, применяет политику
+// медленного клиента. Вызывается под s.mu.RLock, поэтому Send не может быть закрыт
+func (s *service) deliver(connection *Connection, message []byte) {
+	select {
+	case connection.Send <- message:
+		return
+	default:
+	}
+
+	switch s.slowConsumerPolicy {
+	case config.SlowConsumerDropOldest:
+		s.pushDroppingOldest(connection, message)
+
+	case config.SlowConsumerCoalesce:
+		// Неотправленные сообщения вместе с новым заменяются одним resync
+		dropped := 1
+	drain:
+		for {
+			select {
+			case <-connection.Send:
+				dropped++
+			default:
+				break drain
+			}
+		}
+		metric.AddWebSocketDroppedMessages(s.slowConsumerPolicy, dropped)
+		s.pushDroppingOldest(connection, resyncMessage)
+
+	default:
+		metric.AddWebSocketDroppedMessages(s.slowConsumerPolicy, 1)
+		if connection.Close(websocket.ClosePolicyViolation, model.WebSocketCloseReasonSlowConsumer) {
+			log.Printf("WebSocket connection %s (user %s) is too slow, disconnecting", connection.ID, connection.UserID)
+		}
+	}
+}
+
+// pushDroppingOldest кладет сообщение в буфер, отбрасывая самые старые сообщения, пока не освободится место
+func (s *service) pushDroppingOldest(connection *Connection, message []byte) {
+	for {
 		select {
-		case conn.Send <- messageBytes:
+		case connection.Send <- message:
+			return
+		default:
+		}
+
+		select {
+		case <-connection.Send:
+			metric.AddWebSocketDroppedMessages(s.slowConsumerPolicy, 1)
 		default:
-			close(conn.Send)
-			delete(s.hub.Connections, conn.ID)
 		}
 	}
-	return nil
 }
 
-// runHub запускает основной цикл хаба
+// addConnection добавляет соединение в хаб. Вызывается под s.mu
+func (s *service) addConnection(connection *Connection) {
+	s.hub.connections[connection.ID] = connection
+
+	userConnections, ok := s.hub.userConnections[connection.UserID]
+	if !ok {
+		userConnections = make(map[string]*Connection)
+		s.hub.userConnections[connection.UserID] = userConnections
+	}
+	userConnections[connection.ID] = connection
+}
+
+// removeConnection удаляет соединение из хаба вместе с пустым индексом пользователя. Вызывается под s.mu
+func (s *service) removeConnection(connection *Connection) {
+	delete(s.hub.connections, connection.ID)
+
+	userConnections := s.hub.userConnections[connection.UserID]
+	delete(userConnections, connection.ID)
+	if len(userConnections) == 0 {
+		delete(s.hub.userConnections, connection.UserID)
+	}
+}
+
+// markOnline отмечает пользователей онлайн для приоритизации материализации ленты
+func (s *service) markOnline(ctx context.Context, userIDs ...string) {
+	for _, userID := range userIDs {
+		if err := s.activityRepository.SetOnline(ctx, userID, onlineTTL); err != nil {
+			log.Printf("Error marking user %s online: %v", userID, err)
+		}
+	}
+}
+
+// refreshOnline продлевает отметку онлайн для всех открытых соединений
+func (s *service) refreshOnline(ctx context.Context) {
+	s.mu.RLock()
+	userIDs := make([]string, 0, len(s.hub.userConnections))
+	for userID := range s.hub.userConnections {
+		userIDs = append(userIDs, userID)
+	}
+	s.mu.RUnlock()
+
+	s.markOnline(ctx, userIDs...)
+}
+
+// runHub запускает основной цикл хаба. Цикл меняет только индексы соединений в памяти,
+// а обращения к Redis и брокеру передает в runPresence
 func (s *service) runHub() {
 	for {
 		select {
 		case <-s.ctx.Done():
 			return
-		case connection := <-s.hub.Register:
+
+		case connection := <-s.hub.register:
 			s.mu.Lock()
-			s.hub.Connections[connection.ID] = connection
+			s.addConnection(connection)
 			s.mu.Unlock()
-			log.Printf("WebSocket connection registered: %s", connection.ID)
 
-		case connection := <-s.hub.Unregister:
+			userID := connection.UserID
+			s.presence.enqueue(func(ctx context.Context) {
+				s.markOnline(ctx, userID)
+			})
+			log.Printf("WebSocket connection registered: %s (user %s)", connection.ID, connection.UserID)
+
+		case connection := <-s.hub.unregister:
+			// Соединение снимается с регистрации дважды: при завершении чтения и записи
 			s.mu.Lock()
-			if _, ok := s.hub.Connections[connection.ID]; ok {
-				delete(s.hub.Connections, connection.ID)
+			_, registered := s.hub.connections[connection.ID]
+			if registered {
+				s.removeConnection(connection)
 				close(connection.Send)
 			}
+			_, online := s.hub.userConnections[connection.UserID]
 			s.mu.Unlock()
-			log.Printf("WebSocket connection unregistered: %s", connection.ID)
 
-		case post := <-s.hub.Broadcast:
-			// Создаем сообщение согласно AsyncAPI спецификации
-			message := model.WebSocketMessage{
-				Type:    "post",
-				Payload: post,
+			if registered {
+				userID := connection.UserID
+				s.presence.enqueue(func(ctx context.Context) {
+					// Пользователь остается онлайн, пока открыто хотя бы одно его соединение
+					if !online {
+						if err := s.activityRepository.SetOffline(ctx, userID); err != nil {
+							log.Printf("Error marking user %s offline: %v", userID, err)
+						}
+					}
+					s.router.release(ctx, userID)
+				})
+
+				// Обе горутины соединения запрашивают закрытие до снятия с регистрации,
+				// поэтому причина закрытия уже известна
+				_, reason := connection.CloseStatus()
+				metric.IncWebSocketDisconnects(reason)
+				log.Printf("WebSocket connection unregistered: %s (user %s), reason: %s", connection.ID, connection.UserID, reason)
 			}
 
+		case message := <-s.hub.broadcast:
 			messageBytes, err := json.Marshal(message)
 			if err != nil {
 				log.Printf("Error marshaling WebSocket message: %v", err)
@@ -118,13 +352,9 @@ func (s *service) runHub() {
 			}
 
 			s.mu.RLock()
-			for _, connection := range s.hub.Connections {
-				select {
-				case connection.Send <- messageBytes:
-				default:
-					// Если канал заблокирован, закрываем соединение
-					close(connection.Send)
-					delete(s.hub.Connections, connection.ID)
+			for _, connection := range s.hub.connections {
+				if connection.subscribed(message.Channel) {
+					s.deliver(connection, messageBytes)
 				}
 			}
 			s.mu.RUnlock()
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// presence очередь сетевых действий хаба: отметок онлайн и офлайн в хранилище активности
+// и снятия привязок событий пользователя с узла. Хаб только ставит действия в очередь,
+// поэтому медленный Redis или брокер не задерживают регистрацию соединений и рассылку.
+// Очередь не ограничена, чтобы хаб никогда не ждал на ней
+type presence struct {
+	mu      sync.Mutex
+	pending []func(ctx context.Context)
+	notify  chan struct{}
+}
+
+func newPresence() *presence {
+	return &presence{notify: make(chan struct{}, 1)}
+}
+
+// enqueue ставит действие в очередь, не блокируя вызывающего
+func (p *presence) enqueue(action func(ctx context.Context)) {
+	p.mu.Lock()
+	p.pending = append(p.pending, action)
+	p.mu.Unlock()
+
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take забирает из очереди все действия в порядке постановки
+func (p *presence) take() []func(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	actions := p.pending
+	p.pending = nil
+	return actions
+}
+
+// runPresence выполняет действия очереди presence по одному в порядке постановки и периодически
+// продлевает отметки онлайн и отметки узла в реестре, пока хаб не остановлен. Порядок важен:
+// отметка офлайн после закрытия последнего соединения пользователя не обгонит отметку онлайн для нового
+func (s *service) runPresence() {
+	ticker := time.NewTicker(onlineRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshOnline(s.ctx)
+			s.router.refresh(s.ctx)
+		case <-s.presence.notify:
+			for _, action := range s.presence.take() {
+				if s.ctx.Err() != nil {
+					return
+				}
+				action(s.ctx)
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"otus-project/internal/model"
+	"strings"
+)
+
+// maxSubscriptions сколько каналов может быть у одного соединения
+const maxSubscriptions = 100
+
+// HandleClientMessage проверяет сообщение клиента по AsyncAPI спецификации, выполняет его
+// и отвечает ack или error с тем же id
+func (s *service) HandleClientMessage(ctx context.Context, connection *Connection, data []byte) {
+	var frame map[string]interface{}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		s.replyError(connection, "", model.WebSocketErrorInvalidJSON, err.Error())
+		return
+	}
+
+	// id возвращается и в ответе на некорректное сообщение, если его удалось прочитать
+	id, _ := frame["id"].(string)
+
+	// Восстановление соединения возможно только первым сообщением. Любое другое первое сообщение
+	// завершает ожидание восстановления без повтора пропущенных сообщений
+	if messageType, _ := frame["type"].(string); messageType != model.WebSocketMessageTypeResume {
+		s.Resume(ctx, connection, nil)
+	}
+
+	if err := s.validator.validate(frame); err != nil {
+		s.replyError(connection, id, model.WebSocketErrorInvalidFrame, err.Error())
+		return
+	}
+
+	var message struct {
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		s.replyError(connection, id, model.WebSocketErrorInvalidFrame, err.Error())
+		return
+	}
+
+	switch message.Type {
+	case model.WebSocketMessageTypeResume:
+		s.handleResume(ctx, connection, id, message.Payload)
+	case model.WebSocketMessageTypeTyping:
+		s.handleTyping(ctx, connection, id, message.Payload)
+	case model.WebSocketMessageTypeRead:
+		s.handleRead(ctx, connection, id, message.Payload)
+	default:
+		s.handleSubscription(ctx, connection, id, message.Type, message.Payload)
+	}
+}
+
+// handleSubscription подписывает соединение на канал или отписывает от него
+func (s *service) handleSubscription(ctx context.Context, connection *Connection, id, messageType string, payload json.RawMessage) {
+	var request model.WebSocketSubscription
+	if err := json.Unmarshal(payload, &request); err != nil {
+		s.replyError(connection, id, model.WebSocketErrorInvalidFrame, err.Error())
+		return
+	}
+
+	channel := request.Channel
+	switch messageType {
+	case model.WebSocketMessageTypeSubscribe:
+		// Схема проверяет формат канала, а собственный диалог проверяется здесь
+		if strings.TrimPrefix(channel, model.WebSocketChannelDialogPrefix) == connection.UserID {
+			s.replyError(connection, id, model.WebSocketErrorInvalidChannel, "cannot subscribe to a dialog with yourself")
+			return
+		}
+		if !connection.subscribe(channel, maxSubscriptions) {
+			s.replyError(connection, id, model.WebSocketErrorTooManySubscriptions, "subscription limit reached")
+			return
+		}
+
+	case model.WebSocketMessageTypeUnsubscribe:
+		connection.unsubscribe(channel)
+	}
+
+	s.replyAck(connection, id, channel)
+
+	// После подписки на диалог клиент получает позиции прочтения, чтобы показать непрочитанные
+	if peerID, ok := model.WebSocketDialogPeer(channel); ok && messageType == model.WebSocketMessageTypeSubscribe {
+		s.sendDialogReads(ctx, connection, peerID)
+	}
+}
+
+// handleResume повторяет пропущенные сообщения. Ack отправляется до повторенных сообщений
+func (s *service) handleResume(ctx context.Context, connection *Connection, id string, payload json.RawMessage) {
+	var request model.WebSocketResume
+	if err := json.Unmarshal(payload, &request); err != nil {
+		s.replyError(connection, id, model.WebSocketErrorInvalidFrame, err.Error())
+		return
+	}
+
+	if !connection.claimResume() {
+		s.replyError(connection, id, model.WebSocketErrorResumeNotAllowed,
+			"resume must be the first message and is not allowed after last_event_id in URL")
+		return
+	}
+
+	s.replyAck(connection, id, "")
+	s.resume(ctx, connection, &request.LastEventID)
+}
+
+// replyAck подтверждает запрос клиента и сообщает текущие подписки соединения
+func (s *service) replyAck(connection *Connection, id, channel string) {
+	s.reply(connection, &model.WebSocketMessage{
+		Type: model.WebSocketMessageTypeAck,
+		ID:   id,
+		Payload: &model.WebSocketAck{
+			Channel:       channel,
+			Subscriptions: connection.subscriptionList(),
+		},
+	})
+}
+
+// replyError отвечает клиенту структурированной ошибкой
+func (s *service) replyError(connection *Connection, id, code, message string) {
+	s.reply(connection, &model.WebSocketMessage{
+		Type: model.WebSocketMessageTypeError,
+		ID:   id,
+		Payload: &model.WebSocketError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// reply отправляет ответ в соединение, от которого пришел запрос, независимо от его подписок
+func (s *service) reply(connection *Connection, message *model.WebSocketMessage) {
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling WebSocket reply: %v", err)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Send закрывается только после снятия с регистрации, а ему всегда предшествует закрытие Done.
+	// Соединение может быть еще не зарегистрировано в хабе, но тогда Send открыт
+	select {
+	case <-connection.Done:
+		return
+	default:
+	}
+
+	s.deliver(connection, messageBytes)
+}
@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"otus-project/internal/model"
+)
+
+// Resume восстанавливает соединение после переподключения: повторяет сообщения журнала с номерами
+// больше lastEventID и отпускает сообщения, придержанные с момента подключения. Если lastEventID
+// не задан, придержанные сообщения просто отправляются. Если пропущенные сообщения не сохранились
+// в журнале, клиенту отправляется resync. Возвращает false, если соединение уже восстановлено
+func (s *service) Resume(ctx context.Context, connection *Connection, lastEventID *int64) bool {
+	if !connection.claimResume() {
+		return false
+	}
+
+	s.resume(ctx, connection, lastEventID)
+	return true
+}
+
+// resume выполняет восстановление соединения, начатое claimResume
+func (s *service) resume(ctx context.Context, connection *Connection, lastEventID *int64) {
+	var (
+		replayed []*model.WebSocketMessage
+		resync   bool
+		lastID   int64
+	)
+
+	if lastEventID != nil {
+		lastID = *lastEventID
+
+		messages, complete, err := s.eventLog.Since(ctx, connection.UserID, lastID, s.eventLogSize)
+		switch {
+		case err != nil:
+			log.Printf("Error reading event log of user %s: %v", connection.UserID, err)
+			resync = true
+		case !complete:
+			resync = true
+		default:
+			replayed = messages
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Send закрывается только после закрытия Done, см. reply
+	closed := false
+	select {
+	case <-connection.Done:
+		closed = true
+	default:
+	}
+
+	if !closed {
+		if resync {
+			s.deliver(connection, resyncMessage)
+		}
+
+		for _, message := range replayed {
+			// Подписки проверяются при повторе так же, как при обычной доставке
+			if message.Channel != "" && !connection.subscribed(message.Channel) {
+				lastID = message.EventID
+				continue
+			}
+
+			messageBytes, err := json.Marshal(message)
+			if err != nil {
+				log.Printf("Error marshaling replayed WebSocket message: %v", err)
+				continue
+			}
+
+			s.deliver(connection, messageBytes)
+			lastID = message.EventID
+		}
+	}
+
+	connection.finishResume(func(held []heldMessage, overflow bool) {
+		if closed {
+			return
+		}
+
+		// Придержанные сообщения не поместились: клиенту нужно перечитать ленту
+		if overflow && !resync {
+			s.deliver(connection, resyncMessage)
+		}
+
+		for _, message := range held {
+			// Сообщение уже повторено из журнала
+			if message.eventID <= lastID {
+				continue
+			}
+			s.deliver(connection, message.data)
+		}
+	})
+
+	if len(replayed) > 0 || resync {
+		log.Printf("WebSocket connection %s (user %s) resumed: replayed %d events, resync %t",
+			connection.ID, connection.UserID, len(replayed), resync)
+	}
+}
@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"otus-project/internal/client/queue"
+	"otus-project/internal/repository"
+	"sync"
+)
+
+// router направляет события ленты на узел, где подключен пользователь: привязывает очередь
+// WebSocket событий узла к событиям пользователя и отмечает узел в реестре соединений.
+// Привязка и отметка создаются с первым соединением пользователя на узле и удаляются с последним
+type router struct {
+	nodeID      string
+	queueClient queue.Client
+	registry    repository.ConnectionRegistryRepository
+
+	// mu упорядочивает привязки пользователя: отвязка после закрытия последнего соединения
+	// не может обогнать привязку для нового
+	mu sync.Mutex
+	// connections количество соединений пользователя на узле
+	connections map[string]int
+}
+
+func newRouter(nodeID string, queueClient queue.Client, registry repository.ConnectionRegistryRepository) *router {
+	return &router{
+		nodeID:      nodeID,
+		queueClient: queueClient,
+		registry:    registry,
+		connections: make(map[string]int),
+	}
+}
+
+// acquire учитывает новое соединение пользователя. Сначала создается привязка, затем отметка
+// в реестре: публикация, увидевшая отметку, уже попадет в очередь узла. Ошибки только логируются:
+// привязка восстановится при переобъявлении очереди, а отметка - при следующем продлении
+func (r *router) acquire(ctx context.Context, userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.connections[userID]++
+	if r.connections[userID] > 1 {
+		return
+	}
+
+	if err := r.queueClient.BindUserEvents(ctx, userID); err != nil {
+		log.Printf("Error binding events of user %s: %v", userID, err)
+	}
+	if err := r.registry.Register(ctx, r.nodeID, []string{userID}, onlineTTL); err != nil {
+		log.Printf("Error registering user %s on node %s: %v", userID, r.nodeID, err)
+	}
+}
+
+// release учитывает закрытие соединения пользователя. Отметка удаляется раньше привязки,
+// чтобы новые события не публиковались в очередь, которая их уже не получит
+func (r *router) release(ctx context.Context, userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.connections[userID]--
+	if r.connections[userID] > 0 {
+		return
+	}
+	delete(r.connections, userID)
+
+	if err := r.registry.Unregister(ctx, r.nodeID, userID); err != nil {
+		log.Printf("Error unregistering user %s on node %s: %v", userID, r.nodeID, err)
+	}
+	if err := r.queueClient.UnbindUserEvents(ctx, userID); err != nil {
+		log.Printf("Error unbinding events of user %s: %v", userID, err)
+	}
+}
+
+// refresh продлевает отметки всех пользователей узла. Если узел упадет, его отметки
+// перестанут учитываться не позже чем через onlineTTL. Отметка пользователя, отключившегося
+// во время продления, тоже остается до onlineTTL, а события для него брокер вернет как немаршрутизируемые
+func (r *router) refresh(ctx context.Context) {
+	r.mu.Lock()
+	userIDs := make([]string, 0, len(r.connections))
+	for userID := range r.connections {
+		userIDs = append(userIDs, userID)
+	}
+	r.mu.Unlock()
+
+	if len(userIDs) == 0 {
+		return
+	}
+
+	if err := r.registry.Register(ctx, r.nodeID, userIDs, onlineTTL); err != nil {
+		log.Printf("Error refreshing %d users on node %s: %v", len(userIDs), r.nodeID, err)
+	}
+}
+
+// releaseAll снимает отметки узла при остановке, чтобы события не публиковались для узла,
+// который их уже не получит
+func (r *router) releaseAll(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for userID := range r.connections {
+		if err := r.registry.Unregister(ctx, r.nodeID, userID); err != nil {
+			log.Printf("Error unregistering user %s on node %s: %v", userID, r.nodeID, err)
+		}
+	}
+	r.connections = make(map[string]int)
+}
@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	// clientFrameSchema схема конверта сообщения клиента в AsyncAPI спецификации
+	clientFrameSchema = "ClientFrame"
+	// endpointChannel канал AsyncAPI, сообщения клиента в котором проверяются
+	endpointChannel = "/post/feed/posted"
+)
+
+// asyncAPISpec часть AsyncAPI спецификации, нужная для проверки сообщений клиента
+type asyncAPISpec struct {
+	Channels map[string]struct {
+		Publish *struct {
+			Message struct {
+				OneOf []struct {
+					Ref string `json:"$ref"`
+				} `json:"oneOf"`
+			} `json:"message"`
+		} `json:"publish"`
+	} `json:"channels"`
+	Components struct {
+		Schemas  map[string]json.RawMessage `json:"schemas"`
+		Messages map[string]struct {
+			MessageID string          `json:"messageId"`
+			Payload   json.RawMessage `json:"payload"`
+		} `json:"messages"`
+	} `json:"components"`
+}
+
+// frameValidator проверяет сообщения клиента по схемам AsyncAPI спецификации
+type frameValidator struct {
+	frame *openapi3.Schema
+	// payloads схемы payload по типу сообщения
+	payloads map[string]*openapi3.Schema
+}
+
+// newFrameValidator разбирает схемы сообщений, которые клиент публикует в канал endpointChannel.
+// JSON Schema из AsyncAPI совместима со схемами OpenAPI, поэтому схемы собираются в OpenAPI
+// документ, и ссылки #/components/schemas/... разрешаются загрузчиком kin-openapi
+func newFrameValidator(spec []byte) (*frameValidator, error) {
+	var asyncAPI asyncAPISpec
+	if err := json.Unmarshal(spec, &asyncAPI); err != nil {
+		return nil, fmt.Errorf("failed to parse asyncapi spec: %w", err)
+	}
+
+	channel, ok := asyncAPI.Channels[endpointChannel]
+	if !ok || channel.Publish == nil {
+		return nil, fmt.Errorf("asyncapi spec has no publish operation for channel %s", endpointChannel)
+	}
+
+	schemas := make(map[string]json.RawMessage, len(asyncAPI.Components.Schemas)+len(channel.Publish.Message.OneOf))
+	for name, schema := range asyncAPI.Components.Schemas {
+		schemas[name] = schema
+	}
+
+	// Схема payload каждого сообщения клиента добавляется под именем message.<Name>
+	payloadSchemas := make(map[string]string, len(channel.Publish.Message.OneOf))
+	for _, ref := range channel.Publish.Message.OneOf {
+		name := strings.TrimPrefix(ref.Ref, "#/components/messages/")
+		message, ok := asyncAPI.Components.Messages[name]
+		if !ok {
+			return nil, fmt.Errorf("asyncapi spec has no message %s", ref.Ref)
+		}
+
+		schemaName := "message." + name
+		schemas[schemaName] = message.Payload
+		payloadSchemas[message.MessageID] = schemaName
+	}
+
+	doc, err := json.Marshal(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": "asyncapi", "version": "1.0.0"},
+		"paths":   map[string]interface{}{},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := openapi3.NewLoader().LoadFromData(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load asyncapi schemas: %w", err)
+	}
+
+	frame, ok := loaded.Components.Schemas[clientFrameSchema]
+	if !ok {
+		return nil, fmt.Errorf("asyncapi spec has no schema %s", clientFrameSchema)
+	}
+
+	v := &frameValidator{
+		frame:    frame.Value,
+		payloads: make(map[string]*openapi3.Schema, len(payloadSchemas)),
+	}
+	for messageID, schemaName := range payloadSchemas {
+		v.payloads[messageID] = loaded.Components.Schemas[schemaName].Value
+	}
+
+	return v, nil
+}
+
+// validate проверяет конверт сообщения и payload по схеме его типа
+func (v *frameValidator) validate(frame map[string]interface{}) error {
+	if err := v.frame.VisitJSON(frame); err != nil {
+		return schemaError(err)
+	}
+
+	messageType, _ := frame["type"].(string)
+	payload, ok := v.payloads[messageType]
+	if !ok {
+		return fmt.Errorf("unsupported message type %q", messageType)
+	}
+
+	if err := payload.VisitJSON(frame["payload"]); err != nil {
+		return fmt.Errorf("payload: %w", schemaError(err))
+	}
+
+	return nil
+}
+
+// schemaError сокращает ошибку kin-openapi до пути к полю и причины
+func schemaError(err error) error {
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return err
+	}
+
+	path := strings.Join(schemaErr.JSONPointer(), "/")
+	if path == "" {
+		return errors.New(schemaErr.Reason)
+	}
+	return fmt.Errorf("/%s: %s", path, schemaErr.Reason)
+}
@@ -13,12 +13,26 @@ type WebSocketService interface {
 	// StopHub останавливает WebSocket хаб
 	StopHub(ctx context.Context) error
 
-	// BroadcastPost отправляет сообщение о новом посте всем подписчикам
-	BroadcastPost(ctx context.Context, post *model.WebSocketPost) error
+	// BroadcastAnnouncement отправляет объявление администратора соединениям узла, подписанным на канал announcements
+	BroadcastAnnouncement(ctx context.Context, announcement *model.Announcement) error
 
-	// GetHub возвращает WebSocket хаб
-	GetHub() *model.WebSocketHub
+	// RegisterConnection направляет события ленты пользователя на этот узел и регистрирует соединение в хабе.
+	// Возвращает ошибку, если хаб остановлен
+	RegisterConnection(ctx context.Context, connection *Connection) error
+
+	// UnregisterConnection снимает соединение с регистрации в хабе
+	UnregisterConnection(ctx context.Context, connection *Connection)
 
 	// SendPostToUser отправляет сообщение о новом посте конкретному пользователю
 	SendPostToUser(ctx context.Context, userID string, post *model.WebSocketPost) error
+
+	// SendMessageToUser отправляет произвольное сообщение соединениям пользователя, подписанным на канал сообщения
+	SendMessageToUser(ctx context.Context, userID string, message *model.WebSocketMessage) error
+
+	// HandleClientMessage обрабатывает сообщение, полученное от клиента через соединение
+	HandleClientMessage(ctx context.Context, connection *Connection, data []byte)
+
+	// Resume повторяет соединению сообщения журнала после lastEventID (nil - без повтора) и начинает
+	// обычную доставку. Возвращает false, если соединение уже восстановлено
+	Resume(ctx context.Context, connection *Connection, lastEventID *int64) bool
 }
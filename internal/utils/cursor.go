@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"otus-project/internal/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EncodeCursor кодирует курсор в непрозрачную для клиента строку
+func EncodeCursor(cursor *model.Cursor) string {
+	raw := fmt.Sprintf("%d|%s", cursor.CreatedAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor разбирает курсор, полученный от клиента. Пустая строка означает первую страницу.
+func DecodeCursor(value string) (*model.Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, model.ErrorInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, model.ErrorInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, model.ErrorInvalidCursor
+	}
+
+	// Курсор указывает на запись с UUID, иначе запрос к базе упадет на приведении типа
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, model.ErrorInvalidCursor
+	}
+
+	return &model.Cursor{
+		// Время восстанавливаем в UTC: так же pgx возвращает колонки timestamp без зоны
+		CreatedAt: time.Unix(0, nanos).UTC(),
+		ID:        id.String(),
+	}, nil
+}
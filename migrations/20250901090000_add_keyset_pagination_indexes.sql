@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Индексы под keyset-пагинацию по (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_materialized_feeds_user_created_post ON materialized_feeds (user_id, created_at DESC, post_id DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_author_created_id ON posts (author_user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS dialog_messages_dialog_created_id_idx ON dialog_messages (dialog_key, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_materialized_feeds_user_created_post;
+DROP INDEX IF EXISTS idx_posts_author_created_id;
+DROP INDEX IF EXISTS dialog_messages_dialog_created_id_idx;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Текст поста больше не копируется в ленту, а подтягивается из posts при чтении
+ALTER TABLE materialized_feeds DROP COLUMN IF EXISTS post_text;
+
+-- Индекс для очистки ленты по возрасту записей есть (idx_materialized_feeds_created_at),
+-- для очистки по количеству используется idx_materialized_feeds_user_created_post
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE materialized_feeds ADD COLUMN IF NOT EXISTS post_text TEXT NOT NULL DEFAULT '';
+
+UPDATE materialized_feeds mf
+SET post_text = COALESCE(p.content, '')
+FROM posts p
+WHERE p.id = mf.post_id;
+
+ALTER TABLE materialized_feeds ALTER COLUMN post_text DROP DEFAULT;
+-- +goose StatementEnd
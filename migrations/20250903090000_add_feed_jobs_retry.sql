@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Количество попыток, время следующего повтора и исходная задача для повторной публикации
+ALTER TABLE feed_jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feed_jobs ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE feed_jobs ADD COLUMN IF NOT EXISTS payload JSONB;
+
+-- Индекс для поиска зависших и упавших заданий
+CREATE INDEX IF NOT EXISTS idx_feed_jobs_status_updated_at ON feed_jobs(status, updated_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_feed_jobs_status_updated_at;
+
+ALTER TABLE feed_jobs DROP COLUMN IF EXISTS payload;
+ALTER TABLE feed_jobs DROP COLUMN IF EXISTS next_retry_at;
+ALTER TABLE feed_jobs DROP COLUMN IF EXISTS attempts;
+-- +goose StatementEnd
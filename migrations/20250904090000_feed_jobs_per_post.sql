@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Задание отслеживает раскладку поста по всем лентам, а не по одному получателю
+ALTER TABLE feed_jobs ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE feed_jobs ADD COLUMN IF NOT EXISTS event_type VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_feed_jobs_post_id ON feed_jobs(post_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_feed_jobs_post_id;
+
+DELETE FROM feed_jobs WHERE user_id IS NULL;
+ALTER TABLE feed_jobs DROP COLUMN IF EXISTS event_type;
+ALTER TABLE feed_jobs ALTER COLUMN user_id SET NOT NULL;
+-- +goose StatementEnd
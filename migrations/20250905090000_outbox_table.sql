@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Доменные события, записанные в одной транзакции с изменением данных.
+-- Relay публикует их в RabbitMQ и отмечает dispatched_at
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMP WITH TIME ZONE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+-- Очередь неотправленных событий в порядке записи
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (created_at, id) WHERE dispatched_at IS NULL;
+-- Для удаления отправленных событий
+CREATE INDEX IF NOT EXISTS idx_outbox_dispatched_at ON outbox (dispatched_at) WHERE dispatched_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose NO TRANSACTION
+
+-- Позиции прочтения диалогов: последнее прочитанное сообщение каждого участника.
+-- Общая для всех устройств пользователя, поэтому непрочитанные сообщения на них совпадают
+CREATE TABLE IF NOT EXISTS dialog_reads (
+    dialog_key uuid NOT NULL,
+    user_id uuid NOT NULL,
+    peer_id uuid NOT NULL,
+    message_id uuid NOT NULL,
+    message_created_at timestamp NOT NULL,
+    read_at timestamp WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (dialog_key, user_id)
+);
+
+-- Распределяем вместе с сообщениями диалога, чтобы позиции и сообщения лежали на одном шарде
+SELECT
+    create_distributed_table('dialog_reads', 'dialog_key', colocate_with => 'dialog_messages');
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dialog_reads;
+-- +goose StatementEnd
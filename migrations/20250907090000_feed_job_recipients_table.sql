@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Получатели, которым задание раскладки уже записало пост и отправило уведомление.
+-- Повтор задания после ошибки в одной из пачек пропускает их и не дублирует уведомления
+CREATE TABLE IF NOT EXISTS feed_job_recipients (
+    job_id UUID NOT NULL REFERENCES feed_jobs(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    PRIMARY KEY (job_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS feed_job_recipients;
+-- +goose StatementEnd
@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Количество друзей пользователя: дружба хранится в одном или в обоих направлениях,
+-- поэтому считаются различные пользователи, связанные с ним в любом направлении.
+-- По нему лента находит популярных друзей без подсчета друзей каждого друга при чтении
+ALTER TABLE users ADD COLUMN IF NOT EXISTS friend_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users u
+SET friend_count = c.friend_count
+FROM (
+    SELECT id, COUNT(*) AS friend_count
+    FROM (
+        SELECT user_id AS id, friend_id AS other_id FROM friends
+        UNION
+        SELECT friend_id, user_id FROM friends
+    ) pairs
+    GROUP BY id
+) c
+WHERE u.id = c.id;
+
+-- Счетчик меняется, только если связи в обратном направлении нет. Строки обоих пользователей
+-- блокируются до проверки, поэтому встречные добавления или удаления не посчитаются дважды
+CREATE OR REPLACE FUNCTION friends_update_friend_count() RETURNS trigger AS $$
+DECLARE
+    pair friends%ROWTYPE;
+    delta INTEGER;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        pair := NEW;
+        delta := 1;
+    ELSE
+        pair := OLD;
+        delta := -1;
+    END IF;
+
+    PERFORM 1 FROM users WHERE id IN (pair.user_id, pair.friend_id) ORDER BY id FOR UPDATE;
+
+    IF NOT EXISTS (SELECT 1 FROM friends WHERE user_id = pair.friend_id AND friend_id = pair.user_id) THEN
+        UPDATE users SET friend_count = friend_count + delta WHERE id IN (pair.user_id, pair.friend_id);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER friends_friend_count
+    AFTER INSERT OR DELETE ON friends
+    FOR EACH ROW EXECUTE FUNCTION friends_update_friend_count();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS friends_friend_count ON friends;
+DROP FUNCTION IF EXISTS friends_update_friend_count();
+
+ALTER TABLE users DROP COLUMN IF EXISTS friend_count;
+-- +goose StatementEnd
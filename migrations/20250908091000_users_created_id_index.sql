@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Индекс под keyset-пагинацию поиска пользователей по (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_users_created_id ON users (created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_created_id;
+-- +goose StatementEnd
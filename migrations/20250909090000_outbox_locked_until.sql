@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- До какого момента событие закреплено за relay, который его публикует. Relay берет события
+-- короткой транзакцией и публикует их вне ее, поэтому блокировка строки не держится на время публикации
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE outbox DROP COLUMN IF EXISTS locked_until;
+-- +goose StatementEnd
@@ -28,6 +28,9 @@ const (
 // BirthDate Дата рождения
 type BirthDate = openapi_types.Date
 
+// Cursor Непрозрачный курсор keyset-пагинации по (created_at, id)
+type Cursor = string
+
 // DialogMessage defines model for DialogMessage.
 type DialogMessage struct {
 	// From Идентификатор пользователя
@@ -97,6 +100,13 @@ type N5xx struct {
 	RequestId *string `json:"request_id,omitempty"`
 }
 
+// GetDialogUserIdListParams defines parameters for GetDialogUserIdList.
+type GetDialogUserIdListParams struct {
+	// Cursor Курсор из заголовка X-Next-Cursor предыдущего ответа
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+	Limit  *int    `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
 // PostDialogUserIdSendJSONBody defines parameters for PostDialogUserIdSend.
 type PostDialogUserIdSendJSONBody struct {
 	// Text Текст сообщения
@@ -118,8 +128,9 @@ type PostPostCreateJSONBody struct {
 
 // GetPostFeedParams defines parameters for GetPostFeed.
 type GetPostFeedParams struct {
-	Offset *float32 `form:"offset,omitempty" json:"offset,omitempty"`
-	Limit  *float32 `form:"limit,omitempty" json:"limit,omitempty"`
+	// Cursor Курсор из заголовка X-Next-Cursor предыдущего ответа
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+	Limit  *int    `form:"limit,omitempty" json:"limit,omitempty"`
 }
 
 // PutPostUpdateJSONBody defines parameters for PutPostUpdate.
@@ -150,6 +161,10 @@ type GetUserSearchParams struct {
 
 	// LastName Условие поиска по фамилии
 	LastName string `form:"last_name" json:"last_name"`
+
+	// Cursor Курсор из заголовка X-Next-Cursor предыдущего ответа
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+	Limit  *int    `form:"limit,omitempty" json:"limit,omitempty"`
 }
 
 // PostDialogUserIdSendJSONRequestBody defines body for PostDialogUserIdSend for application/json ContentType.
@@ -171,7 +186,7 @@ type PostUserRegisterJSONRequestBody PostUserRegisterJSONBody
 type ServerInterface interface {
 
 	// (GET /dialog/{user_id}/list)
-	GetDialogUserIdList(w http.ResponseWriter, r *http.Request, userId UserId)
+	GetDialogUserIdList(w http.ResponseWriter, r *http.Request, userId UserId, params GetDialogUserIdListParams)
 
 	// (POST /dialog/{user_id}/send)
 	PostDialogUserIdSend(w http.ResponseWriter, r *http.Request, userId UserId)
@@ -239,8 +254,27 @@ func (siw *ServerInterfaceWrapper) GetDialogUserIdList(w http.ResponseWriter, r
 
 	r = r.WithContext(ctx)
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetDialogUserIdListParams
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetDialogUserIdList(w, r, userId)
+		siw.Handler.GetDialogUserIdList(w, r, userId, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -422,11 +456,11 @@ func (siw *ServerInterfaceWrapper) GetPostFeed(w http.ResponseWriter, r *http.Re
 	// Parameter object where we will unmarshal all parameters from the context
 	var params GetPostFeedParams
 
-	// ------------- Optional query parameter "offset" -------------
+	// ------------- Optional query parameter "cursor" -------------
 
-	err = runtime.BindQueryParameter("form", true, false, "offset", r.URL.Query(), &params.Offset)
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "offset", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
 		return
 	}
 
@@ -571,6 +605,22 @@ func (siw *ServerInterfaceWrapper) GetUserSearch(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		siw.Handler.GetUserSearch(w, r, params)
 	}))
@@ -722,45 +772,49 @@ func HandlerWithOptions(si ServerInterface, options StdHTTPServerOptions) http.H
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/+RaX28bxxH/KotrH0mR1B870Evh1EhawEWL2H4yDON0tyQ3Je/Oe3utBYOAJDpxDBlN",
-	"0OahSNukf9HXE21GZ0qivsLsNypm9kjekUeJtCS7UR5sSeTe7vz5zcxvZu+p5fjtwPe4p0Jr86kleRj4",
-	"Xsjpj/VqFX+4PHSkCJTwPWvTgr9BH3oQwxEk8BpO9D70GbyGGE5Gf/SgB0P8yOqUrPVqbc4mMfT0Hgz1",
-	"DiRwCEPa1OzxBjcc6l29p7twirtsPHmCuzi+p7in8Fc7CFrCsXHDyqch7vrUCp0mb9v4WyD9gEsljCKO",
-	"7/ICIb5BKRkM9ReQwAEMIFlh8J3egT4qBjEc4v/6OfThBCU60l8yGMARxHpX70Kin0ECA4j155BAwuBU",
-	"78AQDuAI+nDM8JMDGNJffQYHep8UwiWvYMjwGP0Fbg0J7pt7eMUqWWo74NamJTzFG1yiEdo8DO1GkSbf",
-	"wikkepcMmOBpGZ0mW4VKCq+BO0n+OOKheiTcgs3+DK9RLL2X0ZD8xOAQYiMonrWAsc7QH05hSDIPIJ6v",
-	"/UjkVGYhuWttPhhb4uF4ob/1KXeU1cGV08YZ2yJmehf6egd69H9slawmt10uCSWfcCW3y7fqissCq/yR",
-	"VD3WX5YYKkl/HSIchimIh6hlX7+APvo2xi9PdBe+hxP09i4ZFaGzp1/mDGmVMriddnqHFDLfk5AfCqma",
-	"t21VhIKvyVExI2G+N15EcFkliz+x20ELt16t1m6Wq6vlas0qWXVftm1lbVou7lgAlNvCbvmNX02Alw+s",
-	"uvTb+POnktetTesnlUk2qaRCV+6HXP7Sxc0Uf6LOW5078B4+gA/6ix4yhROSj55PT58FzJSO91IZp0z7",
-	"T+jDAPGLnhwiVNHRBealkEgIYHslhEcMAzby/c+KTPwbPyw68TuTAE2cHOmXoxSp93AvOjXvCztSTV8+",
-	"ikIu07hezCvnr0UJF/cgrjaO6xQYO91rqaxzakxB8TqxdM3dWNuouzfKNzdWa+V1Z6tWtu0bbrm6Vtvi",
-	"N2ura866O8/e53p5zpl3fMnbTARh1Gau3/IlC4VidpurEnOwbjqKq0gy2xWBCB3hNRhvCVViIXeZ6zMu",
-	"orDtu0zxduBLJjxHuMKNPMUixVr2li8548pszVnbbng2s1vicWSvsDvcUVHI2nYkRciilpLC4SHj0g+Z",
-	"8JgTyTAKmYpkIHBVGNorReqj42cDeUv4DWkHze1Cz6BXKOPpXb2fh/t/KG0fQFJikOQXYgXUeytwWijH",
-	"FiYyN01kZ+FpkvE6JcsRqkjEP6UZ+HVeuL9SoRpg2BSJUBcyVI88u80LtT6eDu3RRzMbLRNuIXd8z513",
-	"6r8hhmNIkFxNnz791WyFnAm29NRlg21Oupk9MOROJIXavotapkDituTyVqSaBcd+lWN8xJuI+mCB3IMh",
-	"DEiobilTVg0bPND7cDSSrTsiGT0Gx5hniWz2WaXlN4Q3KqcoqhFmInpTqcBQBOHVqaYooci6v753/y77",
-	"hWg0W77tslvSaQqFRixZv+MyNOLXVlZXqmhWP+CeHQhr01pbqa5UMRPbqknqV1wqJpWnaRruVFrCpPcG",
-	"px8YdERa0S/Wx1yZ6mM8dQfX4m4SUwrRkgdPLYGH4wlWyTKwsUZJPlvtlIx4lkosVC4flvKUf9VQ/oV5",
-	"tlC8HS5V060JUG0p7e1CyvY1JNRhDOEV+RjZjO5iHe3pLkbhXJxiiJi2ozpPrLHCFVw0aVHOW1ujRmSR",
-	"fbFbobVrC67NxBK5PBtFDx52HuKCWWiF3KPgDlIGkccW1rksuO7i6ncPLmo0PvTd7Qv0b29JHKfI4BwC",
-	"2DHrZoNgCpP/0rtwSj0bEnrKT6dE9HvUtaQsP8cNoX9NoViXgntuxeUtrvgEkeS5qAiLkfqIHrlNT6QI",
-	"+X/IdLOsuyCrvGS6m/O+7tLE4wjLMfKeQwav9Y7uwiH0sV7NK6LXGg0hV8tA4S5X1wEHAxp9xHCE0d+D",
-	"IfTNeMPg4dVoCHbtnG7IVqb6TKfLdL5hUmE61TMffU79wS6DWHfJsnkiOp6mmS+PGRncDJpi/Ry/S5bm",
-	"rzSOy/FH4p4T2gnxeGpFoa1fwgkGM83ozMCKhP8sP1thU2PMVHyrVFCN76T89HKK4jJNR2CH4e99SU9k",
-	"Wop/UL+7Qxz6BGK0x2hENyhqmTqLF8+3rfT+b7mXl5Kvu6v8xla17Lh8tby+6myUbdtZK1e3Plir3aiv",
-	"1vjGB4uJWjqrpMfky3mAzOXuJQfi5sn1JbIMIo/RUPWNQdoVxjoFM8ZwxZE8bcfnE0r893Oz7l2Su9xM",
-	"6SKcbmEZF5mHnYOolA8eGiyMR0rXtB4QhEaE8BwCQK0JLV2w+l+g8I+d9fAtWP6Y5/V/JA6sc+6eNa1A",
-	"a36Ea4q99jjicnviNr9eD7nKXXC4vG5HLWVtVgvuaZ7pZ3RBs8eQHWSvVqgAm/ulhH7SLUpP7xtOoLvZ",
-	"WVmtWi1ZbeGJdtSmg9Ls4EXtLbpUKRWL2xJtMUfa2qy4f4EEjiGhMf8QXlEriJTgOY3/Y/0H/QISJD0D",
-	"zPJl5IaMCOIh9HDxhBnpz7DudvULxBxNnfvwJq9QRp/arD7vZIpD9xSLDG+mGuUc6dL74zDCXzNN03WO",
-	"qgZ2RmlOPCuyPubq/SfESyuRS4Pjh5xhJ86OgtGtxlnl737gXiaJutJLvCzhIuBd7iQN26ZjyoU/giob",
-	"hVwW5YPCzmDcqWI7gNVlgMUxzaFzbmlmEgt2gXMSy2XcCl1ucrq0i4nzDjk/OaHNT9/SDVfQK341Ohfi",
-	"2f7QTJmuMrURbiVviDB9PWbO2OfvNC1J0jnC+IrvhAyUefNnEfBSngy5/GR07GVly9xt9/u/zH7bG+tF",
-	"rqYvafIzc299kYvpyx4aZV55eS9jI5pNzoD+YkngymM55LZ0mmdXIHo9L5Py5pWXu2av8+oL2u2IYj4Z",
-	"JdfJC4CGCRgekIzqylSDloH/WQVm6tj/0vua1DCODxjPejNC5F+3+AaGcEJEpAAkS6umn02CZK56LftC",
-	"2uXPOF/Db/Vu0duh76abNDV4uW7SBMrpBJhXUn6vLPI6nf8FAAD//7q3zW9uLQAA",
+	"H4sIAAAAAAAC/+xa224bydF+lcb8/0UCkBKpg73QTeC1194FbCWxZWzWhmCMZlpUyySH7umJpTUIiNKu",
+	"D5CxXiR7EeRgJ9kEuR0duBpTIvUK1W8UVM0MySGHImnJh5VzYcoke6qruqq++qrYjwzLKVWcMi8r15h7",
+	"ZEjuVpyyy+nNTC6Hf2zuWlJUlHDKxpwBf4M67IIPhxDAPjT1NtQZ7IMPzfjNLuxCCz8yqhljJpcfIMSH",
+	"Xb0JLb0BARxAC3bbMl6jwJau6U29BccoZXZtDaVYTlnxssL/mpVKUVgmCpxcdVHqI8O1VnjJxP9VpFPh",
+	"UonQEMuxeYoSf0YtGbT0UwhgBxoQTDB4pTegjoaBDwf4qp9AHZqo0aF+waABh+Drmq5BoL+BABrg68cQ",
+	"QMDgWG9AC3bgEOpwxPCTHWjRuzqDHb1NBuGSPWgx3EY/RdEQ6Bc9D08YGUOtV7gxZ4iy4gUu8RBK3HXN",
+	"QpolL+EYAl2jAwygnrCpI8pVUpQLKEnyBx531T1hpwj7E+yjWnqzy0LyE4MD8ENFca8RDusE++EYWqRz",
+	"A/zB1scqRzoLyW1j7m77JBbbC52lVW4po4orew+nfRY+0zWo6w3YpVffyBgr3LS5pCi5yZVcz15aVlym",
+	"nMofyNQj/SLD0Eh6d4Dh0IqCuIVW1vUzqKNvffyyqbfgJ2iit2t0qBg6m/p54iCNTFfc9jq9SgaF35OS",
+	"nwqpVq6YKi0KfiBH+YyU+Qn24+AyMgZfM0uVIoqeyuUvZnNT2VzeyBjLjiyZypgzbJSYEiiXPek6ckAC",
+	"h047QGv1kzhxG3pLb+gaxct9vu5ylYVj8GEPAmh25Qq02C8syU3F7XumyjBh/zKh540FKz+/cPvhjSuX",
+	"kv8+f7h258rtr+e/vHN/XuSm5xe+WLu+cHP1q9XP1FdffjZ153Lu4Y2vr4o7C9bajdX51flrv00z7Iow",
+	"i07hRiejkoixLJ0S/v1/yZeNOeP/JjswORl5Y/K2y+UXNgpTfE0NW53YcAEfwAedUTfpSQDSj56Pdu/P",
+	"hIzRv2W/H/8JdWhgYmKItjAH9bPUuKFcDyhzNjMY9z40WBzUv0o74t84btqOr0JkDwHgUD+PsV9voiza",
+	"NekL01MrjrznuVxGgDWaV4avRQ1H9yCuDh1XTTnsSNZYcHocHgX4iZPO27PTs8v2hezF2al8dsZaymdN",
+	"84KdzU3nl/jF/NS0NWMPOu+hXh6w53VH8hITFdcrMdspOpK5QjGzxFWGWUgILMWVJ5lpi4pwLVEuMF4U",
+	"KsNcbjPbYVx4bsmxmeKliiOZKFvCFrZXVsxTrGguOZIzrkLRnJXMQtlkZlE88MwJdp1bynNZyfSkcJlX",
+	"VFJY3GVcOi4TZWZ50vVcpjxZEbjKdc2JNPPR8f2JvCScgjQrK+upnkGvEJTrmt5Ohvu/qR7tQJBhECQX",
+	"YmnXmxNwnKrHEiK0HSH0SfHUgfJqxrCESlPxj1Fp2U8q91eqwA1MmzQVloV01b2yWeKpVh/1pnb8UZ+g",
+	"cdLN5ZZTtgft+i/w4QgCZI29u/d+1V/6+5It2nXcZBsAN/0butzypFDrt9DKKJC4Kbm85KmVlG2/T1BZ",
+	"KnLE6bDyb0ILGqTUVqaLL4TVckdvw2Gs21bMnnYZHCHOEouus8miUxDlmCegqqEyHdVXlKqE3EeUl6mm",
+	"KKHodH+9cPsW+1wUVoqOabNL0loRCg8xY/yeSzdUPz8xNZHDY3UqvGxWhDFnTE/kJnJGxqiYaoXMn7Sp",
+	"mEw+imC4OlkUIbwXOP3BpCM2jn4xrnEVVp/QU9dxLUqTCCnEt+4+MgRujjsYGSMMGyOSbnRXOyU93s2R",
+	"RiuX/Wy/i5igm0IStkcMFSMC6eHvsvN8TWVD1hOy0jrs623Y11tUF4m5ogepDlL2kRUPPC7XO2ZYJMAY",
+	"Vetwv1DrNHFFURIqIc3my6ZXVMbcbK6P7/4FAswnKtMt2CMyivX8CZVvX3+nn0FAVA1tz2Kzhj1bCw5g",
+	"FxdHBKCpt/W3TNfI8mZUNerwujt5cfeSuSZKXsmYy+dy+FaUo7f9LUx1MZPsMKdyubHaOqF4yR2LaRkd",
+	"+DClNNdTO4QfIKCGtgV7lHlInvUWsptdvYXYOBA98KCTXUQihIy5E8NQ16jhweD6jk79NYt6pNBjj/X2",
+	"BIOXepO8gK+bpFFIwer0eNDzCHVT0IpFQ1O/IJAbMxKr1LrnBq1ve3ESF3Xa/GFr89TMjyIXO35aOz3i",
+	"2i7YJnTpBuy7i9VFXNCPYi4vUx2pOG4KjCGl6saxW7j6neLYYrtZ/9Sx108xA3nDHqWn7xjQa1TDdf2Z",
+	"3RP8P+oaHNPcoxkD6TGF7i5Fa9QpJ9oQqBvnMxSXpeBle9LmRa54JyLJc15aLHrqKj1yhZ6IIuTdB+NQ",
+	"J79KhcrnTG8lvK+3YD8aIx5GBXlfb+gtOCAgHMjXznU0uFyNEwq3uDoPcdCg8aEPh5j9yEQiohXGw148",
+	"SD53Tg95fVf16YXLaEbYJmSUF/TRY2pFawx8ZAZ9PU97Ih1+ecTowMNhLQ7rAkbT+/FaJQZBT6tCbU6n",
+	"wwG/Pfml1NbPoYnJrJ/GHg2V/zY5n2Q9PwVE6huZlGp8PWqFzqYojtPfVkzXfehIeqKre/0HjVY2qB3A",
+	"+eYL1h5zN9K68+roxfNNK71zn5eTWvIZe4pfWMplLZtPZWemrNmsaVrT2dzSJ9P5C8tTeT77yWiqZk4q",
+	"6T75clBAJrB7zB+VwidnxkAZjDx88eF1GGlvMdcpmTGHJ8OJ9smEEv9dDte9S3KXGF+ehtONrOMoo9ch",
+	"ERXxwYMwFtrTy3NaDyiEYkI4hABQa0JLR6z+pyj8bWctvgHLb/O8+kfiwGXO7ZMGY3iaVzlP8dpHMbHK",
+	"v9eJVb5nYvUhDKwwIEaaU/W0zwkqprfbyaW3Y+p8EJn/v/nUzwlACly14f8kELnG1fvH/jNjA2NH/M+5",
+	"mHSc7VXi3wpPqvS3w1Xvru85xU/j3dySAu9sh4bYIR4R3n8EhAJHNml4kNoEtZty7HwQbhtQD6vB4N8+",
+	"+4AFG94BwHIWv7WeLTgNm0idCTjhJsPBCeqx2eO74S20xd/H+4Lf3wqD/xbDuhO3kheEG92mGzDh+jsx",
+	"1CCmCPEP5006oK6LgqMEL+Gky+XNeNuzQsvEHZL3f0XkTe+BjHLh44yGXH23QU5z3eOs52NdF8ney4RM",
+	"b6QF/elA4K3nsstNaa2cXIHoNm8X5A0qL7dCWcPqy4/UI2DOBzG4du4Lh0wg5AHBgJ62K/xPKjA92/6H",
+	"rnfTLd32Bu2xdpcSyUtMeJe8ic+lBcnYpulvOkky0LyieSrrknsMt/AlXnkfybrzOrd4z4OL7snF9Icx",
+	"uQip0XiTixC/jjt4Mbi+n+uxxYeE8tXqfwcAAjVyOgk0AAA=",
 }
 
 // GetSwagger returns the content of the embedded swagger specification file